@@ -15,6 +15,8 @@ import (
 
 	"kubevirt-image-service/pkg/apis"
 	"kubevirt-image-service/pkg/controller"
+	"kubevirt-image-service/pkg/uploadproxy"
+	"kubevirt-image-service/pkg/webhook"
 	"kubevirt-image-service/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -37,6 +39,10 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	uploadProxyHost           = "0.0.0.0"
+	uploadProxyPort     int32 = 8443
+	uploadProxyCertDir        = "/tmp/upload-proxy/serving-certs"
+	webhookPort               = 9443
 )
 var log = logf.Log.WithName("cmd")
 
@@ -95,6 +101,7 @@ func main() {
 	mgr, err := manager.New(cfg, manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
 	})
 	if err != nil {
 		log.Error(err, "")
@@ -121,6 +128,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the upload proxy. Set ENABLE_UPLOAD_PROXY_TLS=false to serve http, e.g. locally or behind the ingress which terminates TLS
+	certDir := uploadProxyCertDir
+	if os.Getenv("ENABLE_UPLOAD_PROXY_TLS") == "false" {
+		certDir = ""
+	}
+	if err := mgr.Add(uploadproxy.New(mgr.GetAPIReader(), fmt.Sprintf("%s:%d", uploadProxyHost, uploadProxyPort), certDir)); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	// Setup the validating webhooks. Set ENABLE_WEBHOOKS=false to run the operator without the serving certificate, e.g. locally
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhook.AddToManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg, namespace)
 
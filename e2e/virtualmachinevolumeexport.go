@@ -15,6 +15,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func virtualMachineVolumeExportTest(t *testing.T, ctx *framework.Context) error {
@@ -56,12 +57,12 @@ func testLocalVmve(t *testing.T, cleanupOptions *framework.CleanupOptions, ns st
 	}
 	t.Logf("Vmve %s available\n", vmveName)
 
-	localPodName := vmveName + "-exporter-local"
-	if err := waitForPod(t, ns, localPodName); err != nil {
+	localJobName := vmveName + "-exporter-local"
+	if err := waitForJobPod(t, ns, localJobName); err != nil {
 		t.Log(err)
 		t.Fatal(err)
 	}
-	t.Logf("localPod %s is Running", localPodName)
+	t.Logf("Pod of localJob %s is Running", localJobName)
 	return nil
 }
 
@@ -147,17 +148,19 @@ func waitForVmveState(t *testing.T, namespace, name string) error {
 	})
 }
 
-func waitForPod(t *testing.T, namespace, name string) error {
+func waitForJobPod(t *testing.T, namespace, jobName string) error {
 	return wait.Poll(retryInterval, timeout, func() (done bool, err error) {
-		t.Logf("Waiting for creating pod: %s in Namespace: %s \n", name, namespace)
-		localPod := &corev1.Pod{}
-		if err = framework.Global.Client.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, localPod); err != nil {
-			if errors.IsNotFound(err) {
-				return false, nil
-			}
+		t.Logf("Waiting for creating pod of job: %s in Namespace: %s \n", jobName, namespace)
+		pods := &corev1.PodList{}
+		if err = framework.Global.Client.List(context.Background(), pods, client.InNamespace(namespace), client.MatchingLabels{util.JobNameLabel: jobName}); err != nil {
 			return false, err
 		}
-		t.Logf("Waiting for Running of %s pod\n", name)
-		return localPod.Status.Phase == corev1.PodRunning, nil
+		t.Logf("Waiting for Running of %s pod\n", jobName)
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodRunning {
+				return true, nil
+			}
+		}
+		return false, nil
 	})
 }
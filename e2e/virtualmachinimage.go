@@ -39,6 +39,9 @@ func virtualMachineImageTest(t *testing.T, ctx *framework.Context) error {
 	if err := testVmiWithPvcRwx(t, ns); err != nil {
 		return err
 	}
+	if err := testVmiFromRegistry(t, ns); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -71,6 +74,42 @@ func testVmiWithPvcRwx(t *testing.T, namespace string) error {
 	return waitForVmi(t, namespace, vmiName)
 }
 
+// virtualMachineImageFromS3Test imports the image which is exported to s3 by virtualMachineVolumeExportTest
+func virtualMachineImageFromS3Test(t *testing.T, ctx *framework.Context) error {
+	ns, err := ctx.GetWatchNamespace()
+	if err != nil {
+		return err
+	}
+	vmiName := "s3vmi"
+	vmi := newVmi(ns, vmiName)
+	vmi.Spec.Source = v1alpha1.VirtualMachineImageSource{
+		S3: &v1alpha1.VirtualMachineImageSourceS3{
+			Endpoint:  "http://rook-ceph-rgw-my-store.rook-ceph:80",
+			Bucket:    "ceph-bucket",
+			Key:       "disk.img",
+			SecretRef: "ceph-bucket",
+		},
+	}
+	if err := framework.Global.Client.Create(context.Background(), vmi, &cleanupOptions); err != nil {
+		return err
+	}
+	return waitForVmi(t, ns, vmiName)
+}
+
+func testVmiFromRegistry(t *testing.T, namespace string) error {
+	vmiName := "registryvmi"
+	vmi := newVmi(namespace, vmiName)
+	vmi.Spec.Source = v1alpha1.VirtualMachineImageSource{
+		Registry: &v1alpha1.VirtualMachineImageSourceRegistry{
+			URL: "docker://quay.io/kubevirt/cirros-container-disk-demo:latest",
+		},
+	}
+	if err := framework.Global.Client.Create(context.Background(), vmi, &cleanupOptions); err != nil {
+		return err
+	}
+	return waitForVmi(t, namespace, vmiName)
+}
+
 func waitForVmi(t *testing.T, namespace, name string) error {
 	return wait.Poll(retryInterval, timeout, func() (done bool, err error) {
 		t.Logf("Waiting for creating vmi: %s in Namespace: %s \n", name, namespace)
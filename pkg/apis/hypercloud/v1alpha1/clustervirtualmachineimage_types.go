@@ -0,0 +1,37 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterVirtualMachineImage is the Schema for the clustervirtualmachineimages API.
+// It is imported once into the VirtualMachineImage of the same name in the cluster image namespace, and VirtualMachineVolume in any namespace can refer to it
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=clustervirtualmachineimages,scope=Cluster,shortName=cvmim
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Current state of ClusterVirtualMachineImage"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.progress.phase",description="Current phase of the import"
+// +kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.progress.percentage",description="Progress of the current phase of the import"
+// +kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.revision",description="Revision of the image",priority=1
+type ClusterVirtualMachineImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VirtualMachineImageSpec `json:"spec,omitempty"`
+	// Status is the status of the VirtualMachineImage which imports the ClusterVirtualMachineImage
+	Status VirtualMachineImageStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterVirtualMachineImageList contains a list of ClusterVirtualMachineImage
+type ClusterVirtualMachineImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterVirtualMachineImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterVirtualMachineImage{}, &ClusterVirtualMachineImageList{})
+}
@@ -34,3 +34,53 @@ type Condition struct {
 	// +required
 	Message string `json:"message" protobuf:"bytes,6,opt,name=message"`
 }
+
+// Types of the conditions of the stages, which are shared by the resources
+const (
+	// ConditionPvcBound indicates the pvc of the resource is bound
+	ConditionPvcBound = "PvcBound"
+	// ConditionImported indicates the source image is imported into the image pvc
+	ConditionImported = "Imported"
+	// ConditionSnapshotReady indicates the snapshot of the image pvc is ready to use
+	ConditionSnapshotReady = "SnapshotReady"
+	// ConditionProgressing indicates the resource is being created
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded indicates the resource failed, and the reason and the message tell why
+	ConditionDegraded = "Degraded"
+)
+
+// Reasons of the conditions, which are stable for the clients. The reasons of the specific failures are defined by the controllers
+const (
+	// ReasonCreating is the reason of ReadyToUse and Progressing conditions while the resource is being created
+	ReasonCreating = "Creating"
+	// ReasonReady is the reason of ReadyToUse condition when the resource is ready to use
+	ReasonReady = "Ready"
+	// ReasonCompleted is the reason of ReadyToUse condition when the export is completed
+	ReasonCompleted = "Completed"
+	// ReasonPending is the reason of ReadyToUse condition while the resource waits for the resources it depends on
+	ReasonPending = "Pending"
+	// ReasonFailed is the reason of ReadyToUse condition when the resource failed without the specific reason
+	ReasonFailed = "Failed"
+	// ReasonAsExpected is the reason of Progressing and Degraded conditions which are False
+	ReasonAsExpected = "AsExpected"
+	// ReasonPvcNotFound is the reason of the conditions of the stages before the pvc is created
+	ReasonPvcNotFound = "PvcNotFound"
+	// ReasonPvcPending is the reason of PvcBound condition while the pvc is pending
+	ReasonPvcPending = "PvcPending"
+	// ReasonPvcLost is the reason of PvcBound condition when the volume of the pvc is lost
+	ReasonPvcLost = "PvcLost"
+	// ReasonPvcBound is the reason of PvcBound condition when the pvc is bound
+	ReasonPvcBound = "PvcBound"
+	// ReasonImporting is the reason of Imported condition while the source image is being imported
+	ReasonImporting = "Importing"
+	// ReasonImported is the reason of Imported condition when the source image is imported
+	ReasonImported = "Imported"
+	// ReasonSnapshotNotFound is the reason of SnapshotReady condition before the snapshot is created
+	ReasonSnapshotNotFound = "SnapshotNotFound"
+	// ReasonSnapshotNotReady is the reason of SnapshotReady condition while the snapshot is being created
+	ReasonSnapshotNotReady = "SnapshotNotReady"
+	// ReasonSnapshotFailed is the reason of SnapshotReady and ReadyToUse conditions when the snapshot failed
+	ReasonSnapshotFailed = "SnapshotFailed"
+	// ReasonSnapshotReady is the reason of SnapshotReady condition when the snapshot is ready to use
+	ReasonSnapshotReady = "SnapshotReady"
+)
@@ -0,0 +1,100 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KubevirtImageServiceConfigName is the name of the KubevirtImageServiceConfig which the reconcilers read
+	KubevirtImageServiceConfigName = "default"
+)
+
+// KubevirtImageServiceConfigSpec defines the configuration of the worker pods and the defaults of kubevirt-image-service.
+// The built-in value is used for the field which is not set
+type KubevirtImageServiceConfigSpec struct {
+	// Images are the container images of the worker pods
+	// +optional
+	Images KubevirtImageServiceConfigImages `json:"images,omitempty"`
+	// ImagePullPolicy is the image pull policy of the worker pods
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// ImagePullSecrets are the secrets to pull the images of the worker pods. The secrets must exist in the namespace of each worker pod
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Resources are the resource requests and limits of the containers of the worker pods
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// DefaultStorageClassName is the storage class of the VirtualMachineImage and VirtualMachineVolume which don't set it
+	// +optional
+	DefaultStorageClassName string `json:"defaultStorageClassName,omitempty"`
+	// DefaultSnapshotClassName is the snapshot class of the VirtualMachineImage which doesn't set it
+	// +optional
+	DefaultSnapshotClassName string `json:"defaultSnapshotClassName,omitempty"`
+	// ClusterImageNamespace is the namespace where ClusterVirtualMachineImages are imported. Default is the namespace of the operator
+	// +optional
+	ClusterImageNamespace string `json:"clusterImageNamespace,omitempty"`
+	// FilesystemOverhead is the fraction of the Filesystem-mode pvc which is reserved for the file system, when the image pvc is sized
+	// from the source image. It is a decimal less than 1, e.g. "0.1". Default is "0.055"
+	// +kubebuilder:validation:Pattern=`^0(\.[0-9]+)?$`
+	// +optional
+	FilesystemOverhead string `json:"filesystemOverhead,omitempty"`
+	// LogVerbosity is the log level of the importer and the upload server
+	// +optional
+	LogVerbosity *int32 `json:"logVerbosity,omitempty"`
+	// JobActiveDeadlineSeconds is the duration in seconds which the worker job may be active before it fails. Default is 86400
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	JobActiveDeadlineSeconds *int64 `json:"jobActiveDeadlineSeconds,omitempty"`
+	// JobTTLSecondsAfterFinished is the duration in seconds after which the finished worker job is deleted. Default is 3600
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	JobTTLSecondsAfterFinished *int32 `json:"jobTTLSecondsAfterFinished,omitempty"`
+}
+
+// KubevirtImageServiceConfigImages defines the container images of the worker pods
+type KubevirtImageServiceConfigImages struct {
+	// Importer is the image of the importer which converts the source image and imports containerDisk of the registry source
+	// +optional
+	Importer string `json:"importer,omitempty"`
+	// UploadServer is the image of the upload server which receives the uploaded and cloned image
+	// +optional
+	UploadServer string `json:"uploadServer,omitempty"`
+	// Fetcher is the image of the fetcher which downloads the http and s3 source image and verifies the checksum
+	// +optional
+	Fetcher string `json:"fetcher,omitempty"`
+	// CloneSource is the image of the clone source pod which sends the source pvc to the upload server. It is the fetcher image if it is empty
+	// +optional
+	CloneSource string `json:"cloneSource,omitempty"`
+	// Exporter is the image of the exporter which exports the VirtualMachineVolume
+	// +optional
+	Exporter string `json:"exporter,omitempty"`
+	// Local is the image of the pod which holds the exported disk to be copied to the local
+	// +optional
+	Local string `json:"local,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubevirtImageServiceConfig is the Schema for the kubevirtimageserviceconfigs API.
+// Only the config named "default" is read by the reconcilers
+// +kubebuilder:resource:path=kubevirtimageserviceconfigs,scope=Cluster,shortName=kisconfig
+type KubevirtImageServiceConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KubevirtImageServiceConfigSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubevirtImageServiceConfigList contains a list of KubevirtImageServiceConfig
+type KubevirtImageServiceConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubevirtImageServiceConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubevirtImageServiceConfig{}, &KubevirtImageServiceConfigList{})
+}
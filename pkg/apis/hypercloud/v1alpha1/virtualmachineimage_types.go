@@ -2,13 +2,80 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// VirtualMachineImageSource represents the source for our VirtualMachineImage, this can be HTTP or host path
+// VirtualMachineImageSource represents the source for our VirtualMachineImage, this can be HTTP, host path, S3, container registry, upload, pvc or VirtualMachineVolume
 type VirtualMachineImageSource struct {
-	HTTP     string                             `json:"http,omitempty"`
-	HostPath *VirtualMachineImageSourceHostPath `json:"hostPath,omitempty"`
+	HTTP                 string                             `json:"http,omitempty"`
+	HostPath             *VirtualMachineImageSourceHostPath `json:"hostPath,omitempty"`
+	S3                   *VirtualMachineImageSourceS3       `json:"s3,omitempty"`
+	Registry             *VirtualMachineImageSourceRegistry `json:"registry,omitempty"`
+	Upload               *VirtualMachineImageSourceUpload   `json:"upload,omitempty"`
+	PVC                  *VirtualMachineImageSourcePVC      `json:"pvc,omitempty"`
+	VirtualMachineVolume *VirtualMachineImageSourceVolume   `json:"virtualMachineVolume,omitempty"`
+	// Checksum is the expected checksum of the source image. It is supported for http, s3 and hostPath sources
+	// +optional
+	Checksum *VirtualMachineImageSourceChecksum `json:"checksum,omitempty"`
+	// Format is the format of the source image. It is detected automatically if it is empty. It is supported for http, s3 and hostPath sources
+	// +optional
+	Format VirtualMachineImageFormat `json:"format,omitempty"`
+	// HTTPOptions provides the credentials, the extra headers and the CA bundle of the http source
+	// +optional
+	HTTPOptions *VirtualMachineImageSourceHTTPOptions `json:"httpOptions,omitempty"`
+	// InsecureSkipTLSVerify disables the TLS certificate verification of the http, s3 and registry sources
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// VirtualMachineImageSourceHTTPOptions provides the parameters to access the http source
+type VirtualMachineImageSourceHTTPOptions struct {
+	// SecretRef is the name of the secret which contains username and password for the basic auth, or token for the bearer auth.
+	// The extraHeaders key of the secret contains the extra headers which must be kept secret, one header per line
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// CertConfigMap is the name of the config map which contains the CA bundle of the http server
+	// +optional
+	CertConfigMap string `json:"certConfigMap,omitempty"`
+	// ExtraHeaders is the list of the extra headers of the http request, e.g. "X-Api-Version: 2"
+	// +optional
+	ExtraHeaders []string `json:"extraHeaders,omitempty"`
+}
+
+// VirtualMachineImageFormat is the disk format of the source image
+// +kubebuilder:validation:Enum=raw;qcow2;vmdk;vhd;vhdx;iso
+type VirtualMachineImageFormat string
+
+const (
+	// VirtualMachineImageFormatRaw indicates the raw disk image
+	VirtualMachineImageFormatRaw VirtualMachineImageFormat = "raw"
+	// VirtualMachineImageFormatQcow2 indicates the qcow2 disk image
+	VirtualMachineImageFormatQcow2 VirtualMachineImageFormat = "qcow2"
+	// VirtualMachineImageFormatVmdk indicates the VMware disk image
+	VirtualMachineImageFormatVmdk VirtualMachineImageFormat = "vmdk"
+	// VirtualMachineImageFormatVhd indicates the Hyper-V legacy disk image
+	VirtualMachineImageFormatVhd VirtualMachineImageFormat = "vhd"
+	// VirtualMachineImageFormatVhdx indicates the Hyper-V disk image
+	VirtualMachineImageFormatVhdx VirtualMachineImageFormat = "vhdx"
+	// VirtualMachineImageFormatIso indicates the ISO 9660 cd-rom image
+	VirtualMachineImageFormatIso VirtualMachineImageFormat = "iso"
+)
+
+// VirtualMachineImageSourceChecksum provides the expected checksum of the source image. Only one of the fields can be set
+type VirtualMachineImageSourceChecksum struct {
+	// SHA256 is the hex encoded sha256 digest of the source image
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+	// SHA512 is the hex encoded sha512 digest of the source image
+	// +optional
+	SHA512 string `json:"sha512,omitempty"`
+	// MD5 is the hex encoded md5 digest of the source image
+	// +optional
+	MD5 string `json:"md5,omitempty"`
+	// SHA256SumsURL is the url of the SHA256SUMS file which contains the sha256 digest of the source image file
+	// +optional
+	SHA256SumsURL string `json:"sha256SumsURL,omitempty"`
 }
 
 // VirtualMachineImageSourceHostPath provides the parameters to create a virtual machine image from a host path
@@ -17,11 +84,85 @@ type VirtualMachineImageSourceHostPath struct {
 	NodeName string `json:"nodeName"`
 }
 
+// VirtualMachineImageSourceS3 provides the parameters to create a virtual machine image from a S3 compatible object storage
+type VirtualMachineImageSourceS3 struct {
+	// Endpoint is the S3 endpoint, e.g. http://rook-ceph-rgw-my-store.rook-ceph:80
+	Endpoint string `json:"endpoint"`
+	// Bucket is the name of the bucket which contains the image
+	Bucket string `json:"bucket"`
+	// Key is the object key of the image in the bucket
+	Key string `json:"key"`
+	// SecretRef is the secret reference which contains AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the S3 endpoint
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// CertConfigMap is the name of the config map which contains the CA bundle of the S3 endpoint
+	// +optional
+	CertConfigMap string `json:"certConfigMap,omitempty"`
+}
+
+// VirtualMachineImageSourceRegistry provides the parameters to create a virtual machine image from a containerDisk image in a container registry
+type VirtualMachineImageSourceRegistry struct {
+	// URL is the image reference of the containerDisk, e.g. docker://quay.io/kubevirt/cirros-container-disk-demo:latest
+	URL string `json:"url"`
+	// SecretRef is the name of the basic-auth secret which contains username and password of the registry
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// CertConfigMap is the name of the config map which contains the CA bundle of the registry
+	// +optional
+	CertConfigMap string `json:"certConfigMap,omitempty"`
+}
+
+// VirtualMachineImageSourceUpload indicates the image is uploaded by the user through the upload proxy
+type VirtualMachineImageSourceUpload struct{}
+
+// VirtualMachineImageSourcePVC provides the parameters to create a virtual machine image from an existing pvc
+type VirtualMachineImageSourcePVC struct {
+	// Namespace is the namespace of the source pvc. If it is empty, the namespace of the VirtualMachineImage is used.
+	// The source pvc in another namespace must allow the namespace of the VirtualMachineImage with the clone-allowed-namespaces annotation
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the source pvc
+	Name string `json:"name"`
+}
+
+// VirtualMachineImageSourceVolume provides the parameters to capture a VirtualMachineVolume in the same namespace as a virtual machine image
+type VirtualMachineImageSourceVolume struct {
+	// Name is the name of the VirtualMachineVolume
+	Name string `json:"name"`
+}
+
 // VirtualMachineImageSpec defines the desired state of VirtualMachineImage
 type VirtualMachineImageSpec struct {
-	Source            VirtualMachineImageSource        `json:"source"`
-	PVC               corev1.PersistentVolumeClaimSpec `json:"pvc"`
-	SnapshotClassName string                           `json:"snapshotClassName"`
+	Source VirtualMachineImageSource `json:"source"`
+	// PVC is the spec of the image pvc. If the storage request is not set, the pvc is sized from the virtual size of the http or hostPath source image,
+	// or the size of the pvc or VirtualMachineVolume source
+	PVC corev1.PersistentVolumeClaimSpec `json:"pvc"`
+	// SnapshotClassName is the snapshot class of the image snapshot. If it is empty, the defaultSnapshotClassName of KubevirtImageServiceConfig is used
+	// +optional
+	SnapshotClassName string `json:"snapshotClassName,omitempty"`
+	// MaxRetries is the backoff limit of the importer, checksum and probe jobs, the number of times the failed pod is retried
+	// before the image becomes Error. Default is 3
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// RefreshPolicy checks the http or s3 source image periodically, and imports it into a new revision if it is changed
+	// +optional
+	RefreshPolicy *VirtualMachineImageRefreshPolicy `json:"refreshPolicy,omitempty"`
+	// RevisionHistoryLimit is the number of revisions to keep. The snapshots of the oldest revisions are deleted
+	// unless VirtualMachineVolumes use them. Default is to keep all revisions
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// VirtualMachineImageRefreshPolicy defines when the source image is checked. Only one of interval and schedule can be set
+type VirtualMachineImageRefreshPolicy struct {
+	// Interval is the interval between the checks, e.g. 24h
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Schedule is the cron schedule of the checks in UTC, e.g. "0 3 * * 1"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
 }
 
 // VirtualMachineImageState is the current state of VirtualMachineImage
@@ -39,6 +180,10 @@ const (
 const (
 	// ConditionReadyToUse indicated vmi is ready to use
 	ConditionReadyToUse = "ReadyToUse"
+	// ConditionRefreshed indicates the result of the last refresh check of the source image
+	ConditionRefreshed = "Refreshed"
+	// ConditionStorageValidated indicates the result of the check of the storage class and the snapshot class before the image pvc is created
+	ConditionStorageValidated = "StorageValidated"
 )
 
 // VirtualMachineImageConditionType defines the condition of VirtualMachineImage
@@ -51,6 +196,123 @@ type VirtualMachineImageStatus struct {
 	// Conditions indicate current conditions of VirtualMachineImage
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the .metadata.generation which the status is based on
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Digest is the verified digest of the source image, e.g. sha256:{hex encoded digest}
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// Format is the detected format of the source image
+	// +optional
+	Format VirtualMachineImageFormat `json:"format,omitempty"`
+	// VirtualSize is the virtual size of the source image
+	// +optional
+	VirtualSize *resource.Quantity `json:"virtualSize,omitempty"`
+	// StorageRequest is the storage request of the image pvc which is sized from the source image,
+	// because the storage request is not set in spec.pvc
+	// +optional
+	StorageRequest *resource.Quantity `json:"storageRequest,omitempty"`
+	// Progress is the progress of the import
+	// +optional
+	Progress *VirtualMachineImageProgress `json:"progress,omitempty"`
+	// FailedCount is the number of the failed pods of the importer, checksum or probe job since the last retry request
+	// +optional
+	FailedCount int32 `json:"failedCount,omitempty"`
+	// LastFailure is the last failure of the importer, checksum or probe job
+	// +optional
+	LastFailure *VirtualMachineImageFailure `json:"lastFailure,omitempty"`
+	// DependentVolumes are the namespace/name of VirtualMachineVolumes created from the image, which block the deletion of the image
+	// +optional
+	DependentVolumes []string `json:"dependentVolumes,omitempty"`
+	// Revision is the revision of the image which is imported or being imported. It is increased when the changed source image is imported again
+	// +optional
+	Revision int32 `json:"revision,omitempty"`
+	// SourceVersion is the version of the source image of the revision, which is compared with the source image by the refresh check
+	// +optional
+	SourceVersion *VirtualMachineImageSourceVersion `json:"sourceVersion,omitempty"`
+	// LastRefreshTime is the time when the source image is checked last
+	// +optional
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+	// Revisions are the available revisions of the image, from the oldest to the latest
+	// +optional
+	Revisions []VirtualMachineImageRevision `json:"revisions,omitempty"`
+}
+
+// VirtualMachineImageRevision is the revision of the image which is imported into its own pvc and snapshot
+type VirtualMachineImageRevision struct {
+	// Revision is the number of the revision, starting from 1
+	Revision int32 `json:"revision"`
+	// SnapshotName is the name of the snapshot of the revision
+	SnapshotName string `json:"snapshotName"`
+	// Digest is the verified digest of the source image of the revision
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// StorageRequest is the storage request of the image pvc of the revision
+	// +optional
+	StorageRequest *resource.Quantity `json:"storageRequest,omitempty"`
+	// CreationTime is the time when the snapshot of the revision becomes ready to use
+	CreationTime metav1.Time `json:"creationTime"`
+}
+
+// VirtualMachineImageSourceVersion identifies the content of the source image
+type VirtualMachineImageSourceVersion struct {
+	// ETag is the ETag header of the source image
+	// +optional
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the Last-Modified header of the source image
+	// +optional
+	LastModified string `json:"lastModified,omitempty"`
+	// Digest is the sha256 digest of the source image in the SHA256SUMS file of the checksum
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// VirtualMachineImageProgressPhase is the phase of the import
+type VirtualMachineImageProgressPhase string
+
+const (
+	// VirtualMachineImageProgressPhaseFetching indicates the source image is being fetched into the scratch pvc
+	VirtualMachineImageProgressPhaseFetching VirtualMachineImageProgressPhase = "Fetching"
+	// VirtualMachineImageProgressPhaseConverting indicates the source image is being converted and written to the image pvc
+	VirtualMachineImageProgressPhaseConverting VirtualMachineImageProgressPhase = "Converting"
+	// VirtualMachineImageProgressPhaseCompleted indicates the import is completed
+	VirtualMachineImageProgressPhaseCompleted VirtualMachineImageProgressPhase = "Completed"
+)
+
+// VirtualMachineImageProgress is the progress of the current phase of the import
+type VirtualMachineImageProgress struct {
+	// Phase is the current phase of the import
+	Phase VirtualMachineImageProgressPhase `json:"phase"`
+	// TransferredBytes is the bytes transferred in the phase
+	TransferredBytes int64 `json:"transferredBytes"`
+	// TotalBytes is the total bytes of the phase. It is not set if the total is unknown, e.g. the compressed source image
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// Percentage is the percentage of the transferred bytes of the phase, e.g. 45.20%
+	// +optional
+	Percentage string `json:"percentage,omitempty"`
+	// EstimatedCompletionTime is the estimated time when the phase completes
+	// +optional
+	EstimatedCompletionTime *metav1.Time `json:"estimatedCompletionTime,omitempty"`
+}
+
+// VirtualMachineImageFailure is the failure of the container of the importer, checksum or probe pod
+type VirtualMachineImageFailure struct {
+	// Container is the name of the failed container. It is empty if the pod failed without the container failure, e.g. evicted
+	// +optional
+	Container string `json:"container,omitempty"`
+	// ExitCode is the exit code of the failed container
+	// +optional
+	ExitCode int32 `json:"exitCode,omitempty"`
+	// Message is the termination message of the failed container
+	// +optional
+	Message string `json:"message,omitempty"`
+	// JobFailedReason is the reason of the failed worker job, e.g. BackoffLimitExceeded or DeadlineExceeded.
+	// It is empty if the job is retrying the failed pod
+	// +optional
+	JobFailedReason string `json:"jobFailedReason,omitempty"`
+	// Time is the time when the failure is detected
+	Time metav1.Time `json:"time"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -59,6 +321,9 @@ type VirtualMachineImageStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=virtualmachineimages,scope=Namespaced,shortName=vmim
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Current state of VirtualMachineImage"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.progress.phase",description="Current phase of the import"
+// +kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.progress.percentage",description="Progress of the current phase of the import"
+// +kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.revision",description="Revision of the image",priority=1
 type VirtualMachineImage struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
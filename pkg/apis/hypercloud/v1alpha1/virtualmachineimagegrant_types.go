@@ -0,0 +1,40 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VirtualMachineImageGrantSpec defines the namespaces allowed to use the VirtualMachineImage
+type VirtualMachineImageGrantSpec struct {
+	// VirtualMachineImageName is the name of the VirtualMachineImage in the namespace of the grant
+	VirtualMachineImageName string `json:"virtualMachineImageName"`
+	// Namespaces are the namespaces whose VirtualMachineVolume can be created from the image. "*" allows all namespaces
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VirtualMachineImageGrant is the Schema for the virtualmachineimagegrants API.
+// It is created in the namespace of the VirtualMachineImage to share the image with other namespaces
+// +kubebuilder:resource:path=virtualmachineimagegrants,scope=Namespaced,shortName=vmimg
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.virtualMachineImageName",description="Name of the granted VirtualMachineImage"
+type VirtualMachineImageGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VirtualMachineImageGrantSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VirtualMachineImageGrantList contains a list of VirtualMachineImageGrant
+type VirtualMachineImageGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtualMachineImageGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtualMachineImageGrant{}, &VirtualMachineImageGrantList{})
+}
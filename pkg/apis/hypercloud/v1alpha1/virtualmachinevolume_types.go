@@ -7,9 +7,28 @@ import (
 
 // VirtualMachineImageName identifies which VirtualMachineImage source to create a VirtualMachineVolume from
 type VirtualMachineImageName struct {
-	Name string `json:"name"`
+	// Kind is the kind of the image, VirtualMachineImage in the same namespace or ClusterVirtualMachineImage. Default is VirtualMachineImage
+	// +kubebuilder:validation:Enum=VirtualMachineImage;ClusterVirtualMachineImage
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Namespace is the namespace of the VirtualMachineImage, which must be granted to the namespace of the volume by VirtualMachineImageGrant.
+	// Default is the namespace of the volume. It can't be set for ClusterVirtualMachineImage
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Revision pins the revision of the image. Default is the latest available revision
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Revision *int32 `json:"revision,omitempty"`
 }
 
+const (
+	// VirtualMachineImageKind is the kind of VirtualMachineImage
+	VirtualMachineImageKind = "VirtualMachineImage"
+	// ClusterVirtualMachineImageKind is the kind of ClusterVirtualMachineImage
+	ClusterVirtualMachineImageKind = "ClusterVirtualMachineImage"
+)
+
 // ResourceName is the name identifying various resources in a ResourceList.
 type ResourceName string
 
@@ -47,6 +66,12 @@ type VirtualMachineVolumeStatus struct {
 	// Conditions indicate current conditions of VirtualMachineVolume
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the .metadata.generation which the status is based on
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ImageRevision is the revision of the image which the volume pvc is restored from
+	// +optional
+	ImageRevision int32 `json:"imageRevision,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -55,6 +80,7 @@ type VirtualMachineVolumeStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=virtualmachinevolumes,scope=Namespaced,shortName=vmv
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Current state of VirtualMachineVolume"
+// +kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.imageRevision",description="Revision of the image which the volume is restored from",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type VirtualMachineVolume struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -39,6 +39,9 @@ type VirtualMachineVolumeExportStatus struct {
 	// Conditions indicate current conditions of VirtualMachineVolumeExport
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the .metadata.generation which the status is based on
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 const (
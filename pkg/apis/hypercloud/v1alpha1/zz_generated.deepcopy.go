@@ -6,9 +6,71 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterVirtualMachineImage) DeepCopyInto(out *ClusterVirtualMachineImage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterVirtualMachineImage.
+func (in *ClusterVirtualMachineImage) DeepCopy() *ClusterVirtualMachineImage {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterVirtualMachineImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterVirtualMachineImage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterVirtualMachineImageList) DeepCopyInto(out *ClusterVirtualMachineImageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterVirtualMachineImage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterVirtualMachineImageList.
+func (in *ClusterVirtualMachineImageList) DeepCopy() *ClusterVirtualMachineImageList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterVirtualMachineImageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterVirtualMachineImageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -26,6 +88,124 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubevirtImageServiceConfig) DeepCopyInto(out *KubevirtImageServiceConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubevirtImageServiceConfig.
+func (in *KubevirtImageServiceConfig) DeepCopy() *KubevirtImageServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtImageServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KubevirtImageServiceConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubevirtImageServiceConfigImages) DeepCopyInto(out *KubevirtImageServiceConfigImages) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubevirtImageServiceConfigImages.
+func (in *KubevirtImageServiceConfigImages) DeepCopy() *KubevirtImageServiceConfigImages {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtImageServiceConfigImages)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubevirtImageServiceConfigList) DeepCopyInto(out *KubevirtImageServiceConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KubevirtImageServiceConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubevirtImageServiceConfigList.
+func (in *KubevirtImageServiceConfigList) DeepCopy() *KubevirtImageServiceConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtImageServiceConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KubevirtImageServiceConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubevirtImageServiceConfigSpec) DeepCopyInto(out *KubevirtImageServiceConfigSpec) {
+	*out = *in
+	out.Images = in.Images
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogVerbosity != nil {
+		in, out := &in.LogVerbosity, &out.LogVerbosity
+		*out = new(int32)
+		**out = **in
+	}
+	if in.JobActiveDeadlineSeconds != nil {
+		in, out := &in.JobActiveDeadlineSeconds, &out.JobActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.JobTTLSecondsAfterFinished != nil {
+		in, out := &in.JobTTLSecondsAfterFinished, &out.JobTTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubevirtImageServiceConfigSpec.
+func (in *KubevirtImageServiceConfigSpec) DeepCopy() *KubevirtImageServiceConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtImageServiceConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImage) DeepCopyInto(out *VirtualMachineImage) {
 	*out = *in
@@ -54,6 +234,104 @@ func (in *VirtualMachineImage) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageFailure) DeepCopyInto(out *VirtualMachineImageFailure) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageFailure.
+func (in *VirtualMachineImageFailure) DeepCopy() *VirtualMachineImageFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageGrant) DeepCopyInto(out *VirtualMachineImageGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageGrant.
+func (in *VirtualMachineImageGrant) DeepCopy() *VirtualMachineImageGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtualMachineImageGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageGrantList) DeepCopyInto(out *VirtualMachineImageGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VirtualMachineImageGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageGrantList.
+func (in *VirtualMachineImageGrantList) DeepCopy() *VirtualMachineImageGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtualMachineImageGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageGrantSpec) DeepCopyInto(out *VirtualMachineImageGrantSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageGrantSpec.
+func (in *VirtualMachineImageGrantSpec) DeepCopy() *VirtualMachineImageGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImageList) DeepCopyInto(out *VirtualMachineImageList) {
 	*out = *in
@@ -90,6 +368,11 @@ func (in *VirtualMachineImageList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImageName) DeepCopyInto(out *VirtualMachineImageName) {
 	*out = *in
+	if in.Revision != nil {
+		in, out := &in.Revision, &out.Revision
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -103,6 +386,69 @@ func (in *VirtualMachineImageName) DeepCopy() *VirtualMachineImageName {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageProgress) DeepCopyInto(out *VirtualMachineImageProgress) {
+	*out = *in
+	if in.EstimatedCompletionTime != nil {
+		in, out := &in.EstimatedCompletionTime, &out.EstimatedCompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageProgress.
+func (in *VirtualMachineImageProgress) DeepCopy() *VirtualMachineImageProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageRefreshPolicy) DeepCopyInto(out *VirtualMachineImageRefreshPolicy) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageRefreshPolicy.
+func (in *VirtualMachineImageRefreshPolicy) DeepCopy() *VirtualMachineImageRefreshPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageRefreshPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageRevision) DeepCopyInto(out *VirtualMachineImageRevision) {
+	*out = *in
+	if in.StorageRequest != nil {
+		in, out := &in.StorageRequest, &out.StorageRequest
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	in.CreationTime.DeepCopyInto(&out.CreationTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageRevision.
+func (in *VirtualMachineImageRevision) DeepCopy() *VirtualMachineImageRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImageSource) DeepCopyInto(out *VirtualMachineImageSource) {
 	*out = *in
@@ -111,6 +457,41 @@ func (in *VirtualMachineImageSource) DeepCopyInto(out *VirtualMachineImageSource
 		*out = new(VirtualMachineImageSourceHostPath)
 		**out = **in
 	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(VirtualMachineImageSourceS3)
+		**out = **in
+	}
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(VirtualMachineImageSourceRegistry)
+		**out = **in
+	}
+	if in.Upload != nil {
+		in, out := &in.Upload, &out.Upload
+		*out = new(VirtualMachineImageSourceUpload)
+		**out = **in
+	}
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(VirtualMachineImageSourcePVC)
+		**out = **in
+	}
+	if in.VirtualMachineVolume != nil {
+		in, out := &in.VirtualMachineVolume, &out.VirtualMachineVolume
+		*out = new(VirtualMachineImageSourceVolume)
+		**out = **in
+	}
+	if in.Checksum != nil {
+		in, out := &in.Checksum, &out.Checksum
+		*out = new(VirtualMachineImageSourceChecksum)
+		**out = **in
+	}
+	if in.HTTPOptions != nil {
+		in, out := &in.HTTPOptions, &out.HTTPOptions
+		*out = new(VirtualMachineImageSourceHTTPOptions)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -124,6 +505,43 @@ func (in *VirtualMachineImageSource) DeepCopy() *VirtualMachineImageSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceChecksum) DeepCopyInto(out *VirtualMachineImageSourceChecksum) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceChecksum.
+func (in *VirtualMachineImageSourceChecksum) DeepCopy() *VirtualMachineImageSourceChecksum {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceChecksum)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceHTTPOptions) DeepCopyInto(out *VirtualMachineImageSourceHTTPOptions) {
+	*out = *in
+	if in.ExtraHeaders != nil {
+		in, out := &in.ExtraHeaders, &out.ExtraHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceHTTPOptions.
+func (in *VirtualMachineImageSourceHTTPOptions) DeepCopy() *VirtualMachineImageSourceHTTPOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceHTTPOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImageSourceHostPath) DeepCopyInto(out *VirtualMachineImageSourceHostPath) {
 	*out = *in
@@ -140,11 +558,122 @@ func (in *VirtualMachineImageSourceHostPath) DeepCopy() *VirtualMachineImageSour
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourcePVC) DeepCopyInto(out *VirtualMachineImageSourcePVC) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourcePVC.
+func (in *VirtualMachineImageSourcePVC) DeepCopy() *VirtualMachineImageSourcePVC {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourcePVC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceRegistry) DeepCopyInto(out *VirtualMachineImageSourceRegistry) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceRegistry.
+func (in *VirtualMachineImageSourceRegistry) DeepCopy() *VirtualMachineImageSourceRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceS3) DeepCopyInto(out *VirtualMachineImageSourceS3) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceS3.
+func (in *VirtualMachineImageSourceS3) DeepCopy() *VirtualMachineImageSourceS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceUpload) DeepCopyInto(out *VirtualMachineImageSourceUpload) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceUpload.
+func (in *VirtualMachineImageSourceUpload) DeepCopy() *VirtualMachineImageSourceUpload {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceUpload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceVersion) DeepCopyInto(out *VirtualMachineImageSourceVersion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceVersion.
+func (in *VirtualMachineImageSourceVersion) DeepCopy() *VirtualMachineImageSourceVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineImageSourceVolume) DeepCopyInto(out *VirtualMachineImageSourceVolume) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineImageSourceVolume.
+func (in *VirtualMachineImageSourceVolume) DeepCopy() *VirtualMachineImageSourceVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineImageSourceVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineImageSpec) DeepCopyInto(out *VirtualMachineImageSpec) {
 	*out = *in
 	in.Source.DeepCopyInto(&out.Source)
 	in.PVC.DeepCopyInto(&out.PVC)
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RefreshPolicy != nil {
+		in, out := &in.RefreshPolicy, &out.RefreshPolicy
+		*out = new(VirtualMachineImageRefreshPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -168,6 +697,47 @@ func (in *VirtualMachineImageStatus) DeepCopyInto(out *VirtualMachineImageStatus
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.VirtualSize != nil {
+		in, out := &in.VirtualSize, &out.VirtualSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageRequest != nil {
+		in, out := &in.StorageRequest, &out.StorageRequest
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = new(VirtualMachineImageProgress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastFailure != nil {
+		in, out := &in.LastFailure, &out.LastFailure
+		*out = new(VirtualMachineImageFailure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DependentVolumes != nil {
+		in, out := &in.DependentVolumes, &out.DependentVolumes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SourceVersion != nil {
+		in, out := &in.SourceVersion, &out.SourceVersion
+		*out = new(VirtualMachineImageSourceVersion)
+		**out = **in
+	}
+	if in.LastRefreshTime != nil {
+		in, out := &in.LastRefreshTime, &out.LastRefreshTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]VirtualMachineImageRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -421,7 +991,7 @@ func (in *VirtualMachineVolumeSource) DeepCopy() *VirtualMachineVolumeSource {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineVolumeSpec) DeepCopyInto(out *VirtualMachineVolumeSpec) {
 	*out = *in
-	out.VirtualMachineImage = in.VirtualMachineImage
+	in.VirtualMachineImage.DeepCopyInto(&out.VirtualMachineImage)
 	if in.Capacity != nil {
 		in, out := &in.Capacity, &out.Capacity
 		*out = make(v1.ResourceList, len(*in))
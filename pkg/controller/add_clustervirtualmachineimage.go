@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"kubevirt-image-service/pkg/controller/clustervirtualmachineimage"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, clustervirtualmachineimage.Add)
+}
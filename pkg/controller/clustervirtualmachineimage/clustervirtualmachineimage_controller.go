@@ -0,0 +1,203 @@
+package clustervirtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Add creates a new ClusterVirtualMachineImage Controller and adds it to the Manager
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileClusterVirtualMachineImage{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("clustervirtualmachineimage-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &hc.ClusterVirtualMachineImage{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	// The status of the VirtualMachineImage which imports the cvmi is copied to the cvmi
+	if err := c.Watch(&source.Kind{Type: &hc.VirtualMachineImage{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.ClusterVirtualMachineImage{}}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileClusterVirtualMachineImage implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileClusterVirtualMachineImage{}
+
+// ReconcileClusterVirtualMachineImage reconciles a ClusterVirtualMachineImage object
+type ReconcileClusterVirtualMachineImage struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+	cvmi   *hc.ClusterVirtualMachineImage
+	config hc.KubevirtImageServiceConfigSpec
+}
+
+// Reconcile imports the ClusterVirtualMachineImage into the VirtualMachineImage of the same name in the cluster image namespace,
+// and copies the status of the VirtualMachineImage to the ClusterVirtualMachineImage
+func (r *ReconcileClusterVirtualMachineImage) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	klog.Infof("Start sync ClusterVirtualMachineImage %s", request.Name)
+	defer func() {
+		klog.Infof("End sync ClusterVirtualMachineImage %s", request.Name)
+	}()
+
+	cachedCvmi := &hc.ClusterVirtualMachineImage{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, cachedCvmi); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil // Deleted CVMI. The VirtualMachineImage is deleted by the garbage collector
+		}
+		return reconcile.Result{}, err
+	}
+	r.cvmi = cachedCvmi.DeepCopy()
+	config, err := util.GetConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.config = config
+
+	if err := r.syncImage(); err != nil {
+		r.cvmi.Status.Conditions = util.SetConditionByType(r.cvmi.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionFalse, hc.ReasonFailed, err.Error(),
+			r.cvmi.Generation)
+		r.cvmi.Status.Conditions = util.SetStateConditions(r.cvmi.Status.Conditions, false, true, hc.ReasonFailed, err.Error(), r.cvmi.Generation)
+		r.cvmi.Status.State = hc.VirtualMachineImageStateError
+		r.cvmi.Status.ObservedGeneration = r.cvmi.Generation
+		if err2 := r.client.Status().Update(context.TODO(), r.cvmi); err2 != nil {
+			return reconcile.Result{}, err2
+		}
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getImageSpec returns the spec of the cvmi for its VirtualMachineImage. The fields which the cvmi doesn't set keep the defaults of the image,
+// which the webhook sets when the image is created
+func getImageSpec(cvmi *hc.ClusterVirtualMachineImage, vmi *hc.VirtualMachineImage) hc.VirtualMachineImageSpec {
+	spec := *cvmi.Spec.DeepCopy()
+	if spec.PVC.StorageClassName == nil {
+		spec.PVC.StorageClassName = vmi.Spec.PVC.StorageClassName
+	}
+	if spec.SnapshotClassName == "" {
+		spec.SnapshotClassName = vmi.Spec.SnapshotClassName
+	}
+	if len(spec.PVC.AccessModes) == 0 {
+		spec.PVC.AccessModes = vmi.Spec.PVC.AccessModes
+	}
+	if spec.PVC.VolumeMode == nil {
+		spec.PVC.VolumeMode = vmi.Spec.PVC.VolumeMode
+	}
+	return spec
+}
+
+// getImageStatus returns the status of the VirtualMachineImage for the cvmi. The generations are of the cvmi, which the image has observed
+// when it has observed its own generation with the spec of the cvmi. Otherwise the cvmi keeps the generation it has observed before
+func getImageStatus(cvmi *hc.ClusterVirtualMachineImage, vmi *hc.VirtualMachineImage) hc.VirtualMachineImageStatus {
+	status := *vmi.Status.DeepCopy()
+	status.ObservedGeneration = cvmi.Status.ObservedGeneration
+	if vmi.Status.ObservedGeneration == vmi.Generation && equality.Semantic.DeepEqual(getImageSpec(cvmi, vmi), vmi.Spec) {
+		status.ObservedGeneration = cvmi.Generation
+	}
+	for i := range status.Conditions {
+		status.Conditions[i].ObservedGeneration = status.ObservedGeneration
+	}
+	return status
+}
+
+// syncImage creates the VirtualMachineImage which imports the cvmi, and copies its status to the cvmi
+func (r *ReconcileClusterVirtualMachineImage) syncImage() error {
+	vmi := &hc.VirtualMachineImage{}
+	err := r.client.Get(context.TODO(), GetImageNamespacedName(r.cvmi.Name, r.config), vmi)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if errors.IsNotFound(err) {
+		// 클러스터 이미지를 임포트할 이미지가 없으니 만든다
+		klog.Infof("Create a new VirtualMachineImage for cvmi %s", r.cvmi.Name)
+		newVmi, err := r.newImage()
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newVmi); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if !metav1.IsControlledBy(vmi, r.cvmi) {
+		return goerrors.New("VirtualMachineImage " + vmi.Namespace + "/" + vmi.Name + " already exists and is not owned by the ClusterVirtualMachineImage")
+	}
+
+	if spec := getImageSpec(r.cvmi, vmi); !equality.Semantic.DeepEqual(spec, vmi.Spec) {
+		// 클러스터 이미지의 수정된 스펙을 이미지에 반영한다. 소스가 수정됐으면 이미지는 새 리비전으로 다시 임포트한다
+		klog.Infof("Update the spec of VirtualMachineImage for cvmi %s", r.cvmi.Name)
+		vmi.Spec = spec
+		if err := r.client.Update(context.TODO(), vmi); err != nil {
+			return err
+		}
+	}
+	if _, found := r.cvmi.Annotations[img.RetryAnnotation]; found {
+		// 재시도 요청은 이미지에 전달하고 클러스터 이미지에서는 삭제한다
+		if err := r.client.Patch(context.TODO(), vmi, client.RawPatch(types.MergePatchType,
+			[]byte(`{"metadata":{"annotations":{"`+img.RetryAnnotation+`":"true"}}}`))); err != nil {
+			return err
+		}
+		if err := r.client.Patch(context.TODO(), r.cvmi, client.RawPatch(types.MergePatchType,
+			[]byte(`{"metadata":{"annotations":{"`+img.RetryAnnotation+`":null}}}`))); err != nil {
+			return err
+		}
+	}
+	if status := getImageStatus(r.cvmi, vmi); !equality.Semantic.DeepEqual(r.cvmi.Status, status) {
+		// 이미지의 상태를 클러스터 이미지에 복사한다
+		r.cvmi.Status = status
+		if err := r.client.Status().Update(context.TODO(), r.cvmi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newImage returns the VirtualMachineImage which imports the cvmi in the cluster image namespace
+func (r *ReconcileClusterVirtualMachineImage) newImage() (*hc.VirtualMachineImage, error) {
+	namespacedName := GetImageNamespacedName(r.cvmi.Name, r.config)
+	vmi := &hc.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namespacedName.Name,
+			Namespace: namespacedName.Namespace,
+		},
+		Spec: *r.cvmi.Spec.DeepCopy(),
+	}
+	if err := controllerutil.SetControllerReference(r.cvmi, vmi, r.scheme); err != nil {
+		return nil, err
+	}
+	return vmi, nil
+}
+
+// GetImageNamespacedName returns the namespaced name of the VirtualMachineImage which imports the ClusterVirtualMachineImage of cvmiName
+func GetImageNamespacedName(cvmiName string, config hc.KubevirtImageServiceConfigSpec) types.NamespacedName {
+	return types.NamespacedName{Namespace: util.GetClusterImageNamespace(config), Name: cvmiName}
+}
@@ -0,0 +1,230 @@
+package clustervirtualmachineimage
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	testCvmiName = "mycvmi"
+)
+
+var testCvmiNamespacedName = types.NamespacedName{Name: testCvmiName}
+
+// 번호		vmi			retry annotation		vmi state
+// 1		X
+// 2		O(other)
+// 3		O			X						Available
+// 4		O			O
+// 5		O(old spec)
+// 6		O(defaulted)
+var _ = Describe("Reconcile", func() {
+	Context("1. with no vmi", func() {
+		r := createFakeReconcileCvmi()
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create vmi in the cluster image namespace", func() {
+			vmi, err := getTestImage(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Namespace).Should(Equal(util.DefaultOperatorNamespace))
+			Expect(vmi.Spec).Should(Equal(r.cvmi.Spec))
+		})
+		It("Should be owned by the cvmi", func() {
+			vmi, err := getTestImage(r)
+			Expect(err).Should(BeNil())
+			Expect(metav1.IsControlledBy(vmi, r.cvmi)).Should(BeTrue())
+		})
+	})
+
+	Context("2. with vmi not owned by the cvmi", func() {
+		r := createFakeReconcileCvmi(&hc.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Name: testCvmiName, Namespace: util.DefaultOperatorNamespace},
+		})
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should update state to error", func() {
+			cvmi := &hc.ClusterVirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), testCvmiNamespacedName, cvmi)).Should(BeNil())
+			Expect(cvmi.Status.State).Should(Equal(hc.VirtualMachineImageStateError))
+		})
+	})
+
+	Context("3. with available vmi", func() {
+		vmi := newTestOwnedImage()
+		vmi.Status.State = hc.VirtualMachineImageStateAvailable
+		vmi.Status.Conditions = util.SetConditionByType(vmi.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", vmi.Generation)
+		r := createFakeReconcileCvmi(vmi)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should copy the status of the vmi", func() {
+			cvmi := &hc.ClusterVirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), testCvmiNamespacedName, cvmi)).Should(BeNil())
+			Expect(cvmi.Status.State).Should(Equal(hc.VirtualMachineImageStateAvailable))
+			found, cond := util.GetConditionByType(cvmi.Status.Conditions, hc.ConditionReadyToUse)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Status).Should(Equal(corev1.ConditionTrue))
+		})
+	})
+
+	Context("4. with retry annotation", func() {
+		r := createFakeReconcileCvmi(newTestOwnedImage())
+		r.cvmi.Annotations = map[string]string{img.RetryAnnotation: "true"}
+		if err := r.client.Update(context.TODO(), r.cvmi); err != nil {
+			panic(err)
+		}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should forward the retry annotation to the vmi", func() {
+			vmi, err := getTestImage(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Annotations).Should(HaveKeyWithValue(img.RetryAnnotation, "true"))
+		})
+		It("Should remove the retry annotation from the cvmi", func() {
+			cvmi := &hc.ClusterVirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), testCvmiNamespacedName, cvmi)).Should(BeNil())
+			Expect(cvmi.Annotations).ShouldNot(HaveKey(img.RetryAnnotation))
+		})
+	})
+
+	Context("5. with vmi of the old spec", func() {
+		r := createFakeReconcileCvmi(newTestOwnedImage())
+		r.cvmi.Spec.Source.HTTP = "https://kr.tmaxsoft.com/updated.img"
+		if err := r.client.Update(context.TODO(), r.cvmi); err != nil {
+			panic(err)
+		}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the spec of the vmi", func() {
+			vmi, err := getTestImage(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Spec.Source.HTTP).Should(Equal("https://kr.tmaxsoft.com/updated.img"))
+		})
+	})
+
+	Context("6. with vmi of the defaults which the cvmi doesn't set", func() {
+		vmi := newTestOwnedImage()
+		storageClassName := "rook-ceph-block"
+		vmi.Spec.PVC.StorageClassName = &storageClassName
+		vmi.Spec.SnapshotClassName = "csi-rbdplugin-snapclass"
+		r := createFakeReconcileCvmi(vmi)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testCvmiNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should keep the defaults of the vmi", func() {
+			vmi, err := getTestImage(r)
+			Expect(err).Should(BeNil())
+			Expect(*vmi.Spec.PVC.StorageClassName).Should(Equal("rook-ceph-block"))
+			Expect(vmi.Spec.SnapshotClassName).Should(Equal("csi-rbdplugin-snapclass"))
+		})
+	})
+})
+
+var _ = Describe("getImageStatus", func() {
+	Context("1. with vmi which has observed its generation", func() {
+		cvmi, vmi := newTestCvmi(), newTestOwnedImage()
+		cvmi.Generation, cvmi.Status.ObservedGeneration = 4, 3
+		vmi.Generation, vmi.Status.ObservedGeneration = 7, 7
+		vmi.Status.Conditions = util.SetConditionByType(vmi.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", vmi.Generation)
+		status := getImageStatus(cvmi, vmi)
+
+		It("Should set the generation of the cvmi", func() {
+			Expect(status.ObservedGeneration).Should(Equal(int64(4)))
+			Expect(status.Conditions[0].ObservedGeneration).Should(Equal(int64(4)))
+		})
+	})
+
+	Context("2. with vmi which has not observed its generation", func() {
+		cvmi, vmi := newTestCvmi(), newTestOwnedImage()
+		cvmi.Generation, cvmi.Status.ObservedGeneration = 4, 3
+		vmi.Generation, vmi.Status.ObservedGeneration = 7, 6
+		vmi.Status.Conditions = util.SetConditionByType(vmi.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", 6)
+		status := getImageStatus(cvmi, vmi)
+
+		It("Should keep the generation which the cvmi has observed", func() {
+			Expect(status.ObservedGeneration).Should(Equal(int64(3)))
+			Expect(status.Conditions[0].ObservedGeneration).Should(Equal(int64(3)))
+		})
+	})
+})
+
+func createFakeReconcileCvmi(objects ...runtime.Object) *ReconcileClusterVirtualMachineImage {
+	cvmi := newTestCvmi()
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, cvmi)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileClusterVirtualMachineImage{client: client, scheme: scheme, cvmi: cvmi}
+}
+
+func newTestCvmi() *hc.ClusterVirtualMachineImage {
+	return &hc.ClusterVirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: testCvmiName,
+		},
+		Spec: hc.VirtualMachineImageSpec{
+			Source: hc.VirtualMachineImageSource{
+				HTTP: "https://kr.tmaxsoft.com/main.do",
+			},
+			PVC: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{
+					corev1.ReadWriteOnce,
+				},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("3Gi"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// newTestOwnedImage returns the vmi which imports the test cvmi
+func newTestOwnedImage() *hc.VirtualMachineImage {
+	vmi := &hc.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{Name: testCvmiName, Namespace: util.DefaultOperatorNamespace},
+		Spec:       newTestCvmi().Spec,
+	}
+	_, scheme, err := util.CreateFakeClientAndScheme()
+	if err != nil {
+		panic(err)
+	}
+	if err := controllerutil.SetControllerReference(newTestCvmi(), vmi, scheme); err != nil {
+		panic(err)
+	}
+	return vmi
+}
+
+func getTestImage(r *ReconcileClusterVirtualMachineImage) (*hc.VirtualMachineImage, error) {
+	vmi := &hc.VirtualMachineImage{}
+	err := r.client.Get(context.TODO(), GetImageNamespacedName(testCvmiName, r.config), vmi)
+	return vmi, err
+}
@@ -0,0 +1,18 @@
+package clustervirtualmachineimage
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/operator-sdk/pkg/log/zap"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"testing"
+)
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.LoggerTo(GinkgoWriter))
+})
+
+func TestClusterVirtualMachineImage(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ClusterVirtualMachineImage Suite")
+}
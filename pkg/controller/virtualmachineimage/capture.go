@@ -0,0 +1,147 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// syncCapture snapshots the pvc of the VirtualMachineVolume to restore the image pvc from it.
+// The capture snapshot is deleted after the image pvc is restored, so the image doesn't depend on the volume
+func (r *ReconcileVirtualMachineImage) syncCapture() error {
+	src, err := r.getSource()
+	if err != nil {
+		return err
+	} else if src != SourceVolume {
+		return nil
+	}
+
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := r.getCaptureSnapshot()
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	existsSnapshot := err == nil
+
+	if imported && existsSnapshot {
+		// 이미지 pvc가 복원됐으니 볼륨과 독립되도록 캡처 스냅샷을 삭제한다
+		klog.Infof("Delete capture snapshot because restoring completed vmi: %s", r.vmi.Name)
+		if err := r.client.Delete(context.TODO(), snapshot); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotDeleted, "Deleted capture snapshot %s, the image pvc is restored", snapshot.Name)
+	} else if !found && !existsSnapshot {
+		// 이미지 pvc를 복원하기 위해 볼륨의 스냅샷을 만든다
+		klog.Infof("Create capture snapshot of volume %s for vmi %s", r.vmi.Spec.Source.VirtualMachineVolume.Name, r.vmi.Name)
+		if err := r.validateCaptureVolume(); err != nil {
+			return err
+		}
+		if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateCreating, corev1.ConditionFalse, hc.ReasonCreating, "VMI is in creating"); err != nil {
+			return err
+		}
+		newSnapshot, err := newCaptureSnapshot(r.vmi, r.scheme)
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newSnapshot); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotCreated, "Created capture snapshot %s of volume %s",
+			newSnapshot.Name, r.vmi.Spec.Source.VirtualMachineVolume.Name)
+	} else if !imported && existsSnapshot && snapshot.Status != nil && snapshot.Status.Error != nil {
+		return goerrors.New("Capture snapshot is error for vmi " + r.vmi.Name)
+	}
+	return nil
+}
+
+func (r *ReconcileVirtualMachineImage) validateCaptureVolume() error {
+	volume := &hc.VirtualMachineVolume{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Spec.Source.VirtualMachineVolume.Name}, volume); err != nil {
+		if errors.IsNotFound(err) {
+			return goerrors.New("VirtualMachineVolume " + r.vmi.Spec.Source.VirtualMachineVolume.Name + " is not found")
+		}
+		return err
+	}
+	imagePvcSize := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	volumePvcSize := volume.Spec.Capacity[corev1.ResourceStorage]
+	if imagePvcSize.Cmp(volumePvcSize) < 0 {
+		return goerrors.New("storage request in pvc should be greater than or equal to VirtualMachineVolume capacity")
+	}
+	// 스냅샷은 같은 볼륨 모드로만 복원할 수 있다
+	volumePvc := &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: getVolumePvcNameFromVmvName(volume.Name)}, volumePvc); err != nil {
+		if errors.IsNotFound(err) {
+			return goerrors.New("pvc of VirtualMachineVolume " + volume.Name + " is not found")
+		}
+		return err
+	}
+	if util.GetVolumeMode(volumePvc.Spec.VolumeMode) != util.GetVolumeMode(r.vmi.Spec.PVC.VolumeMode) {
+		return goerrors.New("VolumeMode in pvc should be same as the pvc of VirtualMachineVolume, " + string(util.GetVolumeMode(volumePvc.Spec.VolumeMode)))
+	}
+	return nil
+}
+
+func (r *ReconcileVirtualMachineImage) getCaptureSnapshot() (*snapshotv1beta1.VolumeSnapshot, error) {
+	snapshot := &snapshotv1beta1.VolumeSnapshot{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetCaptureSnapshotNameFromVmiName(r.vmi.Name)}, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// GetCaptureSnapshotNameFromVmiName returns the name of the snapshot of the VirtualMachineVolume captured for vmiName
+func GetCaptureSnapshotNameFromVmiName(vmiName string) string {
+	return vmiName + "-image-capture-snapshot"
+}
+
+// getVolumePvcNameFromVmvName returns the pvc name of the VirtualMachineVolume.
+// It must be same as virtualmachinevolume.GetVolumePvcName, which can't be imported because of the import cycle
+func getVolumePvcNameFromVmvName(vmvName string) string {
+	return vmvName + "-vmv-pvc"
+}
+
+func newCaptureSnapshot(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*snapshotv1beta1.VolumeSnapshot, error) {
+	pvcName := getVolumePvcNameFromVmvName(vmi.Spec.Source.VirtualMachineVolume.Name)
+	snapshot := &snapshotv1beta1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetCaptureSnapshotNameFromVmiName(vmi.Name),
+			Namespace: vmi.Namespace,
+		},
+		Spec: snapshotv1beta1.VolumeSnapshotSpec{
+			Source: snapshotv1beta1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &pvcName,
+			},
+			VolumeSnapshotClassName: &vmi.Spec.SnapshotClassName,
+		},
+	}
+	if err := controllerutil.SetControllerReference(vmi, snapshot, scheme); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func setSnapshotDataSource(pvc *corev1.PersistentVolumeClaim, snapshotName string) {
+	apiGroup := snapshotv1beta1.GroupName
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshotName,
+	}
+}
+
+func isSnapshotReadyToUse(snapshot *snapshotv1beta1.VolumeSnapshot) bool {
+	return snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+}
@@ -0,0 +1,195 @@
+package virtualmachineimage
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+const (
+	testVolumeName = "testvolume"
+)
+
+// 번호		pvc		imported		volume			captureSnapshot
+// 1		X						O				X
+// 2		X						X				X
+// 3		X						O(bigger)		X
+// 4		O		no								error
+// 5		O		yes								O
+// 6		X						O(Block)		X
+var _ = Describe("syncCapture", func() {
+	Context("1. with no pvc, volume, no capture snapshot", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestVolume("3Gi"), newTestVolumePvc(corev1.PersistentVolumeFilesystem))
+		err := r.syncCapture()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create capture snapshot of the volume pvc", func() {
+			snapshot, err := r.getCaptureSnapshot()
+			Expect(err).Should(BeNil())
+			Expect(*snapshot.Spec.Source.PersistentVolumeClaimName).Should(Equal(testVolumeName + "-vmv-pvc"))
+		})
+		It("Should update state to creating", func() {
+			vmi := &hc.VirtualMachineImage{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateCreating))
+		})
+	})
+
+	Context("2. with no pvc, no volume, no capture snapshot", func() {
+		r := createFakeReconcileVmiWithVolumeSource()
+		err := r.syncCapture()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should not create capture snapshot", func() {
+			_, err := r.getCaptureSnapshot()
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("3. with no pvc, volume bigger than image, no capture snapshot", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestVolume("5Gi"))
+		err := r.syncCapture()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("4. with pvc, imported=no, capture snapshot with error", func() {
+		snapshot := newTestCaptureSnapshot(false)
+		snapshot.Status.Error = &snapshotv1beta1.VolumeSnapshotError{}
+		r := createFakeReconcileVmiWithVolumeSource(newTestImporterPvc("no"), snapshot)
+		err := r.syncCapture()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("5. with pvc, imported=yes, capture snapshot", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestImporterPvc("yes"), newTestCaptureSnapshot(true))
+		err := r.syncCapture()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete capture snapshot", func() {
+			_, err := r.getCaptureSnapshot()
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("6. with no pvc, volume with the other volume mode, no capture snapshot", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestVolume("3Gi"), newTestVolumePvc(corev1.PersistentVolumeBlock))
+		err := r.syncCapture()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("VolumeMode"))
+		})
+		It("Should not create capture snapshot", func() {
+			_, err := r.getCaptureSnapshot()
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+// 번호		captureSnapshot
+// 1		readyToUse
+// 2		Not ReadyToUse
+var _ = Describe("syncPvc with virtualMachineVolume source", func() {
+	Context("1. with capture snapshot which is ready to use", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestCaptureSnapshot(true))
+		err := r.syncPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create a pvc restored from the capture snapshot", func() {
+			pvc, err := r.getPvc(r.vmi)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Spec.DataSource).ShouldNot(BeNil())
+			Expect(pvc.Spec.DataSource.Kind).Should(Equal("VolumeSnapshot"))
+			Expect(pvc.Spec.DataSource.Name).Should(Equal(GetCaptureSnapshotNameFromVmiName(testVmiName)))
+		})
+	})
+
+	Context("2. with capture snapshot which is not ready to use", func() {
+		r := createFakeReconcileVmiWithVolumeSource(newTestCaptureSnapshot(false))
+		err := r.syncPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create a pvc", func() {
+			_, err := r.getPvc(r.vmi)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+func createFakeReconcileVmiWithVolumeSource(objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	r := createFakeReconcileVmi(objects...)
+	r.vmi.Spec.Source = hc.VirtualMachineImageSource{VirtualMachineVolume: &hc.VirtualMachineImageSourceVolume{Name: testVolumeName}}
+	return r
+}
+
+func newTestVolume(capacity string) *hc.VirtualMachineVolume {
+	return &hc.VirtualMachineVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName,
+			Namespace: testVmiNs,
+		},
+		Spec: hc.VirtualMachineVolumeSpec{
+			VirtualMachineImage: hc.VirtualMachineImageName{Name: "otherimage"},
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(capacity),
+			},
+		},
+	}
+}
+
+func newTestVolumePvc(volumeMode corev1.PersistentVolumeMode) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-vmv-pvc",
+			Namespace: testVmiNs,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeMode: &volumeMode,
+		},
+	}
+}
+
+func newTestCaptureSnapshot(readyToUse bool) *snapshotv1beta1.VolumeSnapshot {
+	pvcName := testVolumeName + "-vmv-pvc"
+	snapshotClassName := testSnapshotClassName
+	return &snapshotv1beta1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetCaptureSnapshotNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Spec: snapshotv1beta1.VolumeSnapshotSpec{
+			Source: snapshotv1beta1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &pvcName,
+			},
+			VolumeSnapshotClassName: &snapshotClassName,
+		},
+		Status: &snapshotv1beta1.VolumeSnapshotStatus{
+			ReadyToUse: &readyToUse,
+		},
+	}
+}
@@ -0,0 +1,248 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"net/url"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+)
+
+const (
+	// ChecksumSumsURL provides a constant to capture our env variable "CHECKSUM_SUMS_URL"
+	ChecksumSumsURL = "CHECKSUM_SUMS_URL"
+	// ChecksumFileName provides a constant to capture our env variable "CHECKSUM_FILE_NAME"
+	ChecksumFileName = "CHECKSUM_FILE_NAME"
+	// ChecksumAlgorithmSHA256 is the sha256 checksum algorithm
+	ChecksumAlgorithmSHA256 = "sha256"
+	// ChecksumAlgorithmSHA512 is the sha512 checksum algorithm
+	ChecksumAlgorithmSHA512 = "sha512"
+	// ChecksumAlgorithmMD5 is the md5 checksum algorithm
+	ChecksumAlgorithmMD5 = "md5"
+	// ReasonChecksumMismatch is the reason of ReadyToUse condition when the digest of the source image doesn't match the checksum
+	ReasonChecksumMismatch = "ChecksumMismatch"
+	checksumDigestKey      = "digest"
+	checksumExpectedKey    = "expected"
+)
+
+// syncChecksum verifies the digest of the source image before importing it and records the verified digest in the status
+func (r *ReconcileVirtualMachineImage) syncChecksum() error {
+	if r.vmi.Spec.Source.Checksum == nil {
+		return nil
+	}
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	} else if !found {
+		return nil
+	}
+
+	checksumJob := &batchv1.Job{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetChecksumJobNameFromVmiName(r.vmi.Name)}, checksumJob)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	existsChecksumJob := err == nil
+
+	if imported && existsChecksumJob {
+		// 임포팅이 완료됐으니 체크섬잡을 삭제한다
+		klog.Infof("Delete checksum job because importing completed vmi: %s", r.vmi.Name)
+		if err := util.DeleteJob(r.client, checksumJob); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobDeleted, "Deleted checksum job %s", checksumJob.Name)
+	} else if !imported && existsChecksumJob && util.IsJobCompleted(checksumJob) && r.vmi.Status.Digest == "" {
+		// 체크섬 계산이 끝났으니 비교하고 검증된 다이제스트를 기록한다
+		result, err := getJobResult(r.client, checksumJob)
+		if err != nil {
+			return err
+		}
+		digest, err := r.verifyChecksum(result)
+		if err != nil {
+			return err
+		}
+		klog.Infof("Checksum is verified for vmi %s: %s", r.vmi.Name, digest)
+		r.vmi.Status.Digest = digest
+		if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobCompleted, "Checksum job %s verified digest %s", checksumJob.Name, digest)
+	} else if !imported && existsChecksumJob && !util.IsJobCompleted(checksumJob) {
+		// 체크섬잡이 실행 중이니 실패한 파드를 기록한다. 재시도 횟수를 넘겨 잡이 실패하면 에러를 반환한다
+		return r.syncJobFailures(checksumJob)
+	} else if !imported && !existsChecksumJob && r.vmi.Status.Digest == "" {
+		// 임포팅 전에 소스 이미지를 검증해야 하므로 체크섬잡을 만든다. 잡이 실패했으면 재시도 요청 전까지 만들지 않는다
+		if err := r.getJobFailedError(); err != nil {
+			return err
+		}
+		klog.Infof("Create checksum job for vmi %s", r.vmi.Name)
+		newJob, err := r.newChecksumJob()
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newJob); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobStarted, "Started checksum job %s", newJob.Name)
+	}
+	return nil
+}
+
+// isChecksumVerified returns true if the checksum is not set or the digest of the source image is verified
+func (r *ReconcileVirtualMachineImage) isChecksumVerified() bool {
+	return r.vmi.Spec.Source.Checksum == nil || r.vmi.Status.Digest != ""
+}
+
+// verifyChecksum compares the result of the checksum pod with the checksum and returns the verified digest
+func (r *ReconcileVirtualMachineImage) verifyChecksum(result string) (string, error) {
+	algorithm, expected := getChecksumAlgorithmAndDigest(r.vmi.Spec.Source.Checksum)
+	values := parseTerminationMessage(result)
+	if r.vmi.Spec.Source.Checksum.SHA256SumsURL != "" {
+		expected = values[checksumExpectedKey]
+		if expected == "" {
+			return "", &vmiError{reason: ReasonChecksumMismatch, message: "sha256 digest of the source image is not found in " + r.vmi.Spec.Source.Checksum.SHA256SumsURL}
+		}
+	}
+	digest := values[checksumDigestKey]
+	if !strings.EqualFold(digest, expected) {
+		return "", &vmiError{reason: ReasonChecksumMismatch, message: fmt.Sprintf("%s digest of the source image is %s, but expected %s", algorithm, digest, expected)}
+	}
+	return algorithm + ":" + strings.ToLower(digest), nil
+}
+
+func (r *ReconcileVirtualMachineImage) validateChecksum() error {
+	checksum := r.vmi.Spec.Source.Checksum
+	if checksum == nil {
+		return nil
+	}
+	count := 0
+	for _, v := range []string{checksum.SHA256, checksum.SHA512, checksum.MD5, checksum.SHA256SumsURL} {
+		if v != "" {
+			count++
+		}
+	}
+	if count != 1 {
+		return goerrors.New("only one of sha256, sha512, md5 and sha256SumsURL must be set in checksum")
+	}
+	if src, err := r.getSource(); err != nil {
+		return err
+	} else if src != SourceHTTP && src != SourceS3 && src != SourceHostPath {
+		return goerrors.New("checksum is only supported for http, s3 and hostPath sources")
+	}
+	return nil
+}
+
+func getChecksumAlgorithmAndDigest(checksum *hc.VirtualMachineImageSourceChecksum) (algorithm, digest string) {
+	if checksum.SHA512 != "" {
+		return ChecksumAlgorithmSHA512, checksum.SHA512
+	} else if checksum.MD5 != "" {
+		return ChecksumAlgorithmMD5, checksum.MD5
+	}
+	return ChecksumAlgorithmSHA256, checksum.SHA256
+}
+
+// GetChecksumJobNameFromVmiName returns the name of the checksum job from vmiName
+func GetChecksumJobNameFromVmiName(vmiName string) string {
+	return vmiName + "-image-checksum"
+}
+
+// newChecksumJob returns the job which runs the checksum pod. The failed pod is retried up to the max retries
+func (r *ReconcileVirtualMachineImage) newChecksumJob() (*batchv1.Job, error) {
+	pod, err := r.newChecksumPod()
+	if err != nil {
+		return nil, err
+	}
+	job := util.NewJob(pod, r.getMaxRetries(), r.config)
+	if err := controllerutil.SetControllerReference(r.vmi, job, r.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newChecksumPod returns the template of the checksum job which writes the digest of the source image to the termination message
+func (r *ReconcileVirtualMachineImage) newChecksumPod() (*corev1.Pod, error) {
+	algorithm, _ := getChecksumAlgorithmAndDigest(r.vmi.Spec.Source.Checksum)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetChecksumJobNameFromVmiName(r.vmi.Name),
+			Namespace: r.vmi.Namespace,
+			Labels:    map[string]string{VmiNameLabel: r.vmi.Name},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:                     "checksum",
+					Image:                    util.GetImageOrDefault(r.config.Images.Fetcher, FetcherImage),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser: &[]int64{0}[0],
+			},
+		},
+	}
+
+	src, err := r.getSource()
+	if err != nil {
+		return nil, err
+	}
+	// The source image is read by readSource command and piped into {algorithm}sum
+	var readSource, sourceURL string
+	if src == SourceHTTP || src == SourceS3 {
+		readSource = r.setCurlReadSource(pod, &pod.Spec.Containers[0], src)
+		sourceURL = r.getSourceURL(src)
+	} else if src == SourceHostPath {
+		sourceURL = SourceVolumeMountPath + "/disk.img"
+		readSource = `cat "$` + SourceURLVar + `"`
+		pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: SourceURLVar, Value: sourceURL}}
+		pod.Spec.NodeName = r.vmi.Spec.Source.HostPath.NodeName
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: SourceVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: r.vmi.Spec.Source.HostPath.Path,
+					}},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true}}
+	} else {
+		return nil, goerrors.New("checksum is only supported for http, s3 and hostPath sources")
+	}
+
+	script := "set -eo pipefail\n" + CurlScript +
+		checksumDigestKey + "=$(" + readSource + " | " + algorithm + "sum | cut -d' ' -f1)\n" +
+		"echo " + checksumDigestKey + "=$" + checksumDigestKey + " > /dev/termination-log\n"
+	if sumsURL := r.vmi.Spec.Source.Checksum.SHA256SumsURL; sumsURL != "" {
+		// The expected digest is the line of the source file name in the SHA256SUMS file, "{digest} {file name}" or "{digest} *{file name}".
+		// The SHA256SUMS file is fetched with the same TLS options and credentials as the source image
+		script += checksumExpectedKey + `=$(` + r.getCurlSourceCommand(src, ChecksumSumsURL) + ` | awk -v f="$` + ChecksumFileName + `" '$2 == f || $2 == "*" f {print $1; exit}')` + "\n" +
+			"echo " + checksumExpectedKey + "=$" + checksumExpectedKey + " >> /dev/termination-log\n"
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env,
+			corev1.EnvVar{Name: ChecksumSumsURL, Value: sumsURL},
+			corev1.EnvVar{Name: ChecksumFileName, Value: getSourceFileName(sourceURL)})
+	}
+	pod.Spec.Containers[0].Command = []string{"/bin/sh", "-c", script}
+	util.ApplyConfigToPod(pod, r.config)
+	return pod, nil
+}
+
+// getSourceFileName returns the file name of the source url or path
+func getSourceFileName(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(source)
+}
@@ -0,0 +1,186 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+const (
+	testSHA256 = "b3c1ad1d9ab7a1e8ae3bec2d5dba1cb2f1ac4f96e9e8d1a23b0a1ba5ce4e6f3a"
+)
+
+// 번호		checksum		pvc		imported		checksumJob		digest
+// 1		X				O		no
+// 2		O				O		no				X
+// 3		O				O		no				Complete		match
+// 4		O				O		no				Complete		mismatch
+// 5		O(sums url)		O		no				Complete		match
+// 6		O				O		yes				O
+// 7		O(sums url, s3)	O		no				X
+var _ = Describe("syncChecksum", func() {
+	Context("1. without checksum", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create checksum job", func() {
+			_, err := getTestJob(r, GetChecksumJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should be verified", func() {
+			Expect(r.isChecksumVerified()).Should(BeTrue())
+		})
+	})
+
+	Context("2. with checksum, pvc, imported=no, no checksumJob", func() {
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256}, newTestImporterPvc("no"))
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create checksum job", func() {
+			job, err := getTestJob(r, GetChecksumJobNameFromVmiName(r.vmi.Name))
+			Expect(err).Should(BeNil())
+			Expect(job.Spec.Template.Spec.Containers[0].Command[2]).Should(ContainSubstring("sha256sum"))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: r.vmi.Spec.Source.HTTP}))
+		})
+		It("Should not be verified", func() {
+			Expect(r.isChecksumVerified()).Should(BeFalse())
+		})
+	})
+
+	Context("3. with checksum, pvc, imported=no, completed checksumJob with matched digest", func() {
+		checksumJob, checksumPod := newTestCompletedJob(GetChecksumJobNameFromVmiName(testVmiName), "digest="+testSHA256+"\n")
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256}, newTestImporterPvc("no"), checksumJob, checksumPod)
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the verified digest", func() {
+			vmi := &hc.VirtualMachineImage{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.Digest).Should(Equal("sha256:" + testSHA256))
+		})
+		It("Should be verified", func() {
+			Expect(r.isChecksumVerified()).Should(BeTrue())
+		})
+	})
+
+	Context("4. with checksum, pvc, imported=no, completed checksumJob with mismatched digest", func() {
+		checksumJob, checksumPod := newTestCompletedJob(GetChecksumJobNameFromVmiName(testVmiName), "digest=0000\n")
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256}, newTestImporterPvc("no"), checksumJob, checksumPod)
+		err := r.syncChecksum()
+
+		It("Should return checksum mismatch error", func() {
+			vmiErr := (*vmiError)(nil)
+			Expect(goerrors.As(err, &vmiErr)).Should(BeTrue())
+			Expect(vmiErr.reason).Should(Equal(ReasonChecksumMismatch))
+		})
+		It("Should not be verified", func() {
+			Expect(r.isChecksumVerified()).Should(BeFalse())
+		})
+	})
+
+	Context("5. with sha256SumsURL, pvc, imported=no, completed checksumJob with matched digest", func() {
+		checksumJob, checksumPod := newTestCompletedJob(GetChecksumJobNameFromVmiName(testVmiName), "digest="+testSHA256+"\nexpected="+testSHA256+"\n")
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256SumsURL: "https://download.cirros-cloud.net/0.5.1/SHA256SUMS"},
+			newTestImporterPvc("no"), checksumJob, checksumPod)
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the verified digest", func() {
+			Expect(r.vmi.Status.Digest).Should(Equal("sha256:" + testSHA256))
+		})
+	})
+
+	Context("6. with checksum, pvc, imported=yes, checksumJob", func() {
+		checksumJob, checksumPod := newTestCompletedJob(GetChecksumJobNameFromVmiName(testVmiName), "digest="+testSHA256+"\n")
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256}, newTestImporterPvc("yes"), checksumJob, checksumPod)
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete checksum job", func() {
+			_, err := getTestJob(r, GetChecksumJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("7. with sha256SumsURL of s3 source, pvc, imported=no, no checksumJob", func() {
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256SumsURL: "http://minio.default:9000/images/SHA256SUMS"}, newTestImporterPvc("no"))
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			S3: &hc.VirtualMachineImageSourceS3{
+				Endpoint:  "http://minio.default:9000",
+				Bucket:    "images",
+				Key:       "disk.img",
+				SecretRef: "minio-secret",
+			},
+			Checksum: r.vmi.Spec.Source.Checksum,
+		}
+		err := r.syncChecksum()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should fetch the SHA256SUMS file with the credentials of the source", func() {
+			job, err := getTestJob(r, GetChecksumJobNameFromVmiName(r.vmi.Name))
+			Expect(err).Should(BeNil())
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).Should(ContainElement(corev1.EnvVar{Name: ChecksumSumsURL, Value: "http://minio.default:9000/images/SHA256SUMS"}))
+			Expect(container.Env).Should(ContainElement(newSecretKeyEnvVar(AccessKeyID, "minio-secret", AccessKeyID)))
+			Expect(container.Command[2]).Should(ContainSubstring(`--aws-sigv4 aws:amz:us-east-1:s3 --user "$` + AccessKeyID + `:$` + SecretAccessKey + `" "$` + ChecksumSumsURL + `"`))
+		})
+	})
+})
+
+var _ = Describe("validateChecksum", func() {
+	Context("1. with multiple checksums", func() {
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256, MD5: "0000"})
+		err := r.validateChecksum()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("2. with upload source", func() {
+		r := createFakeReconcileVmiWithChecksum(&hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256})
+		r.vmi.Spec.Source.HTTP = ""
+		r.vmi.Spec.Source.Upload = &hc.VirtualMachineImageSourceUpload{}
+		err := r.validateChecksum()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+})
+
+var _ = Describe("getSourceFileName", func() {
+	It("Should return the file name of url", func() {
+		Expect(getSourceFileName("https://download.cirros-cloud.net/0.5.1/cirros-0.5.1-x86_64-disk.img?a=b")).Should(Equal("cirros-0.5.1-x86_64-disk.img"))
+	})
+	It("Should return the file name of path", func() {
+		Expect(getSourceFileName("/data/source/disk.img")).Should(Equal("disk.img"))
+	})
+})
+
+func createFakeReconcileVmiWithChecksum(checksum *hc.VirtualMachineImageSourceChecksum, objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	r := createFakeReconcileVmi(objects...)
+	r.vmi.Spec.Source.Checksum = checksum
+	return r
+}
@@ -0,0 +1,293 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	// CloneAllowedNamespacesAnnotation is the annotation of the source pvc which contains the comma separated namespaces
+	// allowed to clone the pvc. "*" allows all namespaces. The pvc in the same namespace can always be cloned.
+	CloneAllowedNamespacesAnnotation = "hypercloud.tmaxanc.com/clone-allowed-namespaces"
+	// CloneTargetNamespaceLabel is the label of the clone source job and its pods which indicates the namespace of the target vmi
+	CloneTargetNamespaceLabel = "hypercloud.tmaxanc.com/clone-target-namespace"
+	// CloneTargetNameLabel is the label of the clone source job and its pods which indicates the name of the target vmi
+	CloneTargetNameLabel = "hypercloud.tmaxanc.com/clone-target-name"
+	// CloneSourceBlockPath is a path where the block source pvc is attached in the clone source pod
+	CloneSourceBlockPath = "/dev/clone-source"
+	// CloneServerCACertVar provides a constant to capture our env variable "SERVER_CA_CERT"
+	CloneServerCACertVar = "SERVER_CA_CERT"
+	// CloneClientCertVar provides a constant to capture our env variable "CLIENT_CERT"
+	CloneClientCertVar = "CLIENT_CERT"
+	// CloneClientKeyVar provides a constant to capture our env variable "CLIENT_KEY"
+	CloneClientKeyVar = "CLIENT_KEY"
+	// UploadServerIPAnnotation is the annotation of the clone source job which indicates the pod ip of the upload server
+	UploadServerIPAnnotation = "uploadServerIP"
+)
+
+// syncClone clones the source pvc into the image pvc.
+// The image pvc provisioned with the source pvc as a dataSource is cloned by the CSI driver and completed in syncPvc,
+// otherwise the clone source job sends the source pvc to the upload server
+func (r *ReconcileVirtualMachineImage) syncClone() error {
+	src, err := r.getSource()
+	if err != nil {
+		return err
+	} else if src != SourcePVC {
+		return nil
+	}
+
+	pvc, err := r.getPvc(r.vmi)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if isDataSourcePvc(pvc) {
+		// CSI 클론은 syncPvc에서 완료를 확인한다
+		return nil
+	}
+	return r.syncCloneSourceJob()
+}
+
+func (r *ReconcileVirtualMachineImage) syncCloneSourceJob() error {
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	} else if !found {
+		return nil
+	}
+
+	sourceNamespace, sourceName := getSourcePvcNamespacedName(r.vmi)
+	sourceJob := &batchv1.Job{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: sourceNamespace, Name: GetCloneSourceJobNameFromVmi(r.vmi)}, sourceJob)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	existsSourceJob := err == nil
+
+	uploadServerIP, err := r.getUploadServerIP()
+	if err != nil {
+		return err
+	}
+
+	if imported && existsSourceJob {
+		// 클론이 완료됐으니 클론 소스잡을 삭제한다
+		klog.Infof("Delete clone source job because cloning completed vmi: %s", r.vmi.Name)
+		if err := util.DeleteJob(r.client, sourceJob); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobDeleted, "Deleted clone source job %s/%s, the source pvc is cloned",
+			sourceJob.Namespace, sourceJob.Name)
+	} else if !imported && existsSourceJob && sourceJob.Annotations[UploadServerIPAnnotation] != uploadServerIP {
+		// 업로드 서버가 바뀌었으므로 클론 소스잡을 다시 만들기 위해 삭제한다
+		klog.Infof("Delete clone source job because upload server changed vmi: %s", r.vmi.Name)
+		if err := util.DeleteJob(r.client, sourceJob); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	} else if !imported && existsSourceJob {
+		// 클론 소스잡이 실행 중이니 실패한 파드를 기록한다. 재시도 횟수를 넘겨 잡이 실패하면 에러를 반환한다
+		return r.syncJobFailures(sourceJob)
+	} else if !imported && !existsSourceJob && uploadServerIP != "" {
+		// 업로드 서버가 준비됐으므로 클론 소스잡을 만든다. 잡이 실패했으면 재시도 요청 전까지 만들지 않는다
+		if err := r.getJobFailedError(); err != nil {
+			return err
+		}
+		klog.Infof("Create clone source job for vmi %s from pvc %s/%s", r.vmi.Name, sourceNamespace, sourceName)
+		sourcePvc, err := r.getSourcePvc()
+		if err != nil {
+			return err
+		}
+		certSecret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)}, certSecret); err != nil {
+			return err
+		}
+		newSourceJob := newCloneSourceJob(r.vmi, sourcePvc, uploadServerIP, certSecret, r.getMaxRetries(), r.config)
+		if err := r.client.Create(context.TODO(), newSourceJob); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobStarted, "Started clone source job %s/%s", newSourceJob.Namespace, newSourceJob.Name)
+	}
+	return nil
+}
+
+// deleteCloneSourceJobs deletes the clone source jobs of the vmi. They are in the namespace of the source pvc without the owner reference,
+// so they are not deleted with the vmi
+func (r *ReconcileVirtualMachineImage) deleteCloneSourceJobs() error {
+	jobs := &batchv1.JobList{}
+	if err := r.client.List(context.TODO(), jobs, client.MatchingLabels{CloneTargetNamespaceLabel: r.vmi.Namespace, CloneTargetNameLabel: r.vmi.Name}); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		klog.Infof("Delete clone source job %s/%s of vmi %s being deleted", jobs.Items[i].Namespace, jobs.Items[i].Name, r.vmi.Name)
+		if err := util.DeleteJob(r.client, &jobs.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobDeleted, "Deleted clone source job %s/%s", jobs.Items[i].Namespace, jobs.Items[i].Name)
+	}
+	return nil
+}
+
+// getUploadServerIP returns the pod ip of the running upload server. It returns empty string if the upload server is not running
+func (r *ReconcileVirtualMachineImage) getUploadServerIP() (string, error) {
+	importerPod, err := GetImporterPod(r.client, r.vmi.Namespace, r.vmi.Name)
+	if err != nil {
+		return "", err
+	}
+	if importerPod == nil || !IsUploadServerRunning(importerPod) {
+		return "", nil
+	}
+	return importerPod.Status.PodIP, nil
+}
+
+// setCloneDataSource sets the source pvc as a dataSource of the image pvc if the source pvc can be cloned by the CSI driver
+func (r *ReconcileVirtualMachineImage) setCloneDataSource(pvc *corev1.PersistentVolumeClaim) error {
+	sourcePvc, err := r.getSourcePvc()
+	if err != nil {
+		return err
+	}
+	if canCsiClone(sourcePvc, pvc) {
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			Kind: "PersistentVolumeClaim",
+			Name: sourcePvc.Name,
+		}
+	}
+	return nil
+}
+
+// getSourcePvc returns the source pvc after checking the target namespace is allowed to clone it
+func (r *ReconcileVirtualMachineImage) getSourcePvc() (*corev1.PersistentVolumeClaim, error) {
+	namespace, name := getSourcePvcNamespacedName(r.vmi)
+	sourcePvc := &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, sourcePvc); err != nil {
+		return nil, err
+	}
+	if !isCloneAllowed(sourcePvc, r.vmi.Namespace) {
+		return nil, goerrors.New(fmt.Sprintf("pvc %s/%s is not allowed to be cloned to namespace %s. Add the namespace to the %s annotation of the pvc",
+			namespace, name, r.vmi.Namespace, CloneAllowedNamespacesAnnotation))
+	}
+	return sourcePvc, nil
+}
+
+func getSourcePvcNamespacedName(vmi *hc.VirtualMachineImage) (namespace, name string) {
+	namespace = vmi.Spec.Source.PVC.Namespace
+	if namespace == "" {
+		namespace = vmi.Namespace
+	}
+	return namespace, vmi.Spec.Source.PVC.Name
+}
+
+func isCloneAllowed(sourcePvc *corev1.PersistentVolumeClaim, targetNamespace string) bool {
+	if sourcePvc.Namespace == targetNamespace {
+		return true
+	}
+	for _, ns := range strings.Split(sourcePvc.Annotations[CloneAllowedNamespacesAnnotation], ",") {
+		if ns = strings.TrimSpace(ns); ns == "*" || ns == targetNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// canCsiClone returns true if the CSI driver can clone the source pvc into the target pvc.
+// CSI clone is only possible in the same namespace and storage class with the same volume mode and not smaller size
+func canCsiClone(sourcePvc, targetPvc *corev1.PersistentVolumeClaim) bool {
+	if sourcePvc.Namespace != targetPvc.Namespace {
+		return false
+	}
+	if sourcePvc.Spec.StorageClassName == nil || targetPvc.Spec.StorageClassName == nil ||
+		*sourcePvc.Spec.StorageClassName != *targetPvc.Spec.StorageClassName {
+		return false
+	}
+	if util.GetVolumeMode(sourcePvc.Spec.VolumeMode) != util.GetVolumeMode(targetPvc.Spec.VolumeMode) {
+		return false
+	}
+	sourceSize, found := sourcePvc.Status.Capacity[corev1.ResourceStorage]
+	if !found {
+		sourceSize = sourcePvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+	targetSize := targetPvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	return targetSize.Cmp(sourceSize) >= 0
+}
+
+// GetCloneSourceJobNameFromVmi returns the name of the clone source job in the namespace of the source pvc
+func GetCloneSourceJobNameFromVmi(vmi *hc.VirtualMachineImage) string {
+	return vmi.Namespace + "-" + vmi.Name + "-image-clone-source"
+}
+
+// newCloneSourceJob returns the job which sends the source pvc to the upload server. The failed pod is retried up to maxRetries.
+// The job is in the namespace of the source pvc, so it has the labels of the vmi instead of the owner reference.
+// It sends the source pvc by curl, so it runs the fetcher image unless the clone source image is set in the config.
+// The upload server accepts only the client certificate, which is set in the env because the secret is in the namespace of the vmi
+func newCloneSourceJob(vmi *hc.VirtualMachineImage, sourcePvc *corev1.PersistentVolumeClaim, uploadServerIP string, certSecret *corev1.Secret,
+	maxRetries int32, config hc.KubevirtImageServiceConfigSpec) *batchv1.Job {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetCloneSourceJobNameFromVmi(vmi),
+			Namespace: sourcePvc.Namespace,
+			Labels: map[string]string{
+				CloneTargetNamespaceLabel: vmi.Namespace,
+				CloneTargetNameLabel:      vmi.Name,
+			},
+			Annotations: map[string]string{
+				UploadServerIPAnnotation: uploadServerIP,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:                     "clone-source",
+					Image:                    util.GetImageOrDefault(config.Images.CloneSource, util.GetImageOrDefault(config.Images.Fetcher, FetcherImage)),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: SourceVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: sourcePvc.Name,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser: &[]int64{0}[0],
+			},
+		},
+	}
+
+	sourcePath := util.AttachDiskVolume(&pod.Spec.Containers[0], SourceVolumeName, sourcePvc.Spec.VolumeMode, CloneSourceBlockPath, SourceVolumeMountPath, true)
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{
+		{Name: CloneServerCACertVar, Value: string(certSecret.Data[corev1.TLSCertKey])},
+		{Name: CloneClientCertVar, Value: string(certSecret.Data[UploadClientCertKey])},
+		{Name: CloneClientKeyVar, Value: string(certSecret.Data[UploadClientKeyKey])},
+	}
+	// The upload server is connected by its pod ip with the server name of its certificate
+	resolveIP := uploadServerIP
+	if strings.Contains(resolveIP, ":") {
+		resolveIP = "[" + resolveIP + "]"
+	}
+	uploadURL := fmt.Sprintf("https://%s:%d%s", UploadServerName, UploadServerPort, UploadServerPath)
+	pod.Spec.Containers[0].Command = []string{"/bin/sh", "-c", "set -e\n" +
+		`printf '%s' "$` + CloneServerCACertVar + `" > /tmp/server-ca.crt` + "\n" +
+		`printf '%s' "$` + CloneClientCertVar + `" > /tmp/client.crt` + "\n" +
+		`printf '%s' "$` + CloneClientKeyVar + `" > /tmp/client.key` + "\n" +
+		fmt.Sprintf("curl -sSf --cacert /tmp/server-ca.crt --cert /tmp/client.crt --key /tmp/client.key --resolve %s:%d:%s -X POST -T - %s < %s",
+			UploadServerName, UploadServerPort, resolveIP, uploadURL, sourcePath)}
+	util.ApplyConfigToPod(pod, config)
+	return util.NewJob(pod, maxRetries, config)
+}
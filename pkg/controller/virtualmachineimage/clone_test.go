@@ -0,0 +1,283 @@
+package virtualmachineimage
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+)
+
+const (
+	testSourcePvcName = "testsourcepvc"
+	testSourcePvcNs   = "sourcens"
+)
+
+// 번호		sourceNs		allowed		volumeMode		dataSource
+// 1		same			O			Block			O
+// 2		same			O			Filesystem		X
+// 3		other			X
+// 4		other			O			Block			X
+var _ = Describe("setCloneDataSource", func() {
+	Context("1. with block source pvc in the same namespace", func() {
+		sourcePvc := newTestSourcePvc(testVmiNs, corev1.PersistentVolumeBlock, "")
+		r := createFakeReconcileVmiWithPvcSource(testVmiNs, sourcePvc)
+		pvc, _ := newPvc(r.vmi, r.scheme)
+		err := r.setCloneDataSource(pvc)
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should set the source pvc as a dataSource", func() {
+			Expect(pvc.Spec.DataSource).ShouldNot(BeNil())
+			Expect(pvc.Spec.DataSource.Name).Should(Equal(testSourcePvcName))
+		})
+	})
+
+	Context("2. with filesystem source pvc in the same namespace", func() {
+		sourcePvc := newTestSourcePvc(testVmiNs, corev1.PersistentVolumeFilesystem, "")
+		r := createFakeReconcileVmiWithPvcSource(testVmiNs, sourcePvc)
+		pvc, _ := newPvc(r.vmi, r.scheme)
+		err := r.setCloneDataSource(pvc)
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not set a dataSource", func() {
+			Expect(pvc.Spec.DataSource).Should(BeNil())
+		})
+	})
+
+	Context("3. with source pvc in other namespace which is not allowed", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, "otherns")
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc)
+		pvc, _ := newPvc(r.vmi, r.scheme)
+		err := r.setCloneDataSource(pvc)
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("4. with source pvc in other namespace which is allowed", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, "otherns, "+testVmiNs)
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc)
+		pvc, _ := newPvc(r.vmi, r.scheme)
+		err := r.setCloneDataSource(pvc)
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not set a dataSource", func() {
+			Expect(pvc.Spec.DataSource).Should(BeNil())
+		})
+	})
+})
+
+// 번호		pvc				imported		uploadServer		cloneSourceJob
+// 1		csi clone		no
+// 2		X
+// 3		host-assisted	no				Running				X
+// 4		host-assisted	no				X					X
+// 5		host-assisted	no				Running				O(other upload server)
+// 6		host-assisted	yes									O
+// 7		host-assisted	no				Running				O(failed)
+var _ = Describe("syncClone", func() {
+	Context("1. with bound csi clone pvc, imported=no", func() {
+		sourcePvc := newTestSourcePvc(testVmiNs, corev1.PersistentVolumeBlock, "")
+		pvc := newTestImporterPvc("no")
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: testSourcePvcName}
+		pvc.Status.Phase = corev1.ClaimBound
+		r := createFakeReconcileVmiWithPvcSource(testVmiNs, sourcePvc, pvc)
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create scratch pvc", func() {
+			scratchPvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: getScratchPvcNameFromVmiName(r.vmi.Name)}, scratchPvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("2. with no pvc", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc)
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create clone source job", func() {
+			_, err := getTestCloneSourceJob(r)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("3. with host-assisted pvc, imported=no, running upload server, no clone source job", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc, newTestImporterPvc("no"), newTestUploadServerPod("10.0.0.1"),
+			newTestUploadServerCertSecret())
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create clone source job which sends the source pvc to the upload server", func() {
+			sourceJob, err := getTestCloneSourceJob(r)
+			Expect(err).Should(BeNil())
+			Expect(sourceJob.Labels[CloneTargetNamespaceLabel]).Should(Equal(testVmiNs))
+			Expect(sourceJob.Labels[CloneTargetNameLabel]).Should(Equal(testVmiName))
+			Expect(*sourceJob.Spec.BackoffLimit).Should(Equal(int32(DefaultMaxRetries)))
+			sourcePod := sourceJob.Spec.Template
+			Expect(sourcePod.Labels[CloneTargetNameLabel]).Should(Equal(testVmiName))
+			Expect(sourcePod.Spec.RestartPolicy).Should(Equal(corev1.RestartPolicyNever))
+			Expect(sourcePod.Spec.Containers[0].VolumeDevices).Should(ContainElement(corev1.VolumeDevice{Name: SourceVolumeName, DevicePath: CloneSourceBlockPath}))
+			Expect(sourcePod.Spec.Containers[0].Command[2]).Should(ContainSubstring("--resolve " + UploadServerName + ":8443:10.0.0.1"))
+			Expect(sourcePod.Spec.Containers[0].Command[2]).Should(ContainSubstring("https://" + UploadServerName + ":8443/v1alpha1/upload"))
+		})
+		It("Should send the source pvc with the client certificate", func() {
+			sourceJob, err := getTestCloneSourceJob(r)
+			Expect(err).Should(BeNil())
+			env := sourceJob.Spec.Template.Spec.Containers[0].Env
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: CloneServerCACertVar, Value: "servercert"}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: CloneClientCertVar, Value: "clientcert"}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: CloneClientKeyVar, Value: "clientkey"}))
+			Expect(sourceJob.Spec.Template.Spec.Containers[0].Command[2]).Should(ContainSubstring("--cert /tmp/client.crt --key /tmp/client.key"))
+		})
+	})
+
+	Context("4. with host-assisted pvc, imported=no, no upload server, no clone source job", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc, newTestImporterPvc("no"))
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create clone source job", func() {
+			_, err := getTestCloneSourceJob(r)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("5. with host-assisted pvc, imported=no, running upload server, clone source job for other upload server", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		sourceJob := newTestCloneSourceJob(sourcePvc, "10.0.0.2")
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc, newTestImporterPvc("no"), newTestUploadServerPod("10.0.0.1"), sourceJob)
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete clone source job", func() {
+			_, err := getTestCloneSourceJob(r)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("6. with host-assisted pvc, imported=yes, clone source job", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		sourceJob := newTestCloneSourceJob(sourcePvc, "10.0.0.1")
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc, newTestImporterPvc("yes"), sourceJob)
+		err := r.syncClone()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete clone source job", func() {
+			_, err := getTestCloneSourceJob(r)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("7. with host-assisted pvc, imported=no, running upload server, failed clone source job", func() {
+		sourcePvc := newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs)
+		sourceJob := newTestCloneSourceJob(sourcePvc, "10.0.0.1")
+		sourceJob.Status.Failed = DefaultMaxRetries + 1
+		sourceJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}
+		r := createFakeReconcileVmiWithPvcSource(testSourcePvcNs, sourcePvc, newTestImporterPvc("no"), newTestUploadServerPod("10.0.0.1"), sourceJob)
+		err := r.syncClone()
+
+		It("Should return the error of the failed job", func() {
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("BackoffLimitExceeded"))
+		})
+		It("Should record the failure", func() {
+			Expect(r.vmi.Status.FailedCount).Should(Equal(int32(DefaultMaxRetries + 1)))
+			Expect(r.vmi.Status.LastFailure.JobFailedReason).Should(Equal("BackoffLimitExceeded"))
+		})
+	})
+})
+
+func newTestCloneSourceJob(sourcePvc *corev1.PersistentVolumeClaim, uploadServerIP string) *batchv1.Job {
+	vmi := newTestVmi()
+	vmi.Spec.Source = hc.VirtualMachineImageSource{PVC: &hc.VirtualMachineImageSourcePVC{Namespace: sourcePvc.Namespace, Name: sourcePvc.Name}}
+	return newCloneSourceJob(vmi, sourcePvc, uploadServerIP, newTestUploadServerCertSecret(), DefaultMaxRetries, hc.KubevirtImageServiceConfigSpec{})
+}
+
+func createFakeReconcileVmiWithPvcSource(sourceNamespace string, objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	r := createFakeReconcileVmi(objects...)
+	r.vmi.Spec.Source = hc.VirtualMachineImageSource{PVC: &hc.VirtualMachineImageSourcePVC{Namespace: sourceNamespace, Name: testSourcePvcName}}
+	volumeMode := corev1.PersistentVolumeBlock
+	r.vmi.Spec.PVC.VolumeMode = &volumeMode
+	return r
+}
+
+func newTestSourcePvc(namespace string, volumeMode corev1.PersistentVolumeMode, allowedNamespaces string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testSourcePvcName,
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeMode:       &volumeMode,
+			StorageClassName: &testStorageClassName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+			Capacity: map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceStorage: resource.MustParse("3Gi"),
+			},
+		},
+	}
+	if allowedNamespaces != "" {
+		pvc.Annotations = map[string]string{CloneAllowedNamespacesAnnotation: allowedNamespaces}
+	}
+	return pvc
+}
+
+func newTestUploadServerPod(podIP string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetImporterJobNameFromVmiName(testVmiName) + "-abcde",
+			Namespace: testVmiNs,
+			Labels:    map[string]string{util.JobNameLabel: GetImporterJobNameFromVmiName(testVmiName)},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			PodIP: podIP,
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  FetcherContainerName,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+}
+
+func getTestCloneSourceJob(r *ReconcileVirtualMachineImage) (*batchv1.Job, error) {
+	namespace, _ := getSourcePvcNamespacedName(r.vmi)
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: GetCloneSourceJobNameFromVmi(r.vmi)}, job)
+	return job, err
+}
@@ -0,0 +1,80 @@
+package virtualmachineimage
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+)
+
+// syncConditions updates PvcBound, Imported and SnapshotReady conditions by the image pvc and the snapshot of the current revision,
+// and records the generation of the vmi which the status is based on
+func (r *ReconcileVirtualMachineImage) syncConditions() error {
+	oldStatus := r.vmi.Status.DeepCopy()
+	if err := r.setStageConditions(); err != nil {
+		return err
+	}
+	r.vmi.Status.ObservedGeneration = r.vmi.Generation
+	if equality.Semantic.DeepEqual(oldStatus, &r.vmi.Status) {
+		return nil
+	}
+	return r.client.Status().Update(context.TODO(), r.vmi)
+}
+
+// setStageConditions sets PvcBound, Imported and SnapshotReady conditions of the vmi. The status is not updated
+func (r *ReconcileVirtualMachineImage) setStageConditions() error {
+	var pvc *corev1.PersistentVolumeClaim
+	if found, err := r.getPvc(r.vmi); err == nil {
+		pvc = found
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	snapshot := &snapshotv1beta1.VolumeSnapshot{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImageSnapshotName(r.vmi)}, snapshot); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		snapshot = nil
+	}
+
+	generation := r.vmi.Generation
+	r.vmi.Status.Conditions = util.SetPvcBoundCondition(r.vmi.Status.Conditions, pvc, generation)
+	status, reason, message := getImportedCondition(pvc)
+	r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionImported, status, reason, message, generation)
+	status, reason, message = getSnapshotReadyCondition(snapshot)
+	r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionSnapshotReady, status, reason, message, generation)
+	return nil
+}
+
+// getImportedCondition returns the status, the reason and the message of Imported condition by the imported annotation of the image pvc
+func getImportedCondition(pvc *corev1.PersistentVolumeClaim) (corev1.ConditionStatus, string, string) {
+	if pvc == nil {
+		return corev1.ConditionFalse, hc.ReasonPvcNotFound, "Image pvc is not created"
+	}
+	if pvc.Annotations["imported"] == "yes" {
+		return corev1.ConditionTrue, hc.ReasonImported, "Source image is imported into pvc " + pvc.Name
+	}
+	return corev1.ConditionFalse, hc.ReasonImporting, "Source image is being imported into pvc " + pvc.Name
+}
+
+// getSnapshotReadyCondition returns the status, the reason and the message of SnapshotReady condition by the snapshot of the current revision
+func getSnapshotReadyCondition(snapshot *snapshotv1beta1.VolumeSnapshot) (corev1.ConditionStatus, string, string) {
+	switch {
+	case snapshot == nil:
+		return corev1.ConditionFalse, hc.ReasonSnapshotNotFound, "Snapshot is not created"
+	case snapshot.Status != nil && snapshot.Status.Error != nil:
+		message := "Snapshot " + snapshot.Name + " failed"
+		if snapshot.Status.Error.Message != nil {
+			message += ": " + *snapshot.Status.Error.Message
+		}
+		return corev1.ConditionFalse, hc.ReasonSnapshotFailed, message
+	case snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse:
+		return corev1.ConditionTrue, hc.ReasonSnapshotReady, "Snapshot " + snapshot.Name + " is ready to use"
+	default:
+		return corev1.ConditionFalse, hc.ReasonSnapshotNotReady, "Snapshot " + snapshot.Name + " is not ready to use"
+	}
+}
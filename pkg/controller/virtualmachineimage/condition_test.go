@@ -0,0 +1,122 @@
+package virtualmachineimage
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+)
+
+// 번호		pvc				imported		snapshot		PvcBound		Imported		SnapshotReady
+// 1		X								X				PvcNotFound		PvcNotFound		SnapshotNotFound
+// 2		O(Pending)		no				X				PvcPending		Importing		SnapshotNotFound
+// 3		O(Bound)		yes				not ready		PvcBound		Imported		SnapshotNotReady
+// 4		O(Bound)		yes				error			PvcBound		Imported		SnapshotFailed
+// 5		O(Bound)		yes				ready			PvcBound		Imported		SnapshotReady
+var _ = Describe("syncConditions", func() {
+	Context("1. with no pvc", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Generation = 3
+		err := r.syncConditions()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the conditions of the stages and observedGeneration", func() {
+			expectTestConditions(r, hc.ReasonPvcNotFound, hc.ReasonPvcNotFound, hc.ReasonSnapshotNotFound)
+		})
+	})
+
+	Context("2. with pending pvc which is not imported", func() {
+		r := createFakeReconcileVmi(newTestConditionPvc(corev1.ClaimPending, "no"))
+		r.vmi.Generation = 3
+		err := r.syncConditions()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the conditions of the stages and observedGeneration", func() {
+			expectTestConditions(r, hc.ReasonPvcPending, hc.ReasonImporting, hc.ReasonSnapshotNotFound)
+		})
+	})
+
+	Context("3. with snapshot which is not ready", func() {
+		r := createFakeReconcileVmi(newTestConditionPvc(corev1.ClaimBound, "yes"), newTestConditionSnapshot(false, nil))
+		r.vmi.Generation = 3
+		err := r.syncConditions()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the conditions of the stages and observedGeneration", func() {
+			expectTestConditions(r, hc.ReasonPvcBound, hc.ReasonImported, hc.ReasonSnapshotNotReady)
+		})
+	})
+
+	Context("4. with snapshot which failed", func() {
+		r := createFakeReconcileVmi(newTestConditionPvc(corev1.ClaimBound, "yes"), newTestConditionSnapshot(false, &snapshotv1beta1.VolumeSnapshotError{}))
+		r.vmi.Generation = 3
+		err := r.syncConditions()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the conditions of the stages and observedGeneration", func() {
+			expectTestConditions(r, hc.ReasonPvcBound, hc.ReasonImported, hc.ReasonSnapshotFailed)
+		})
+	})
+
+	Context("5. with snapshot which is ready", func() {
+		r := createFakeReconcileVmi(newTestConditionPvc(corev1.ClaimBound, "yes"), newTestConditionSnapshot(true, nil))
+		r.vmi.Generation = 3
+		err := r.syncConditions()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the conditions of the stages and observedGeneration", func() {
+			expectTestConditions(r, hc.ReasonPvcBound, hc.ReasonImported, hc.ReasonSnapshotReady)
+		})
+	})
+})
+
+func expectTestConditions(r *ReconcileVirtualMachineImage, pvcBoundReason, importedReason, snapshotReadyReason string) {
+	vmi := &hc.VirtualMachineImage{}
+	Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}, vmi)).Should(BeNil())
+	Expect(vmi.Status.ObservedGeneration).Should(Equal(int64(3)))
+	for conditionType, reason := range map[string]string{
+		hc.ConditionPvcBound:      pvcBoundReason,
+		hc.ConditionImported:      importedReason,
+		hc.ConditionSnapshotReady: snapshotReadyReason,
+	} {
+		found, cond := util.GetConditionByType(vmi.Status.Conditions, conditionType)
+		Expect(found).Should(BeTrue())
+		Expect(cond.Reason).Should(Equal(reason))
+		Expect(cond.Status == corev1.ConditionTrue).Should(Equal(reason == hc.ReasonPvcBound || reason == hc.ReasonImported || reason == hc.ReasonSnapshotReady))
+		Expect(cond.ObservedGeneration).Should(Equal(int64(3)))
+	}
+}
+
+func newTestConditionPvc(phase corev1.PersistentVolumeClaimPhase, imported string) *corev1.PersistentVolumeClaim {
+	pvc := newTestImporterPvc(imported)
+	pvc.Status.Phase = phase
+	return pvc
+}
+
+func newTestConditionSnapshot(readyToUse bool, snapshotErr *snapshotv1beta1.VolumeSnapshotError) *snapshotv1beta1.VolumeSnapshot {
+	return &snapshotv1beta1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSnapshotNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Status: &snapshotv1beta1.VolumeSnapshotStatus{
+			ReadyToUse: &readyToUse,
+			Error:      snapshotErr,
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"strconv"
+)
+
+const (
+	// ConverterSourceFile provides a constant to capture our env variable "SOURCE_FILE"
+	ConverterSourceFile = "SOURCE_FILE"
+	// ConverterSourceFormat provides a constant to capture our env variable "SOURCE_FORMAT"
+	ConverterSourceFormat = "SOURCE_FORMAT"
+	// ConverterImageSize provides a constant to capture our env variable "IMAGE_SIZE"
+	ConverterImageSize = "IMAGE_SIZE"
+	// ConverterDestination provides a constant to capture our env variable "DESTINATION"
+	ConverterDestination    = "DESTINATION"
+	converterFormatKey      = "format"
+	converterVirtualSizeKey = "virtualSize"
+)
+
+// ConverterScript detects the format of the source file if SOURCE_FORMAT is empty, converts it to raw and writes it to DESTINATION.
+// DESTINATION is the block device or the disk file in the Filesystem-mode pvc, which must fit in the free space of the file system.
+// The progress of qemu-img is reported to the log while converting, and the detected format and the virtual size are written to the termination message
+const ConverterScript = `set -e
+fail() {
+  echo "$1" > /dev/termination-log
+  exit 1
+}
+format="$SOURCE_FORMAT"
+if [ -z "$format" ]; then
+  format=$(qemu-img info "$SOURCE_FILE" | sed -n 's/^file format: //p')
+  if [ "$format" = raw ] && [ "$(dd if="$SOURCE_FILE" bs=1 skip=32769 count=5 2>/dev/null)" = CD001 ]; then
+    format=iso
+  elif [ "$format" = vpc ]; then
+    format=vhd
+  fi
+fi
+case "$format" in
+  raw|iso) qemuFormat=raw ;;
+  qcow2|vmdk|vhdx) qemuFormat=$format ;;
+  vhd) qemuFormat=vpc ;;
+  *) fail "unsupported source format: $format" ;;
+esac
+info=$(qemu-img info -f "$qemuFormat" "$SOURCE_FILE") || fail "source image is not $format format"
+if echo "$info" | grep -q '^backing file:'; then
+  fail "source image with a backing file is not supported"
+fi
+virtualSize=$(echo "$info" | sed -n 's/^virtual size: .*(\([0-9]*\) bytes)$/\1/p')
+if [ "$virtualSize" -gt "$IMAGE_SIZE" ]; then
+  fail "virtual size of source image($virtualSize) is bigger than storage request in pvc($IMAGE_SIZE)"
+fi
+cache=none
+if [ ! -b "$DESTINATION" ]; then
+  # The disk file is written through the page cache, because some file systems don't support O_DIRECT
+  cache=writeback
+  rm -f "$DESTINATION"
+  available=$(df -Pk "$(dirname "$DESTINATION")" | awk 'NR == 2 { printf "%d", $4 * 1024 }')
+  if [ "$virtualSize" -gt "$available" ]; then
+    fail "virtual size of source image($virtualSize) is bigger than available space in pvc($available)"
+  fi
+fi
+(
+  set +e
+  qemu-img convert -p -t "$cache" -f "$qemuFormat" -O raw "$SOURCE_FILE" "$DESTINATION" > /tmp/convert-progress
+  echo $? > /tmp/convert-exit.tmp
+  mv /tmp/convert-exit.tmp /tmp/convert-exit
+) &
+while [ ! -e /tmp/convert-exit ]; do
+  sleep ` + ProgressReportInterval + `
+  percent=$(tr '\r' '\n' < /tmp/convert-progress | sed -n 's/.*(\([0-9.]*\)\/100%).*/\1/p' | tail -n 1)
+  echo "` + progressKey + `=$(awk -v p="${percent:-0}" -v s="$virtualSize" 'BEGIN { printf "%d", p * s / 100 }')/$virtualSize"
+done
+[ "$(cat /tmp/convert-exit)" = 0 ] || fail "failed to convert source image from $format to raw"
+printf 'format=%s\nvirtualSize=%s\n' "$format" "$virtualSize" > /dev/termination-log
+`
+
+// newConverterEnv returns the environment variables of the converter which converts sourceFile to destination, the device or the disk file of the pvc
+func (r *ReconcileVirtualMachineImage) newConverterEnv(sourceFile, destination string) []corev1.EnvVar {
+	pvcSize := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	return []corev1.EnvVar{
+		{Name: ConverterSourceFile, Value: sourceFile},
+		{Name: ConverterSourceFormat, Value: string(r.vmi.Spec.Source.Format)},
+		{Name: ConverterImageSize, Value: strconv.FormatInt(pvcSize.Value(), 10)},
+		{Name: ConverterDestination, Value: destination},
+	}
+}
+
+func (r *ReconcileVirtualMachineImage) validateFormat() error {
+	if r.vmi.Spec.Source.Format == "" {
+		return nil
+	}
+	if src, err := r.getSource(); err != nil {
+		return err
+	} else if src != SourceHTTP && src != SourceS3 && src != SourceHostPath {
+		return goerrors.New("format is only supported for http, s3 and hostPath sources")
+	}
+	return nil
+}
+
+// updateImageInfo records the format and the virtual size of the source image in the status
+func (r *ReconcileVirtualMachineImage) updateImageInfo(format hc.VirtualMachineImageFormat, virtualSize *resource.Quantity) error {
+	r.vmi.Status.Format = format
+	r.vmi.Status.VirtualSize = virtualSize
+	return r.client.Status().Update(context.TODO(), r.vmi)
+}
+
+// parseConverterResult returns the format and the virtual size from the termination message of the converter
+func parseConverterResult(result string) (hc.VirtualMachineImageFormat, *resource.Quantity) {
+	values := parseTerminationMessage(result)
+	var virtualSize *resource.Quantity
+	if size, err := strconv.ParseInt(values[converterVirtualSizeKey], 10, 64); err == nil {
+		virtualSize = resource.NewQuantity(size, resource.BinarySI)
+	}
+	return hc.VirtualMachineImageFormat(values[converterFormatKey]), virtualSize
+}
@@ -0,0 +1,56 @@
+package virtualmachineimage
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+var _ = Describe("parseConverterResult", func() {
+	Context("1. with format and virtual size", func() {
+		format, virtualSize := parseConverterResult("format=vmdk\nvirtualSize=10737418240\n")
+
+		It("Should return the format", func() {
+			Expect(format).Should(Equal(hc.VirtualMachineImageFormatVmdk))
+		})
+		It("Should return the virtual size", func() {
+			Expect(virtualSize.String()).Should(Equal("10Gi"))
+		})
+	})
+
+	Context("2. with empty message", func() {
+		format, virtualSize := parseConverterResult("")
+
+		It("Should return empty format", func() {
+			Expect(format).Should(BeEmpty())
+		})
+		It("Should return nil virtual size", func() {
+			Expect(virtualSize).Should(BeNil())
+		})
+	})
+})
+
+var _ = Describe("validateFormat", func() {
+	Context("1. with http source", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source.Format = hc.VirtualMachineImageFormatQcow2
+		err := r.validateFormat()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+	})
+
+	Context("2. with registry source", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			Registry: &hc.VirtualMachineImageSourceRegistry{URL: "quay.io/kubevirt/cirros-container-disk-demo:latest"},
+			Format:   hc.VirtualMachineImageFormatQcow2,
+		}
+		err := r.validateFormat()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+})
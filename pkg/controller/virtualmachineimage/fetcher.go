@@ -0,0 +1,269 @@
+package virtualmachineimage
+
+import (
+	goerrors "errors"
+	corev1 "k8s.io/api/core/v1"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FetcherImage indicates image name of the fetcher which downloads and unpacks the http, s3 and hostPath source image
+	FetcherImage = "curlimages/curl:7.75.0"
+	// FetcherContainerName is the name of the init container which fetches the source image into the scratch pvc,
+	// or the container which streams the raw source image into the image pvc
+	FetcherContainerName = "fetcher"
+	// FetcherDataPath is a path where the fetcher writes the source image
+	FetcherDataPath = "/data"
+	// FetchedImageFile is the file name of the fetched source image
+	FetchedImageFile = "disk.img"
+	// ScratchDataSubPath is the sub path of the scratch pvc where the fetched source image is written
+	ScratchDataSubPath = "data"
+	// ScratchTmpSubPath is the sub path of the scratch pvc which is used as a temporary space by the fetcher
+	ScratchTmpSubPath = "tmp"
+	// SourceURLVar provides a constant to capture our env variable "SOURCE_URL"
+	SourceURLVar = "SOURCE_URL"
+	// HTTPUsernameVar provides a constant to capture our env variable "HTTP_USERNAME"
+	HTTPUsernameVar = "HTTP_USERNAME"
+	// HTTPPasswordVar provides a constant to capture our env variable "HTTP_PASSWORD"
+	HTTPPasswordVar = "HTTP_PASSWORD"
+	// HTTPTokenVar provides a constant to capture our env variable "HTTP_TOKEN"
+	HTTPTokenVar = "HTTP_TOKEN"
+	// HTTPExtraHeadersVar provides a constant to capture our env variable "HTTP_EXTRA_HEADERS"
+	HTTPExtraHeadersVar = "HTTP_EXTRA_HEADERS"
+	// HTTPSecretExtraHeadersVar provides a constant to capture our env variable "HTTP_SECRET_EXTRA_HEADERS"
+	HTTPSecretExtraHeadersVar = "HTTP_SECRET_EXTRA_HEADERS"
+	// HTTPSecretTokenKey is the key of the bearer token in the secret of the http source
+	HTTPSecretTokenKey = "token"
+	// HTTPSecretExtraHeadersKey is the key of the extra headers in the secret of the http source
+	HTTPSecretExtraHeadersKey = "extraHeaders"
+	// CurlHeadersFile is the file where curl_source writes the response headers
+	CurlHeadersFile = "/tmp/curl-headers"
+)
+
+// CurlScript defines curl_source shell function which runs curl with the TLS options, the credentials and the extra headers
+// in the environment variables. The CA bundle files in the cert volume are concatenated because curl takes only one CA file.
+// The response headers are written to CurlHeadersFile to report the progress with Content-Length
+const CurlScript = `curl_source() {
+  set -- -sSfL "$@"
+  if [ "$` + InsecureTLSVar + `" = true ]; then
+    set -- -k "$@"
+  elif [ -d ` + CertVolumeMountPath + ` ]; then
+    cat ` + CertVolumeMountPath + `/* > /tmp/ca-bundle.crt
+    set -- --cacert /tmp/ca-bundle.crt "$@"
+  fi
+  if [ -n "$` + HTTPTokenVar + `" ]; then
+    set -- --oauth2-bearer "$` + HTTPTokenVar + `" "$@"
+  elif [ -n "$` + HTTPUsernameVar + `" ]; then
+    set -- --user "$` + HTTPUsernameVar + `:$` + HTTPPasswordVar + `" "$@"
+  fi
+  printf '%s\n%s\n' "$` + HTTPExtraHeadersVar + `" "$` + HTTPSecretExtraHeadersVar + `" | sed '/^[[:space:]]*$/d' > /tmp/extra-headers
+  curl -H @/tmp/extra-headers -D ` + CurlHeadersFile + ` "$@"
+}
+`
+
+// setFetcher sets the init container of the importer pod which fetches the source image into the scratch pvc.
+// The compressed or archived http, s3 and hostPath source image is unpacked by UnpackScript while fetching
+func (r *ReconcileVirtualMachineImage) setFetcher(ip *corev1.Pod, src string) error {
+	fetcher := corev1.Container{
+		Name:                     FetcherContainerName,
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: ScratchVolumeName, MountPath: FetcherDataPath, SubPath: ScratchDataSubPath},
+		},
+	}
+	fetchedImagePath := FetcherDataPath + "/" + FetchedImageFile
+
+	if src == SourceHTTP || src == SourceS3 {
+		readSource := r.setCurlReadSource(ip, &fetcher, src)
+		fetcher.Image = util.GetImageOrDefault(r.config.Images.Fetcher, FetcherImage)
+		fetcher.Command = []string{"/bin/sh", "-c", "set -eo pipefail\n" + CurlScript + UnpackScript + ProgressScript +
+			"report_fetch_progress " + fetchedImagePath + " &\nreporter=$!\n" +
+			readSource + " | unpack " + fetchedImagePath + "\nkill $reporter\n"}
+	} else if src == SourceHostPath {
+		// The packed source image is unpacked into the scratch pvc, otherwise it is linked to be converted without copying.
+		// The importer container mounts the host path at the same path, so the link is valid in it
+		sourcePath := SourceVolumeMountPath + "/" + FetchedImageFile
+		fetcher.Image = util.GetImageOrDefault(r.config.Images.Fetcher, FetcherImage)
+		fetcher.Command = []string{"/bin/sh", "-c", "set -eo pipefail\n" + UnpackScript +
+			`if [ -n "$(packing "$` + SourceURLVar + `")" ]; then unpack ` + fetchedImagePath + ` < "$` + SourceURLVar + `"; else ln -sf "$` + SourceURLVar + `" ` + fetchedImagePath + "; fi\n"}
+		fetcher.Env = []corev1.EnvVar{{Name: SourceURLVar, Value: sourcePath}}
+		fetcher.VolumeMounts = append(fetcher.VolumeMounts, corev1.VolumeMount{
+			Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true})
+	} else if src == SourceRegistry {
+		// The cdi importer extracts the disk of the containerDisk into /data/disk.img using /scratch as a temporary space
+		registry := r.vmi.Spec.Source.Registry
+		fetcher.Image = util.GetImageOrDefault(r.config.Images.Importer, ImportPodImage)
+		fetcher.Args = []string{"-v=" + util.GetLogVerbosityOrDefault(r.config, ImportPodVerbose)}
+		fetcher.Env = r.newCdiImporterEnv(SourceRegistry, GetRegistryEndpoint(registry))
+		fetcher.VolumeMounts = append(fetcher.VolumeMounts, corev1.VolumeMount{
+			Name: ScratchVolumeName, MountPath: ScratchVolumeMountPath, SubPath: ScratchTmpSubPath})
+		if registry.SecretRef != "" {
+			fetcher.Env = append(fetcher.Env,
+				newSecretKeyEnvVar(ImporterAccessKeyID, registry.SecretRef, corev1.BasicAuthUsernameKey),
+				newSecretKeyEnvVar(ImporterSecretKey, registry.SecretRef, corev1.BasicAuthPasswordKey))
+		}
+		if registry.CertConfigMap != "" {
+			fetcher.Env = append(fetcher.Env, corev1.EnvVar{Name: ImporterCertDir, Value: CertVolumeMountPath})
+			setCertVolume(ip, &fetcher, registry.CertConfigMap)
+		}
+	} else if src == SourceUpload || src == SourcePVC {
+		// The upload server receives the image from the upload proxy or the clone source pod and writes it to /data/disk.img.
+		// It serves https and accepts only the client certificate of the upload server cert secret, as the cdi upload server does
+		pvcSize := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+		certSecret := GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)
+		fetcher.Image = util.GetImageOrDefault(r.config.Images.UploadServer, UploadServerImage)
+		fetcher.Args = []string{"-v=" + util.GetLogVerbosityOrDefault(r.config, ImportPodVerbose)}
+		fetcher.Env = []corev1.EnvVar{
+			{Name: UploadServerDestination, Value: fetchedImagePath},
+			{Name: UploadServerImageSize, Value: pvcSize.String()},
+			newSecretKeyEnvVar(UploadServerTLSKey, certSecret, corev1.TLSPrivateKeyKey),
+			newSecretKeyEnvVar(UploadServerTLSCert, certSecret, corev1.TLSCertKey),
+			newSecretKeyEnvVar(UploadServerClientCert, certSecret, UploadClientCertKey),
+			{Name: UploadServerClientName, Value: UploadClientName},
+		}
+		fetcher.Ports = []corev1.ContainerPort{
+			{Name: "upload", ContainerPort: UploadServerPort, Protocol: corev1.ProtocolTCP}}
+		fetcher.VolumeMounts = append(fetcher.VolumeMounts, corev1.VolumeMount{
+			Name: ScratchVolumeName, MountPath: ScratchVolumeMountPath, SubPath: ScratchTmpSubPath})
+	} else {
+		return goerrors.New("source " + src + " can't be fetched")
+	}
+	ip.Spec.InitContainers = []corev1.Container{fetcher}
+	return nil
+}
+
+// isStreamedSource returns true if the source image is streamed into the image pvc without the scratch pvc and the converter.
+// Only the http, s3 and hostPath source image whose format is set to raw or iso is streamed, because the format of the others
+// can't be known before fetching it
+func (r *ReconcileVirtualMachineImage) isStreamedSource(src string) bool {
+	if src != SourceHTTP && src != SourceS3 && src != SourceHostPath {
+		return false
+	}
+	format := r.vmi.Spec.Source.Format
+	return format == hc.VirtualMachineImageFormatRaw || format == hc.VirtualMachineImageFormatIso
+}
+
+// setStreamer makes the importer container the fetcher which writes the raw source image to destination, the device or the disk file
+// of the image pvc, while fetching it. The compressed source image is decompressed while streaming, but the archived one fails.
+// The number of the written bytes is the virtual size of the raw image, so it is written to the termination message like the converter
+func (r *ReconcileVirtualMachineImage) setStreamer(ip *corev1.Pod, src, destination string) {
+	streamer := &ip.Spec.Containers[0]
+	streamer.Name = FetcherContainerName
+	streamer.Image = util.GetImageOrDefault(r.config.Images.Fetcher, FetcherImage)
+	streamer.Env = []corev1.EnvVar{
+		{Name: ConverterSourceFormat, Value: string(r.vmi.Spec.Source.Format)},
+		{Name: ConverterDestination, Value: destination},
+	}
+
+	var readSource string
+	if src == SourceHostPath {
+		// The host path is mounted to the importer container by newImporterPod
+		readSource = `cat "$` + SourceURLVar + `"`
+		streamer.Env = append(streamer.Env, corev1.EnvVar{Name: SourceURLVar, Value: SourceVolumeMountPath + "/" + FetchedImageFile})
+	} else {
+		readSource = r.setCurlReadSource(ip, streamer, src)
+	}
+	streamer.Command = []string{"/bin/sh", "-c", "set -eo pipefail\n" + CurlScript + UnpackScript + ProgressScript +
+		"streaming=true\nreport_fetch_progress \"$" + ConverterDestination + "\" &\nreporter=$!\n" +
+		readSource + ` | unpack "$` + ConverterDestination + `"` + "\nkill $reporter\n" +
+		`virtualSize=$(sed -n 's/^\([0-9]*\) bytes.*/\1/p' ` + WriterLogFile + ")\n" +
+		`printf '` + converterFormatKey + `=%s\n` + converterVirtualSizeKey + `=%s\n' "$` + ConverterSourceFormat + `" "$virtualSize" > /dev/termination-log` + "\n"}
+}
+
+// setCurlReadSource sets the environment variables and the volumes of the container to read the http or s3 source image,
+// and returns the command which writes the source image to stdout. The command needs CurlScript
+func (r *ReconcileVirtualMachineImage) setCurlReadSource(pod *corev1.Pod, container *corev1.Container, src string) string {
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: SourceURLVar, Value: r.getSourceURL(src)},
+		corev1.EnvVar{Name: InsecureTLSVar, Value: strconv.FormatBool(r.vmi.Spec.Source.InsecureSkipTLSVerify)})
+	if s3 := r.vmi.Spec.Source.S3; src == SourceS3 {
+		if s3.SecretRef != "" {
+			container.Env = append(container.Env,
+				newSecretKeyEnvVar(AccessKeyID, s3.SecretRef, AccessKeyID),
+				newSecretKeyEnvVar(SecretAccessKey, s3.SecretRef, SecretAccessKey))
+		}
+		if s3.CertConfigMap != "" {
+			setCertVolume(pod, container, s3.CertConfigMap)
+		}
+		return r.getCurlSourceCommand(src, SourceURLVar)
+	}
+
+	if options := r.vmi.Spec.Source.HTTPOptions; options != nil {
+		container.Env = append(container.Env, corev1.EnvVar{Name: HTTPExtraHeadersVar, Value: strings.Join(options.ExtraHeaders, "\n")})
+		if options.SecretRef != "" {
+			// The keys of the secret are optional, so only the keys in the secret are used
+			container.Env = append(container.Env,
+				newOptionalSecretKeyEnvVar(HTTPUsernameVar, options.SecretRef, corev1.BasicAuthUsernameKey),
+				newOptionalSecretKeyEnvVar(HTTPPasswordVar, options.SecretRef, corev1.BasicAuthPasswordKey),
+				newOptionalSecretKeyEnvVar(HTTPTokenVar, options.SecretRef, HTTPSecretTokenKey),
+				newOptionalSecretKeyEnvVar(HTTPSecretExtraHeadersVar, options.SecretRef, HTTPSecretExtraHeadersKey))
+		}
+		if options.CertConfigMap != "" {
+			setCertVolume(pod, container, options.CertConfigMap)
+		}
+	}
+	return r.getCurlSourceCommand(src, SourceURLVar)
+}
+
+// getCurlSourceCommand returns the command which writes the file at the url in urlVar to stdout with the options of the source.
+// The s3 file is signed with the credentials, so the environment variables must be set by setCurlReadSource
+func (r *ReconcileVirtualMachineImage) getCurlSourceCommand(src, urlVar string) string {
+	if src == SourceS3 && r.vmi.Spec.Source.S3.SecretRef != "" {
+		return `curl_source --aws-sigv4 aws:amz:us-east-1:s3 --user "$` + AccessKeyID + `:$` + SecretAccessKey + `" "$` + urlVar + `"`
+	}
+	return `curl_source "$` + urlVar + `"`
+}
+
+// getSourceURL returns the url of the http or s3 source image
+func (r *ReconcileVirtualMachineImage) getSourceURL(src string) string {
+	if src == SourceS3 {
+		return GetS3Endpoint(r.vmi.Spec.Source.S3)
+	}
+	return r.vmi.Spec.Source.HTTP
+}
+
+// setCertVolume mounts the CA bundle in the config map to CertVolumeMountPath of the container
+func setCertVolume(pod *corev1.Pod, container *corev1.Container, configMap string) {
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name: CertVolumeName, MountPath: CertVolumeMountPath, ReadOnly: true})
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: CertVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+			}},
+	})
+}
+
+func (r *ReconcileVirtualMachineImage) validateHTTPOptions() error {
+	if r.vmi.Spec.Source.HTTPOptions == nil {
+		return nil
+	}
+	if src, err := r.getSource(); err != nil {
+		return err
+	} else if src != SourceHTTP {
+		return goerrors.New("httpOptions is only supported for http source")
+	}
+	for _, header := range r.vmi.Spec.Source.HTTPOptions.ExtraHeaders {
+		if !strings.Contains(header, ":") || strings.ContainsAny(header, "\r\n") {
+			return goerrors.New("extra header must be a single line of \"name: value\", but " + strconv.Quote(header))
+		}
+	}
+	return nil
+}
+
+// IsUploadServerRunning returns true if the upload server of the importer pod is running and ready to receive the image
+func IsUploadServerRunning(pod *corev1.Pod) bool {
+	if pod.Status.PodIP == "" {
+		return false
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == FetcherContainerName {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
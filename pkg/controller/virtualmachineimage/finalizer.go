@@ -0,0 +1,131 @@
+package virtualmachineimage
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// VolumeProtectionFinalizer is the finalizer of vmi which blocks its deletion while VirtualMachineVolumes created from it exist,
+	// because the snapshot of the vmi is deleted with it and some CSI drivers can't delete the snapshot with the restored volumes
+	VolumeProtectionFinalizer = "hypercloud.tmaxanc.com/volume-protection"
+	// ForceDeleteAnnotation is the annotation of vmi which requests to delete it even if the dependent volumes exist
+	ForceDeleteAnnotation = "hypercloud.tmaxanc.com/force-delete"
+	// DependentVolumesSyncInterval is the interval to check the dependent volumes of the vmi being deleted
+	DependentVolumesSyncInterval = 10 * time.Second
+	// ReasonDeletionBlocked is the reason of the event when the deletion of vmi is blocked by the dependent volumes
+	ReasonDeletionBlocked = "DeletionBlocked"
+)
+
+// syncFinalizer adds VolumeProtectionFinalizer to the vmi. The spec of r.vmi has the defaults of the config in memory,
+// so only the finalizers are patched
+func (r *ReconcileVirtualMachineImage) syncFinalizer() error {
+	if hasFinalizer(r.vmi) {
+		return nil
+	}
+	patch := client.MergeFrom(r.vmi.DeepCopy())
+	r.vmi.Finalizers = append(r.vmi.Finalizers, VolumeProtectionFinalizer)
+	return r.client.Patch(context.TODO(), r.vmi, patch)
+}
+
+// syncDeletion removes VolumeProtectionFinalizer from the vmi being deleted if no volume depends on it or ForceDeleteAnnotation is set.
+// Otherwise, it records the dependent volumes in the status and returns true. The clone source jobs are deleted in any case
+func (r *ReconcileVirtualMachineImage) syncDeletion() (bool, error) {
+	if !hasFinalizer(r.vmi) {
+		return false, nil
+	}
+	// 다른 네임스페이스의 클론 소스잡은 이미지와 함께 삭제되지 않으므로 직접 삭제한다
+	if err := r.deleteCloneSourceJobs(); err != nil {
+		return false, err
+	}
+
+	dependentVolumes, err := r.getDependentVolumes()
+	if err != nil {
+		return false, err
+	}
+	if _, found := r.vmi.Annotations[ForceDeleteAnnotation]; len(dependentVolumes) != 0 && !found {
+		// 이미지로 만든 볼륨이 남아 있으니 삭제를 막고 볼륨을 상태에 기록한다
+		if !equality.Semantic.DeepEqual(r.vmi.Status.DependentVolumes, dependentVolumes) {
+			klog.Infof("Deletion of vmi %s is blocked by volumes %v", r.vmi.Name, dependentVolumes)
+			r.vmi.Status.DependentVolumes = dependentVolumes
+			if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+				return false, err
+			}
+			r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, ReasonDeletionBlocked, "Deletion is blocked by volumes %v", dependentVolumes)
+		}
+		return true, nil
+	}
+
+	// 의존하는 볼륨이 없거나 강제 삭제가 요청됐으니 finalizer를 제거한다
+	klog.Infof("Remove finalizer of vmi %s", r.vmi.Name)
+	patch := client.MergeFrom(r.vmi.DeepCopy())
+	var finalizers []string
+	for _, finalizer := range r.vmi.Finalizers {
+		if finalizer != VolumeProtectionFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	r.vmi.Finalizers = finalizers
+	return false, r.client.Patch(context.TODO(), r.vmi, patch)
+}
+
+// getDependentVolumes returns the namespace/name of the volumes created from the vmi in all namespaces
+func (r *ReconcileVirtualMachineImage) getDependentVolumes() ([]string, error) {
+	volumes, err := r.getImageVolumes()
+	if err != nil {
+		return nil, err
+	}
+	var dependentVolumes []string
+	for _, volume := range volumes {
+		dependentVolumes = append(dependentVolumes, volume.Namespace+"/"+volume.Name)
+	}
+	return dependentVolumes, nil
+}
+
+// getImageVolumes returns the volumes created from the vmi in all namespaces
+func (r *ReconcileVirtualMachineImage) getImageVolumes() ([]hc.VirtualMachineVolume, error) {
+	volumes := &hc.VirtualMachineVolumeList{}
+	if err := r.client.List(context.TODO(), volumes); err != nil {
+		return nil, err
+	}
+	var imageVolumes []hc.VirtualMachineVolume
+	for i := range volumes.Items {
+		if r.isVolumeOfImage(&volumes.Items[i]) {
+			imageVolumes = append(imageVolumes, volumes.Items[i])
+		}
+	}
+	return imageVolumes, nil
+}
+
+// isVolumeOfImage returns true if the volume refers to the vmi, or to the ClusterVirtualMachineImage which is imported into the vmi
+func (r *ReconcileVirtualMachineImage) isVolumeOfImage(volume *hc.VirtualMachineVolume) bool {
+	image := volume.Spec.VirtualMachineImage
+	if image.Name != r.vmi.Name {
+		return false
+	}
+	if image.Kind == hc.ClusterVirtualMachineImageKind {
+		owner := metav1.GetControllerOf(r.vmi)
+		return owner != nil && owner.Kind == hc.ClusterVirtualMachineImageKind && r.vmi.Namespace == util.GetClusterImageNamespace(r.config)
+	}
+	namespace := image.Namespace
+	if namespace == "" {
+		namespace = volume.Namespace
+	}
+	return namespace == r.vmi.Namespace
+}
+
+func hasFinalizer(vmi *hc.VirtualMachineImage) bool {
+	for _, finalizer := range vmi.Finalizers {
+		if finalizer == VolumeProtectionFinalizer {
+			return true
+		}
+	}
+	return false
+}
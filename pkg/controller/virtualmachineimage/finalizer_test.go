@@ -0,0 +1,214 @@
+package virtualmachineimage
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("syncFinalizer", func() {
+	r := createFakeReconcileVmi()
+	err := r.syncFinalizer()
+
+	It("Should not return error", func() {
+		Expect(err).Should(BeNil())
+	})
+	It("Should add the finalizer to the vmi", func() {
+		vmi := &hc.VirtualMachineImage{}
+		Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}, vmi)).Should(BeNil())
+		Expect(vmi.Finalizers).Should(ConsistOf(VolumeProtectionFinalizer))
+	})
+	It("Should not persist the defaults of the config", func() {
+		vmi := &hc.VirtualMachineImage{}
+		Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}, vmi)).Should(BeNil())
+		Expect(vmi.Spec).Should(Equal(newTestVmi().Spec))
+	})
+})
+
+// 번호		volume							force-delete		blocked
+// 1		X								X					X
+// 2		same namespace					X					O
+// 3		namespace of the vmi			X					O
+// 4		image in other namespace		X					X
+// 5		same namespace					O					X
+// 6		same namespace					X					O					(clone source job in other namespace)
+var _ = Describe("syncDeletion", func() {
+	Context("1. with no volume", func() {
+		r := createFakeReconcileDeletingVmi()
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be blocked", func() {
+			Expect(blocked).Should(BeFalse())
+		})
+		It("Should remove the finalizer", func() {
+			Expect(getTestVmiFinalizers(r)).ShouldNot(ContainElement(VolumeProtectionFinalizer))
+		})
+	})
+
+	Context("2. with volume in the same namespace", func() {
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", testVmiNs, ""))
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be blocked", func() {
+			Expect(blocked).Should(BeTrue())
+		})
+		It("Should keep the finalizer", func() {
+			Expect(getTestVmiFinalizers(r)).Should(ContainElement(VolumeProtectionFinalizer))
+		})
+		It("Should record the dependent volume in the status", func() {
+			vmi := &hc.VirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}, vmi)).Should(BeNil())
+			Expect(vmi.Status.DependentVolumes).Should(ConsistOf(testVmiNs + "/myvmv"))
+		})
+	})
+
+	Context("3. with volume in another namespace which refers to the namespace of the vmi", func() {
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", "othernamespace", testVmiNs))
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be blocked", func() {
+			Expect(blocked).Should(BeTrue())
+			Expect(r.vmi.Status.DependentVolumes).Should(ConsistOf("othernamespace/myvmv"))
+		})
+	})
+
+	Context("4. with volume of the image of the same name in another namespace", func() {
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", "othernamespace", ""))
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be blocked", func() {
+			Expect(blocked).Should(BeFalse())
+			Expect(getTestVmiFinalizers(r)).ShouldNot(ContainElement(VolumeProtectionFinalizer))
+		})
+	})
+
+	Context("5. with volume and force-delete annotation", func() {
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", testVmiNs, ""))
+		r.vmi.Annotations = map[string]string{ForceDeleteAnnotation: "true"}
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be blocked", func() {
+			Expect(blocked).Should(BeFalse())
+		})
+		It("Should remove the finalizer", func() {
+			Expect(getTestVmiFinalizers(r)).ShouldNot(ContainElement(VolumeProtectionFinalizer))
+		})
+	})
+
+	Context("6. with volume and clone source job in the namespace of the source pvc", func() {
+		sourceJob := newTestCloneSourceJob(newTestSourcePvc(testSourcePvcNs, corev1.PersistentVolumeBlock, testVmiNs), "10.0.0.1")
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", testVmiNs, ""), sourceJob)
+		blocked, err := r.syncDeletion()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be blocked", func() {
+			Expect(blocked).Should(BeTrue())
+		})
+		It("Should delete the clone source job", func() {
+			err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: testSourcePvcNs, Name: sourceJob.Name}, &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("isVolumeOfImage", func() {
+	r := createFakeReconcileVmi()
+	r.vmi.Namespace = util.DefaultOperatorNamespace
+	r.vmi.OwnerReferences = []metav1.OwnerReference{newTestCvmiOwnerReference()}
+	volume := newTestVolumeOfVmi("myvmv", "othernamespace", "")
+	volume.Spec.VirtualMachineImage.Kind = hc.ClusterVirtualMachineImageKind
+
+	It("Should be true for the volume of the ClusterVirtualMachineImage which is imported into the vmi", func() {
+		Expect(r.isVolumeOfImage(volume)).Should(BeTrue())
+	})
+	It("Should be false if the vmi is not owned by the ClusterVirtualMachineImage", func() {
+		r.vmi.OwnerReferences = nil
+		Expect(r.isVolumeOfImage(volume)).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Reconcile", func() {
+	Context("with deleting vmi and dependent volume", func() {
+		r := createFakeReconcileDeletingVmi(newTestVolumeOfVmi("myvmv", testVmiNs, ""))
+		result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should check the dependent volumes again", func() {
+			Expect(result.RequeueAfter).Should(Equal(DependentVolumesSyncInterval))
+		})
+		It("Should not create pvc", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: GetPvcNameFromVmiName(testVmiName)}, pvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+func createFakeReconcileDeletingVmi(objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	vmi := newTestVmi()
+	now := metav1.Now()
+	vmi.DeletionTimestamp = &now
+	vmi.Finalizers = []string{VolumeProtectionFinalizer}
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, vmi)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileVirtualMachineImage{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), vmi: vmi}
+}
+
+func newTestVolumeOfVmi(name, namespace, imageNamespace string) *hc.VirtualMachineVolume {
+	return &hc.VirtualMachineVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: hc.VirtualMachineVolumeSpec{
+			VirtualMachineImage: hc.VirtualMachineImageName{Namespace: imageNamespace, Name: testVmiName},
+		},
+	}
+}
+
+func newTestCvmiOwnerReference() metav1.OwnerReference {
+	controller := true
+	return metav1.OwnerReference{
+		APIVersion: hc.SchemeGroupVersion.String(),
+		Kind:       hc.ClusterVirtualMachineImageKind,
+		Name:       testVmiName,
+		UID:        "cvmi-uid",
+		Controller: &controller,
+	}
+}
+
+func getTestVmiFinalizers(r *ReconcileVirtualMachineImage) []string {
+	vmi := &hc.VirtualMachineImage{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: testVmiName}, vmi); err != nil {
+		panic(err)
+	}
+	return vmi.Finalizers
+}
@@ -2,20 +2,29 @@ package virtualmachineimage
 
 import (
 	"context"
+	goerrors "errors"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
+	"strings"
 )
 
 const (
 	// DataVolName provides a const to use for creating volumes in pod specs
 	DataVolName = "data-vol"
-	// WriteBlockPath provides a constant for the path where the PV is mounted.
+	// WriteBlockPath provides a constant for the path where the Block-mode PV is attached.
 	WriteBlockPath = "/dev/cdi-block-volume"
+	// WriteFilesystemPath provides a constant for the path where the Filesystem-mode PV is mounted.
+	WriteFilesystemPath = "/target"
 	// ImporterSource provides a constant to capture our env variable "IMPORTER_SOURCE"
 	ImporterSource = "IMPORTER_SOURCE"
 	// ImporterEndpoint provides a constant to capture our env variable "IMPORTER_ENDPOINT"
@@ -26,13 +35,35 @@ const (
 	ImporterImageSize = "IMPORTER_IMAGE_SIZE"
 	// InsecureTLSVar provides a constant to capture our env variable "INSECURE_TLS"
 	InsecureTLSVar = "INSECURE_TLS"
+	// ImporterAccessKeyID provides a constant to capture our env variable "IMPORTER_ACCESS_KEY_ID"
+	ImporterAccessKeyID = "IMPORTER_ACCESS_KEY_ID"
+	// ImporterSecretKey provides a constant to capture our env variable "IMPORTER_SECRET_KEY"
+	ImporterSecretKey = "IMPORTER_SECRET_KEY"
+	// ImporterCertDir provides a constant to capture our env variable "IMPORTER_CERT_DIR"
+	ImporterCertDir = "IMPORTER_CERT_DIR"
 	// SourceHTTP is the source type HTTP
 	SourceHTTP = "http"
 	// SourceHostPath is the source type host path
 	SourceHostPath = "hostPath"
+	// SourceS3 is the source type S3
+	SourceS3 = "s3"
+	// AccessKeyID is the key of the AWS-style access key id in the S3 secret
+	AccessKeyID = "AWS_ACCESS_KEY_ID"
+	// SecretAccessKey is the key of the AWS-style secret access key in the S3 secret
+	SecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	// SourceRegistry is the source type container registry
+	SourceRegistry = "registry"
+	// RegistryTransport is the default transport of the containerDisk image reference
+	RegistryTransport = "docker://"
+	// SourceUpload is the source type upload
+	SourceUpload = "upload"
+	// SourcePVC is the source type pvc
+	SourcePVC = "pvc"
+	// SourceVolume is the source type VirtualMachineVolume
+	SourceVolume = "virtualMachineVolume"
 	// ImageContentType is the content-type of the imported file
 	ImageContentType = "kubevirt"
-	// ImportPodImage and ImportPodVerbose should be modified to get value from vmi env
+	// ImportPodImage and ImportPodVerbose are the defaults which can be overridden by KubevirtImageServiceConfig
 	// ImportPodImage indicates image name of the import pod
 	ImportPodImage = "kubevirt/cdi-importer:v1.13.0"
 	// ImportPodVerbose indicates log level of the import pod
@@ -41,70 +72,165 @@ const (
 	SourceVolumeName = "source-vol"
 	// SourceVolumeMountPath is a path where the source volume is mounted
 	SourceVolumeMountPath = "/data/source"
+	// CertVolumeName is used for creating the volume of the CA bundle in pod specs
+	CertVolumeName = "cert-vol"
+	// CertVolumeMountPath is a path where the CA bundle is mounted
+	CertVolumeMountPath = "/certs"
+	// ScratchVolumeName is used for creating the scratch volume in pod specs
+	ScratchVolumeName = "scratch-vol"
+	// ScratchVolumeMountPath is a path where the scratch volume is mounted
+	ScratchVolumeMountPath = "/scratch"
+	// UploadServerImage indicates image name of the upload server pod which receives the uploaded image
+	UploadServerImage = "kubevirt/cdi-uploadserver:v1.13.0"
+	// UploadServerPort is the port where the upload server listens
+	UploadServerPort = 8443
+	// UploadServerPath is the path of the upload api of the upload server
+	UploadServerPath = "/v1alpha1/upload"
+	// UploadServerDestination provides a constant to capture our env variable "DESTINATION"
+	UploadServerDestination = "DESTINATION"
+	// UploadServerImageSize provides a constant to capture our env variable "UPLOAD_IMAGE_SIZE"
+	UploadServerImageSize = "UPLOAD_IMAGE_SIZE"
+	// UploadServerTLSKey provides a constant to capture our env variable "TLS_KEY"
+	UploadServerTLSKey = "TLS_KEY"
+	// UploadServerTLSCert provides a constant to capture our env variable "TLS_CERT"
+	UploadServerTLSCert = "TLS_CERT"
+	// UploadServerClientCert provides a constant to capture our env variable "CLIENT_CERT"
+	UploadServerClientCert = "CLIENT_CERT"
+	// UploadServerClientName provides a constant to capture our env variable "CLIENT_NAME"
+	UploadServerClientName = "CLIENT_NAME"
+	// VmiNameLabel is the label of the pods of the importer and checksum jobs which indicates the name of the vmi
+	VmiNameLabel = "hypercloud.tmaxanc.com/vmi"
 )
 
-func (r *ReconcileVirtualMachineImage) syncImporterPod() error {
+func (r *ReconcileVirtualMachineImage) syncImporterJob() error {
 	imported, found, err := r.isPvcImported()
 	if err != nil {
 		return err
 	} else if !found {
-		klog.Warningf("syncImporterPod without pvc in vmi %s", r.vmi.Name)
+		klog.Warningf("syncImporterJob without pvc in vmi %s", r.vmi.Name)
+		return nil
+	}
+	if pvc, err := r.getPvc(r.vmi); err != nil {
+		return err
+	} else if isDataSourcePvc(pvc) {
+		// dataSource로 프로비저닝된 pvc는 임포터잡 없이 CSI 드라이버가 채운다
 		return nil
 	}
 
-	importerPod := &corev1.Pod{}
-	err = r.client.Get(context.Background(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
+	importerJob := &batchv1.Job{}
+	err = r.client.Get(context.Background(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)}, importerJob)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	existsImporterPod := err == nil
+	existsImporterJob := err == nil
 
-	if !imported && existsImporterPod && isPodCompleted(importerPod) {
+	if !imported && existsImporterJob && util.IsJobCompleted(importerJob) {
 		// 임포팅이 완료됐으니 애노테이션을 업데이트하고 삭제한다.
-		klog.Infof("syncImporterPod finish for vmi %s, delete importerPod", r.vmi.Name)
+		klog.Infof("syncImporterJob finish for vmi %s, delete importerJob", r.vmi.Name)
+		result, err := getJobResult(r.client, importerJob)
+		if err != nil {
+			return err
+		}
+		if err := r.updateImageInfo(parseConverterResult(result)); err != nil {
+			return err
+		}
 		if err := r.updatePvcImported(true); err != nil {
 			return err
 		}
-		if err := r.client.Delete(context.TODO(), importerPod); err != nil && !errors.IsNotFound(err) {
+		if err := util.DeleteJob(r.client, importerJob); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobCompleted, "Importer job %s completed, the source image is imported", importerJob.Name)
+	} else if !imported && existsImporterJob {
+		// 임포터잡이 실행 중이니 실패한 파드를 기록한다. 재시도 횟수를 넘겨 잡이 실패하면 에러를 반환한다
+		return r.syncJobFailures(importerJob)
+	} else if !imported && !existsImporterJob && r.isChecksumVerified() {
+		// 임포팅을 해야 하므로 임포터잡을 만든다. 잡이 실패했으면 재시도 요청 전까지 만들지 않는다
+		if err := r.getJobFailedError(); err != nil {
 			return err
 		}
-	} else if !imported && !existsImporterPod {
-		// 임포팅을 해야 하므로 임포터파드를 만든다
-		klog.Infof("syncImporterPod create new importerPod for vmi %s", r.vmi.Name)
-		newPod, err := r.newImporterPod()
+		klog.Infof("syncImporterJob create new importerJob for vmi %s", r.vmi.Name)
+		newJob, err := r.newImporterJob()
 		if err != nil {
 			return err
 		}
-		if err := r.client.Create(context.TODO(), newPod); err != nil && !errors.IsAlreadyExists(err) {
+		if err := r.client.Create(context.TODO(), newJob); err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobStarted, "Started importer job %s", newJob.Name)
 	}
 	return nil
 }
 
-func isPodCompleted(pod *corev1.Pod) bool {
-	return len(pod.Status.ContainerStatuses) != 0 &&
-		pod.Status.ContainerStatuses[0].State.Terminated != nil &&
-		pod.Status.ContainerStatuses[0].State.Terminated.Reason == "Completed"
+// getJobResult returns the termination message of the last container of the succeeded pod of the job
+func getJobResult(c client.Client, job *batchv1.Job) (string, error) {
+	pods, err := util.ListJobPods(c, job.Namespace, job.Name)
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded && len(pod.Status.ContainerStatuses) != 0 {
+			if terminated := pod.Status.ContainerStatuses[len(pod.Status.ContainerStatuses)-1].State.Terminated; terminated != nil {
+				return terminated.Message, nil
+			}
+		}
+	}
+	return "", goerrors.New("succeeded pod of job " + job.Name + " is not found")
+}
+
+// parseTerminationMessage parses the termination message which consists of key=value lines
+func parseTerminationMessage(message string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(message, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) == 2 {
+			values[kv[0]] = kv[1]
+		}
+	}
+	return values
 }
 
-// GetImporterPodNameFromVmiName returns ImporterPod name from VmiName
-func GetImporterPodNameFromVmiName(vmiName string) string {
+// GetImporterJobNameFromVmiName returns ImporterJob name from VmiName
+func GetImporterJobNameFromVmiName(vmiName string) string {
 	return vmiName + "-image-importer"
 }
 
+// GetImporterPod returns the running or pending pod of the importer job of the vmi, or nil if there is no such pod
+func GetImporterPod(c client.Reader, namespace, vmiName string) (*corev1.Pod, error) {
+	return util.GetActiveJobPod(c, namespace, GetImporterJobNameFromVmiName(vmiName))
+}
+
+// newImporterJob returns the job which runs the importer pod. The failed pod is retried up to the max retries
+func (r *ReconcileVirtualMachineImage) newImporterJob() (*batchv1.Job, error) {
+	ip, err := r.newImporterPod()
+	if err != nil {
+		return nil, err
+	}
+	job := util.NewJob(ip, r.getMaxRetries(), r.config)
+	if err := controllerutil.SetControllerReference(r.vmi, job, r.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newImporterPod returns the template of the importer job which imports the source image into the pvc.
+// The fetcher init container fetches the source image into the scratch pvc unpacking it if it is compressed or archived,
+// and the converter container converts it to raw and writes it to the pvc. The raw source image is streamed into the pvc by the fetcher container
 func (r *ReconcileVirtualMachineImage) newImporterPod() (*corev1.Pod, error) {
 	ip := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      GetImporterPodNameFromVmiName(r.vmi.Name),
+			Name:      GetImporterJobNameFromVmiName(r.vmi.Name),
 			Namespace: r.vmi.Namespace,
+			Labels:    map[string]string{VmiNameLabel: r.vmi.Name},
 		},
 		Spec: corev1.PodSpec{
-			RestartPolicy: corev1.RestartPolicyOnFailure,
+			RestartPolicy: corev1.RestartPolicyNever,
 			Containers: []corev1.Container{
 				{
-					Name:  "importer",
-					Image: ImportPodImage,
+					Name:                     "importer",
+					Image:                    util.GetImageOrDefault(r.config.Images.Importer, ImportPodImage),
+					Command:                  []string{"/bin/sh", "-c", ConverterScript},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 					Resources: corev1.ResourceRequirements{
 						Limits: map[corev1.ResourceName]resource.Quantity{
 							corev1.ResourceCPU:    resource.MustParse("0"),
@@ -113,9 +239,6 @@ func (r *ReconcileVirtualMachineImage) newImporterPod() (*corev1.Pod, error) {
 							corev1.ResourceCPU:    resource.MustParse("0"),
 							corev1.ResourceMemory: resource.MustParse("0")},
 					},
-					VolumeDevices: []corev1.VolumeDevice{
-						{Name: DataVolName, DevicePath: WriteBlockPath},
-					},
 				},
 			},
 			Volumes: []corev1.Volume{
@@ -138,20 +261,10 @@ func (r *ReconcileVirtualMachineImage) newImporterPod() (*corev1.Pod, error) {
 	if err != nil {
 		return nil, err
 	}
-	if src == SourceHTTP {
-		pvcSize := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
-
-		ip.Spec.Containers[0].Args = []string{"-v=" + ImportPodVerbose}
-		ip.Spec.Containers[0].Env = []corev1.EnvVar{
-			{Name: ImporterSource, Value: SourceHTTP},
-			{Name: ImporterEndpoint, Value: r.vmi.Spec.Source.HTTP},
-			{Name: ImporterContentType, Value: ImageContentType},
-			{Name: ImporterImageSize, Value: pvcSize.String()},
-			{Name: InsecureTLSVar, Value: "true"},
-		}
-	} else if src == SourceHostPath {
+	// The Block-mode pvc is written as the device, and the Filesystem-mode pvc is written as disk.img in it
+	destination := util.AttachDiskVolume(&ip.Spec.Containers[0], DataVolName, r.vmi.Spec.PVC.VolumeMode, WriteBlockPath, WriteFilesystemPath, false)
+	if src == SourceHostPath {
 		ip.Spec.NodeName = r.vmi.Spec.Source.HostPath.NodeName
-		ip.Spec.Containers[0].Command = []string{"qemu-img", "convert", "-f", "qcow2", "-O", "raw", SourceVolumeMountPath + "/disk.img", WriteBlockPath}
 		ip.Spec.Volumes = append(ip.Spec.Volumes, corev1.Volume{
 			Name: SourceVolumeName,
 			VolumeSource: corev1.VolumeSource{
@@ -159,11 +272,75 @@ func (r *ReconcileVirtualMachineImage) newImporterPod() (*corev1.Pod, error) {
 					Path: r.vmi.Spec.Source.HostPath.Path,
 				}},
 		})
-		ip.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
-			{Name: SourceVolumeName, MountPath: SourceVolumeMountPath}}
+		ip.Spec.Containers[0].VolumeMounts = append(ip.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true})
 	}
-	if err := controllerutil.SetControllerReference(r.vmi, ip, r.scheme); err != nil {
+	if r.isStreamedSource(src) {
+		// raw 소스 이미지는 scratch pvc 없이 pvc에 바로 쓴다
+		r.setStreamer(ip, src, destination)
+		util.ApplyConfigToPod(ip, r.config)
+		return ip, nil
+	}
+
+	ip.Spec.Volumes = append(ip.Spec.Volumes, corev1.Volume{
+		Name: ScratchVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: getScratchPvcNameFromVmiName(r.vmi.Name),
+			}},
+	})
+	ip.Spec.Containers[0].VolumeMounts = append(ip.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name: ScratchVolumeName, MountPath: ScratchVolumeMountPath})
+	if err := r.setFetcher(ip, src); err != nil {
 		return nil, err
 	}
+	sourceFile := ScratchVolumeMountPath + "/" + ScratchDataSubPath + "/" + FetchedImageFile
+	ip.Spec.Containers[0].Env = r.newConverterEnv(sourceFile, destination)
+	util.ApplyConfigToPod(ip, r.config)
 	return ip, nil
 }
+
+// newCdiImporterEnv returns the environment variables of the cdi importer which imports the image from endpoint
+func (r *ReconcileVirtualMachineImage) newCdiImporterEnv(source, endpoint string) []corev1.EnvVar {
+	pvcSize := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	return []corev1.EnvVar{
+		{Name: ImporterSource, Value: source},
+		{Name: ImporterEndpoint, Value: endpoint},
+		{Name: ImporterContentType, Value: ImageContentType},
+		{Name: ImporterImageSize, Value: pvcSize.String()},
+		{Name: InsecureTLSVar, Value: strconv.FormatBool(r.vmi.Spec.Source.InsecureSkipTLSVerify)},
+	}
+}
+
+// GetS3Endpoint returns the path-style url of the s3 object, {endpoint}/{bucket}/{key}
+func GetS3Endpoint(s3 *hc.VirtualMachineImageSourceS3) string {
+	return strings.TrimSuffix(s3.Endpoint, "/") + "/" + s3.Bucket + "/" + strings.TrimPrefix(s3.Key, "/")
+}
+
+// GetRegistryEndpoint returns the image reference of the containerDisk with docker:// transport
+func GetRegistryEndpoint(registry *hc.VirtualMachineImageSourceRegistry) string {
+	if strings.Contains(registry.URL, "://") {
+		return registry.URL
+	}
+	return RegistryTransport + registry.URL
+}
+
+func newSecretKeyEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: secretName,
+				},
+				Key: key,
+			},
+		},
+	}
+}
+
+func newOptionalSecretKeyEnvVar(name, secretName, key string) corev1.EnvVar {
+	env := newSecretKeyEnvVar(name, secretName, key)
+	env.ValueFrom.SecretKeyRef.Optional = &[]bool{true}[0]
+	return env
+}
@@ -4,151 +4,531 @@ import (
 	"context"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 )
 
-// 번호		pvc		imported		importPod		importPodState
+// newTestJob returns the job with the condition of conditionType, or the running job if conditionType is empty
+func newTestJob(name string, conditionType batchv1.JobConditionType, failed int32) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testVmiNs,
+		},
+		Status: batchv1.JobStatus{Failed: failed},
+	}
+	if conditionType != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+	}
+	if conditionType == batchv1.JobFailed {
+		job.Status.Conditions[0].Reason = "BackoffLimitExceeded"
+		job.Status.Conditions[0].Message = "Job has reached the specified backoff limit"
+	}
+	return job
+}
+
+// newTestJobPod returns the pod of the job with the phase and the container statuses
+func newTestJobPod(jobName, podName string, phase corev1.PodPhase, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: testVmiNs,
+			Labels:    map[string]string{util.JobNameLabel: jobName},
+		},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+// newTestCompletedJob returns the completed job and its pod which wrote message to the termination log
+func newTestCompletedJob(jobName, message string) (*batchv1.Job, *corev1.Pod) {
+	return newTestJob(jobName, batchv1.JobComplete, 0), newTestJobPod(jobName, jobName+"-abcde", corev1.PodSucceeded, corev1.ContainerStatus{
+		State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{
+				Reason:  "Completed",
+				Message: message,
+			},
+		},
+	})
+}
+
+// getTestJob returns the job of jobName in the namespace of the vmi
+func getTestJob(r *ReconcileVirtualMachineImage, jobName string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: jobName}, job)
+	return job, err
+}
+
+// 번호		pvc		imported		importJob		importJobState
 // 1		X
 // 2		O		yes				X
 // 3		O		no				X
 // 4		O		no				O				Running
 // 5		O		no				O				Complete
-var _ = Describe("syncImporterPod", func() {
+// 6		O		no				X								(checksum not verified)
+var _ = Describe("syncImporterJob", func() {
+	getImporterJob := func(r *ReconcileVirtualMachineImage) error {
+		return r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)}, &batchv1.Job{})
+	}
+
 	Context("1. with no pvc", func() {
 		r := createFakeReconcileVmi()
-		err := r.syncImporterPod()
+		err := r.syncImporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not create importerPod", func() {
-			importerPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
-			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		It("Should not create importerJob", func() {
+			Expect(errors.IsNotFound(getImporterJob(r))).Should(BeTrue())
 		})
 	})
 
-	Context("2. with pvc, imported=yes, no importerPod", func() {
-		importedPvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetPvcNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
-				Annotations: map[string]string{
-					"imported": "yes",
-				},
-			},
-		}
-		r := createFakeReconcileVmi(importedPvc)
-		err := r.syncImporterPod()
+	Context("2. with pvc, imported=yes, no importerJob", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("yes"))
+		err := r.syncImporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create importerJob", func() {
+			Expect(errors.IsNotFound(getImporterJob(r))).Should(BeTrue())
+		})
+	})
+
+	Context("3. with pvc, imported=no, no importerJob", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		err := r.syncImporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create importerJob with the backoff limit and the template of importerPod", func() {
+			job := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)}, job)
+			Expect(err).Should(BeNil())
+			Expect(*job.Spec.BackoffLimit).Should(Equal(int32(DefaultMaxRetries)))
+			Expect(*job.Spec.ActiveDeadlineSeconds).Should(Equal(int64(util.DefaultJobActiveDeadlineSeconds)))
+			Expect(*job.Spec.TTLSecondsAfterFinished).Should(Equal(int32(util.DefaultJobTTLSecondsAfterFinished)))
+			Expect(job.Spec.Template.Spec.RestartPolicy).Should(Equal(corev1.RestartPolicyNever))
+			Expect(job.Spec.Template.Labels).Should(HaveKeyWithValue(VmiNameLabel, r.vmi.Name))
+			Expect(metav1.IsControlledBy(job, r.vmi)).Should(BeTrue())
+		})
+	})
+
+	Context("4. with pvc, imported=no, importerJob with running", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newTestJob(GetImporterJobNameFromVmiName(testVmiName), "", 0))
+		err := r.syncImporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not create importerPod", func() {
-			importerPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
-			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		It("Should not delete importerJob", func() {
+			Expect(getImporterJob(r)).Should(Succeed())
 		})
 	})
 
-	Context("3. with pvc, imported=no, no importerPod", func() {
-		notImportedPvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetPvcNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
-				Annotations: map[string]string{
-					"imported": "no",
+	Context("5. with pvc, imported=no, importerJob with complete", func() {
+		jobName := GetImporterJobNameFromVmiName(testVmiName)
+		succeededPod := newTestJobPod(jobName, jobName+"-abcde", corev1.PodSucceeded, corev1.ContainerStatus{
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					Reason:  "Completed",
+					Message: "format=qcow2\nvirtualSize=1073741824\n",
 				},
 			},
-		}
-		r := createFakeReconcileVmi(notImportedPvc)
-		err := r.syncImporterPod()
+		})
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newTestJob(jobName, batchv1.JobComplete, 0), succeededPod)
+		err := r.syncImporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should create importerPod", func() {
-			importerPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
+		It("Should delete importerJob", func() {
+			Expect(errors.IsNotFound(getImporterJob(r))).Should(BeTrue())
+		})
+		It("Should update pvc annotation(imported=true)", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetPvcNameFromVmiName(r.vmi.Name)}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Annotations["imported"]).Should(Equal("yes"))
+		})
+		It("Should record the format and the virtual size", func() {
+			vmi := &hc.VirtualMachineImage{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
 			Expect(err).Should(BeNil())
+			Expect(vmi.Status.Format).Should(Equal(hc.VirtualMachineImageFormatQcow2))
+			Expect(vmi.Status.VirtualSize.Value()).Should(Equal(int64(1073741824)))
 		})
 	})
 
-	Context("4. with pvc, imported=no, importerPod with running", func() {
-		notImportedPvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetPvcNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
-				Annotations: map[string]string{
-					"imported": "no",
+	Context("6. with pvc, imported=no, no importerJob, checksum is not verified", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		r.vmi.Spec.Source.Checksum = &hc.VirtualMachineImageSourceChecksum{SHA256: testSHA256}
+		err := r.syncImporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create importerJob", func() {
+			Expect(errors.IsNotFound(getImporterJob(r))).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("newImporterPod", func() {
+	Context("1. with s3 source", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			S3: &hc.VirtualMachineImageSourceS3{
+				Endpoint:      "http://minio.default:9000/",
+				Bucket:        "images",
+				Key:           "/ubuntu/disk.img",
+				SecretRef:     "minio-secret",
+				CertConfigMap: "minio-ca",
+			},
+		}
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should fetch path-style endpoint", func() {
+			env := ip.Spec.InitContainers[0].Env
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: "http://minio.default:9000/images/ubuntu/disk.img"}))
+		})
+		It("Should unpack the source image while fetching", func() {
+			Expect(ip.Spec.InitContainers[0].Command[2]).Should(ContainSubstring("| unpack " + FetcherDataPath + "/" + FetchedImageFile))
+		})
+		It("Should get credentials from the secret", func() {
+			env := ip.Spec.InitContainers[0].Env
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(AccessKeyID, "minio-secret", AccessKeyID)))
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(SecretAccessKey, "minio-secret", SecretAccessKey)))
+			Expect(ip.Spec.InitContainers[0].Command[2]).Should(ContainSubstring("--aws-sigv4"))
+		})
+		It("Should verify TLS certificate with the CA bundle", func() {
+			Expect(ip.Spec.InitContainers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: CertVolumeName, MountPath: CertVolumeMountPath, ReadOnly: true}))
+			Expect(ip.Spec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: CertVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "minio-ca"},
+					},
 				},
+			}))
+		})
+	})
+
+	Context("2. with s3 source without secretRef", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			S3: &hc.VirtualMachineImageSourceS3{
+				Endpoint: "http://minio.default:9000",
+				Bucket:   "images",
+				Key:      "disk.img",
 			},
 		}
-		importerPod := &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetImporterPodNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not set credentials", func() {
+			for _, env := range ip.Spec.InitContainers[0].Env {
+				Expect(env.Name).ShouldNot(Equal(AccessKeyID))
+				Expect(env.Name).ShouldNot(Equal(SecretAccessKey))
+			}
+			Expect(ip.Spec.InitContainers[0].Command[2]).ShouldNot(ContainSubstring("--aws-sigv4"))
+		})
+	})
+
+	Context("3. with multiple sources", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source.S3 = &hc.VirtualMachineImageSourceS3{
+			Endpoint: "http://minio.default:9000",
+			Bucket:   "images",
+			Key:      "disk.img",
+		}
+		_, err := r.newImporterPod()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("4. with registry source", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			Registry: &hc.VirtualMachineImageSourceRegistry{
+				URL:           "localhost:5000/cirros-container-disk:latest",
+				SecretRef:     "registry-secret",
+				CertConfigMap: "registry-ca",
 			},
 		}
-		r := createFakeReconcileVmi(notImportedPvc, importerPod)
-		err := r.syncImporterPod()
+		ip, err := r.newImporterPod()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not delete importerPod", func() {
-			importerPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
+		It("Should set registry source with docker transport", func() {
+			env := ip.Spec.InitContainers[0].Env
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: ImporterSource, Value: SourceRegistry}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: ImporterEndpoint, Value: "docker://localhost:5000/cirros-container-disk:latest"}))
+		})
+		It("Should verify TLS certificate", func() {
+			Expect(ip.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{Name: InsecureTLSVar, Value: "false"}))
+		})
+		It("Should get credentials from the basic-auth secret", func() {
+			env := ip.Spec.InitContainers[0].Env
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(ImporterAccessKeyID, "registry-secret", corev1.BasicAuthUsernameKey)))
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(ImporterSecretKey, "registry-secret", corev1.BasicAuthPasswordKey)))
+		})
+		It("Should mount the CA bundle", func() {
+			Expect(ip.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{Name: ImporterCertDir, Value: CertVolumeMountPath}))
+			Expect(ip.Spec.InitContainers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: CertVolumeName, MountPath: CertVolumeMountPath, ReadOnly: true}))
+			Expect(ip.Spec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: CertVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "registry-ca"},
+					},
+				},
+			}))
+		})
+	})
+	Context("5. with upload source", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{Upload: &hc.VirtualMachineImageSourceUpload{}}
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
+		It("Should run the upload server as a fetcher", func() {
+			Expect(ip.Spec.InitContainers[0].Image).Should(Equal(UploadServerImage))
+			Expect(ip.Spec.InitContainers[0].Ports[0].ContainerPort).Should(Equal(int32(UploadServerPort)))
+			Expect(ip.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{Name: UploadServerDestination, Value: FetcherDataPath + "/" + FetchedImageFile}))
+		})
+		It("Should serve https and accept only the client certificate", func() {
+			env := ip.Spec.InitContainers[0].Env
+			certSecret := GetUploadServerCertSecretNameFromVmiName(testVmiName)
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(UploadServerTLSCert, certSecret, corev1.TLSCertKey)))
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(UploadServerTLSKey, certSecret, corev1.TLSPrivateKeyKey)))
+			Expect(env).Should(ContainElement(newSecretKeyEnvVar(UploadServerClientCert, certSecret, UploadClientCertKey)))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: UploadServerClientName, Value: UploadClientName}))
+		})
+		It("Should convert the fetched image in the scratch pvc", func() {
+			Expect(ip.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: ScratchVolumeName, MountPath: ScratchVolumeMountPath}))
+			Expect(ip.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: ConverterSourceFile, Value: "/scratch/data/disk.img"}))
+		})
 	})
 
-	Context("5. with pvc, imported=no, importerPod with complete", func() {
-		notImportedPvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetPvcNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
-				Annotations: map[string]string{
-					"imported": "no",
-				},
-			},
+	Context("6. with hostPath source and format", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			HostPath: &hc.VirtualMachineImageSourceHostPath{Path: "/mnt/data", NodeName: "node1"},
+			Format:   hc.VirtualMachineImageFormatVmdk,
 		}
-		importerPod := &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetImporterPodNameFromVmiName(testVmiName),
-				Namespace: testVmiNs,
-			},
-			Status: corev1.PodStatus{
-				ContainerStatuses: []corev1.ContainerStatus{
-					{
-						State: corev1.ContainerState{
-							Terminated: &corev1.ContainerStateTerminated{
-								Reason: "Completed",
-							},
-						},
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should unpack the source image in the host path into the scratch pvc", func() {
+			Expect(ip.Spec.InitContainers).Should(HaveLen(1))
+			fetcher := ip.Spec.InitContainers[0]
+			Expect(fetcher.Image).Should(Equal(FetcherImage))
+			Expect(fetcher.Command[2]).Should(ContainSubstring("unpack " + FetcherDataPath + "/" + FetchedImageFile))
+			Expect(fetcher.Env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: SourceVolumeMountPath + "/disk.img"}))
+			Expect(fetcher.VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true}))
+			Expect(ip.Spec.NodeName).Should(Equal("node1"))
+		})
+		It("Should convert the fetched source image with the format", func() {
+			env := ip.Spec.Containers[0].Env
+			Expect(ip.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: ConverterSourceFile, Value: ScratchVolumeMountPath + "/data/disk.img"}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: ConverterSourceFormat, Value: "vmdk"}))
+			Expect(env).Should(ContainElement(corev1.EnvVar{Name: ConverterImageSize, Value: "3221225472"}))
+		})
+	})
+	Context("7. with http source and httpOptions", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source.HTTPOptions = &hc.VirtualMachineImageSourceHTTPOptions{
+			SecretRef:     "http-secret",
+			CertConfigMap: "http-ca",
+			ExtraHeaders:  []string{"X-Api-Version: 2", "X-Team: infra"},
+		}
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should verify TLS certificate with the CA bundle", func() {
+			fetcher := ip.Spec.InitContainers[0]
+			Expect(fetcher.Command[2]).ShouldNot(ContainSubstring("curl -sSfLk"))
+			Expect(fetcher.Env).Should(ContainElement(corev1.EnvVar{Name: InsecureTLSVar, Value: "false"}))
+			Expect(fetcher.VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: CertVolumeName, MountPath: CertVolumeMountPath, ReadOnly: true}))
+			Expect(ip.Spec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: CertVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "http-ca"},
 					},
 				},
+			}))
+		})
+		It("Should get credentials and extra headers from the secret", func() {
+			env := ip.Spec.InitContainers[0].Env
+			Expect(env).Should(ContainElement(newOptionalSecretKeyEnvVar(HTTPUsernameVar, "http-secret", corev1.BasicAuthUsernameKey)))
+			Expect(env).Should(ContainElement(newOptionalSecretKeyEnvVar(HTTPPasswordVar, "http-secret", corev1.BasicAuthPasswordKey)))
+			Expect(env).Should(ContainElement(newOptionalSecretKeyEnvVar(HTTPTokenVar, "http-secret", HTTPSecretTokenKey)))
+			Expect(env).Should(ContainElement(newOptionalSecretKeyEnvVar(HTTPSecretExtraHeadersVar, "http-secret", HTTPSecretExtraHeadersKey)))
+		})
+		It("Should set extra headers", func() {
+			Expect(ip.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{Name: HTTPExtraHeadersVar, Value: "X-Api-Version: 2\nX-Team: infra"}))
+		})
+	})
+
+	Context("8. with http source and insecureSkipTLSVerify", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source.InsecureSkipTLSVerify = true
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should skip TLS verification", func() {
+			Expect(ip.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{Name: InsecureTLSVar, Value: "true"}))
+		})
+	})
+	Context("9. with KubevirtImageServiceConfig", func() {
+		r := createFakeReconcileVmi()
+		verbosity := int32(3)
+		r.config = hc.KubevirtImageServiceConfigSpec{
+			Images: hc.KubevirtImageServiceConfigImages{
+				Importer: "registry.local/cdi-importer:v1.13.0",
+				Fetcher:  "registry.local/curl:7.75.0",
 			},
+			ImagePullPolicy:  corev1.PullAlways,
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "mirror-secret"}},
+			LogVerbosity:     &verbosity,
 		}
-		r := createFakeReconcileVmi(notImportedPvc, importerPod)
-		err := r.syncImporterPod()
+		ip, err := r.newImporterPod()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should delete importerPod", func() {
-			importerPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterPodNameFromVmiName(r.vmi.Name)}, importerPod)
-			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		It("Should use the images of the config", func() {
+			Expect(ip.Spec.Containers[0].Image).Should(Equal("registry.local/cdi-importer:v1.13.0"))
+			Expect(ip.Spec.InitContainers[0].Image).Should(Equal("registry.local/curl:7.75.0"))
 		})
-		It("Should update pvc annotation(imported=true)", func() {
-			pvc := &corev1.PersistentVolumeClaim{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetPvcNameFromVmiName(r.vmi.Name)}, pvc)
+		It("Should use the image pull policy and secrets of the config", func() {
+			Expect(ip.Spec.Containers[0].ImagePullPolicy).Should(Equal(corev1.PullAlways))
+			Expect(ip.Spec.InitContainers[0].ImagePullPolicy).Should(Equal(corev1.PullAlways))
+			Expect(ip.Spec.ImagePullSecrets).Should(Equal([]corev1.LocalObjectReference{{Name: "mirror-secret"}}))
+		})
+	})
+
+	Context("10. with registry source and log verbosity of KubevirtImageServiceConfig", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{Registry: &hc.VirtualMachineImageSourceRegistry{URL: "localhost:5000/cirros-container-disk:latest"}}
+		verbosity := int32(3)
+		r.config = hc.KubevirtImageServiceConfigSpec{LogVerbosity: &verbosity}
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
-			Expect(pvc.Annotations["imported"]).Should(Equal("yes"))
+		})
+		It("Should set the log verbosity of the importer", func() {
+			Expect(ip.Spec.InitContainers[0].Image).Should(Equal(ImportPodImage))
+			Expect(ip.Spec.InitContainers[0].Args).Should(Equal([]string{"-v=3"}))
+		})
+	})
+
+	Context("11. with Block-mode pvc", func() {
+		r := createFakeReconcileVmi()
+		volumeMode := corev1.PersistentVolumeBlock
+		r.vmi.Spec.PVC.VolumeMode = &volumeMode
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should write the image to the device of the pvc", func() {
+			Expect(ip.Spec.Containers[0].VolumeDevices).Should(ContainElement(corev1.VolumeDevice{Name: DataVolName, DevicePath: WriteBlockPath}))
+			Expect(ip.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: ConverterDestination, Value: WriteBlockPath}))
+		})
+	})
+
+	Context("12. with Filesystem-mode pvc", func() {
+		r := createFakeReconcileVmi()
+		volumeMode := corev1.PersistentVolumeFilesystem
+		r.vmi.Spec.PVC.VolumeMode = &volumeMode
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should write the image to disk.img in the pvc", func() {
+			Expect(ip.Spec.Containers[0].VolumeDevices).Should(BeEmpty())
+			Expect(ip.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: DataVolName, MountPath: WriteFilesystemPath}))
+			Expect(ip.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: ConverterDestination, Value: WriteFilesystemPath + "/disk.img"}))
+		})
+	})
+	Context("13. with http source with raw format and Block-mode pvc", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source.Format = hc.VirtualMachineImageFormatRaw
+		volumeMode := corev1.PersistentVolumeBlock
+		r.vmi.Spec.PVC.VolumeMode = &volumeMode
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should stream the source image into the device of the pvc without the scratch pvc", func() {
+			Expect(ip.Spec.InitContainers).Should(BeEmpty())
+			Expect(ip.Spec.Volumes).Should(HaveLen(1))
+			Expect(ip.Spec.Containers).Should(HaveLen(1))
+			streamer := ip.Spec.Containers[0]
+			Expect(streamer.Name).Should(Equal(FetcherContainerName))
+			Expect(streamer.Image).Should(Equal(FetcherImage))
+			Expect(streamer.VolumeDevices).Should(ContainElement(corev1.VolumeDevice{Name: DataVolName, DevicePath: WriteBlockPath}))
+			Expect(streamer.VolumeMounts).Should(BeEmpty())
+			Expect(streamer.Env).Should(ContainElement(corev1.EnvVar{Name: ConverterDestination, Value: WriteBlockPath}))
+			Expect(streamer.Env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: r.vmi.Spec.Source.HTTP}))
+			Expect(streamer.Command[2]).Should(ContainSubstring("streaming=true"))
+			Expect(streamer.Command[2]).Should(ContainSubstring(`| unpack "$` + ConverterDestination + `"`))
+		})
+	})
+
+	Context("14. with hostPath source with iso format", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{
+			HostPath: &hc.VirtualMachineImageSourceHostPath{Path: "/mnt/data", NodeName: "node1"},
+			Format:   hc.VirtualMachineImageFormatIso,
+		}
+		ip, err := r.newImporterPod()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should stream the source image in the host path into disk.img in the pvc", func() {
+			Expect(ip.Spec.InitContainers).Should(BeEmpty())
+			streamer := ip.Spec.Containers[0]
+			Expect(streamer.Name).Should(Equal(FetcherContainerName))
+			Expect(streamer.VolumeMounts).Should(ConsistOf(
+				corev1.VolumeMount{Name: DataVolName, MountPath: WriteFilesystemPath},
+				corev1.VolumeMount{Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true}))
+			Expect(streamer.Env).Should(ContainElement(corev1.EnvVar{Name: ConverterDestination, Value: WriteFilesystemPath + "/disk.img"}))
+			Expect(streamer.Env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: SourceVolumeMountPath + "/disk.img"}))
+			Expect(streamer.Env).Should(ContainElement(corev1.EnvVar{Name: ConverterSourceFormat, Value: "iso"}))
+			Expect(ip.Spec.NodeName).Should(Equal("node1"))
 		})
 	})
 })
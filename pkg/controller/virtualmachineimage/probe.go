@@ -0,0 +1,314 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"math"
+	"net/url"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
+)
+
+const (
+	// ProbeSourceFile provides a constant to capture our env variable "SOURCE_FILE" of the probe pod
+	ProbeSourceFile = "SOURCE_FILE"
+	// DefaultFilesystemOverhead is the fraction of the Filesystem-mode pvc reserved for the file system if it is not set in the config
+	DefaultFilesystemOverhead = "0.055"
+	// storageRequestAlignment is the unit which the storage request sized from the source image is rounded up to
+	storageRequestAlignment = 1024 * 1024
+	// ReasonStorageRequestSized is the reason of the event when the storage request of the image pvc is sized from the source
+	ReasonStorageRequestSized = "StorageRequestSized"
+)
+
+// ProbeScript writes the virtual size of SOURCE_FILE to the termination message. SOURCE_FILE is the path or the qemu-img filename of the url.
+// The virtual size of the compressed or archived source image can't be known without unpacking it, so the probe fails for it
+const ProbeScript = `set -e
+` + UnpackScript + `qemu-img dd -f raw -O raw bs=512 count=1 if="$SOURCE_FILE" of=/tmp/header || fail "failed to read source image"
+if [ -n "$(packing /tmp/header)" ]; then
+  fail "virtual size of the compressed or archived source image can't be probed. Set storage request in pvc"
+fi
+virtualSize=$(qemu-img info "$SOURCE_FILE" | sed -n 's/^virtual size: .*(\([0-9]*\) bytes)$/\1/p')
+[ -n "$virtualSize" ] || fail "failed to probe virtual size of source image"
+echo "` + converterVirtualSizeKey + `=$virtualSize" > /dev/termination-log
+`
+
+// syncStorageRequest sizes the image pvc if the storage request is not set in spec.pvc. The storage request is taken from the size of
+// the pvc or VirtualMachineVolume source, or the virtual size of the http or hostPath source image probed by the probe job, and recorded in the status.
+// Like setConfigDefaults, it is set to the spec of r.vmi which is not persisted, so the pvcs and the worker pods use it
+func (r *ReconcileVirtualMachineImage) syncStorageRequest() error {
+	if _, found := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]; found {
+		return nil
+	}
+
+	if r.vmi.Status.StorageRequest == nil {
+		// 스토리지 요청 크기를 모르니 소스로부터 구한다. 프로브잡이 끝나지 않았으면 pvc를 만들지 않고 기다린다
+		storageRequest, err := r.getSourceStorageRequest()
+		if err != nil || storageRequest == nil {
+			return err
+		}
+		klog.Infof("Storage request of vmi %s is sized to %s", r.vmi.Name, storageRequest.String())
+		r.vmi.Status.StorageRequest = storageRequest
+		if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, ReasonStorageRequestSized, "Storage request of the image pvc is sized to %s", storageRequest.String())
+	}
+	if r.vmi.Spec.PVC.Resources.Requests == nil {
+		r.vmi.Spec.PVC.Resources.Requests = corev1.ResourceList{}
+	}
+	r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage] = *r.vmi.Status.StorageRequest
+
+	// 크기를 구했으니 프로브잡을 삭제한다
+	probeJob := &batchv1.Job{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetProbeJobNameFromVmiName(r.vmi.Name)}, probeJob); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := util.DeleteJob(r.client, probeJob); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// hasStorageRequest returns true if the storage request of the image pvc is set in spec.pvc or sized from the source
+func (r *ReconcileVirtualMachineImage) hasStorageRequest() bool {
+	_, found := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	return found
+}
+
+// GetStorageRequest returns the storage request of the image pvc which is set in spec.pvc or sized from the source
+func GetStorageRequest(vmi *hc.VirtualMachineImage) (resource.Quantity, bool) {
+	if storageRequest, found := vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]; found {
+		return storageRequest, true
+	}
+	if vmi.Status.StorageRequest != nil {
+		return *vmi.Status.StorageRequest, true
+	}
+	return resource.Quantity{}, false
+}
+
+// canSizeStorageRequest returns true if the storage request of the image pvc can be sized from the source
+func (r *ReconcileVirtualMachineImage) canSizeStorageRequest(src string) bool {
+	switch src {
+	case SourcePVC, SourceVolume, SourceHostPath:
+		return true
+	case SourceHTTP:
+		// qemu-img can't read the http source with the credentials, the extra headers or the CA bundle
+		return r.vmi.Spec.Source.HTTPOptions == nil
+	}
+	return false
+}
+
+// getSourceStorageRequest returns the storage request to store the source in the image pvc, or nil if the probe job is not completed
+func (r *ReconcileVirtualMachineImage) getSourceStorageRequest() (*resource.Quantity, error) {
+	src, err := r.getSource()
+	if err != nil {
+		return nil, err
+	}
+	switch src {
+	case SourcePVC:
+		sourcePvc, err := r.getSourcePvc()
+		if err != nil {
+			return nil, err
+		}
+		size, found := sourcePvc.Status.Capacity[corev1.ResourceStorage]
+		if !found {
+			size = sourcePvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		}
+		if util.GetVolumeMode(sourcePvc.Spec.VolumeMode) == util.GetVolumeMode(r.vmi.Spec.PVC.VolumeMode) {
+			return &size, nil
+		}
+		return r.getDiskStorageRequest(size.Value())
+	case SourceVolume:
+		// 캡처하는 볼륨은 이미지와 볼륨 모드가 같으므로 볼륨의 크기를 그대로 쓴다
+		volume := &hc.VirtualMachineVolume{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Spec.Source.VirtualMachineVolume.Name}, volume); err != nil {
+			return nil, err
+		}
+		size := volume.Spec.Capacity[corev1.ResourceStorage]
+		return &size, nil
+	case SourceHTTP, SourceHostPath:
+		virtualSize, err := r.syncProbeJob()
+		if err != nil || virtualSize == nil {
+			return nil, err
+		}
+		return r.getDiskStorageRequest(virtualSize.Value())
+	}
+	return nil, goerrors.New("storage request in pvc is missing. It can be sized only for http, hostPath, pvc and virtualMachineVolume sources")
+}
+
+// getDiskStorageRequest returns the storage request of the image pvc to store the disk of size bytes
+func (r *ReconcileVirtualMachineImage) getDiskStorageRequest(size int64) (*resource.Quantity, error) {
+	overhead, err := r.getFilesystemOverhead()
+	if err != nil {
+		return nil, err
+	}
+	return getRequiredStorage(size, r.vmi.Spec.PVC.VolumeMode, overhead), nil
+}
+
+// getFilesystemOverhead returns the fraction of the Filesystem-mode pvc reserved for the file system
+func (r *ReconcileVirtualMachineImage) getFilesystemOverhead() (float64, error) {
+	value := r.config.FilesystemOverhead
+	if value == "" {
+		value = DefaultFilesystemOverhead
+	}
+	overhead, err := strconv.ParseFloat(value, 64)
+	if err != nil || overhead < 0 || overhead >= 1 {
+		return 0, goerrors.New("filesystemOverhead of KubevirtImageServiceConfig must be a decimal less than 1, but " + value)
+	}
+	return overhead, nil
+}
+
+// getRequiredStorage returns the storage request of the pvc of volumeMode to store the disk of size bytes, rounded up to MiB.
+// The Filesystem-mode pvc reserves the fraction of overhead for the file system
+func getRequiredStorage(size int64, volumeMode *corev1.PersistentVolumeMode, overhead float64) *resource.Quantity {
+	required := float64(size)
+	if !util.IsBlockVolumeMode(volumeMode) {
+		required = math.Ceil(required / (1 - overhead))
+	}
+	aligned := int64(math.Ceil(required/storageRequestAlignment)) * storageRequestAlignment
+	return resource.NewQuantity(aligned, resource.BinarySI)
+}
+
+// syncProbeJob runs the probe job and returns the virtual size of the source image, or nil if the probe job is not completed
+func (r *ReconcileVirtualMachineImage) syncProbeJob() (*resource.Quantity, error) {
+	probeJob := &batchv1.Job{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetProbeJobNameFromVmiName(r.vmi.Name)}, probeJob); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		// 소스 이미지의 크기를 알아야 하므로 프로브잡을 만든다. 잡이 실패했으면 재시도 요청 전까지 만들지 않는다
+		if err := r.getJobFailedError(); err != nil {
+			return nil, err
+		}
+		klog.Infof("Create probe job for vmi %s", r.vmi.Name)
+		newJob, err := r.newProbeJob()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.client.Create(context.TODO(), newJob); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobStarted, "Started probe job %s", newJob.Name)
+		return nil, nil
+	}
+	if !util.IsJobCompleted(probeJob) {
+		// 프로브잡이 실행 중이니 실패한 파드를 기록한다. 재시도 횟수를 넘겨 잡이 실패하면 에러를 반환한다
+		return nil, r.syncJobFailures(probeJob)
+	}
+
+	result, err := getJobResult(r.client, probeJob)
+	if err != nil {
+		return nil, err
+	}
+	_, virtualSize := parseConverterResult(result)
+	if virtualSize == nil {
+		return nil, goerrors.New(fmt.Sprintf("virtual size of the source image is not found in the result of probe job, %q", result))
+	}
+	return virtualSize, nil
+}
+
+// GetProbeJobNameFromVmiName returns the name of the probe job from vmiName
+func GetProbeJobNameFromVmiName(vmiName string) string {
+	return vmiName + "-image-probe"
+}
+
+// newProbeJob returns the job which runs the probe pod. The failed pod is retried up to the max retries
+func (r *ReconcileVirtualMachineImage) newProbeJob() (*batchv1.Job, error) {
+	pod, err := r.newProbePod()
+	if err != nil {
+		return nil, err
+	}
+	job := util.NewJob(pod, r.getMaxRetries(), r.config)
+	if err := controllerutil.SetControllerReference(r.vmi, job, r.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newProbePod returns the template of the probe job which inspects the http or hostPath source image with qemu-img
+// and writes its virtual size to the termination message
+func (r *ReconcileVirtualMachineImage) newProbePod() (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetProbeJobNameFromVmiName(r.vmi.Name),
+			Namespace: r.vmi.Namespace,
+			Labels:    map[string]string{VmiNameLabel: r.vmi.Name},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:                     "probe",
+					Image:                    util.GetImageOrDefault(r.config.Images.Importer, ImportPodImage),
+					Command:                  []string{"/bin/sh", "-c", ProbeScript},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser: &[]int64{0}[0],
+			},
+		},
+	}
+
+	src, err := r.getSource()
+	if err != nil {
+		return nil, err
+	}
+	var sourceFile string
+	if src == SourceHTTP {
+		if sourceFile, err = getQemuImgURLFilename(r.vmi.Spec.Source.HTTP, r.vmi.Spec.Source.InsecureSkipTLSVerify); err != nil {
+			return nil, err
+		}
+	} else if src == SourceHostPath {
+		sourceFile = SourceVolumeMountPath + "/" + FetchedImageFile
+		pod.Spec.NodeName = r.vmi.Spec.Source.HostPath.NodeName
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: SourceVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: r.vmi.Spec.Source.HostPath.Path,
+					}},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{Name: SourceVolumeName, MountPath: SourceVolumeMountPath, ReadOnly: true}}
+	} else {
+		return nil, goerrors.New("probe is only supported for http and hostPath sources")
+	}
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: ProbeSourceFile, Value: sourceFile}}
+	util.ApplyConfigToPod(pod, r.config)
+	return pod, nil
+}
+
+// getQemuImgURLFilename returns the json filename of qemu-img which reads the http or https url by the curl block driver.
+// The TLS certificate verification of the https url is disabled if insecure is true
+func getQemuImgURLFilename(sourceURL string, insecure bool) (string, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return "", err
+	}
+	options := map[string]interface{}{"file.driver": u.Scheme, "file.url": sourceURL}
+	if u.Scheme == "https" && insecure {
+		options["file.sslverify"] = false
+	}
+	filename, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return "json:" + string(filename), nil
+}
@@ -0,0 +1,179 @@
+package virtualmachineimage
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+// 번호		source		storageRequest		probeJob		status.storageRequest
+// 1		http		O
+// 2		http		X					X
+// 3		http		X					Complete
+// 4		http		X					Running
+// 5		http		X										O
+// 6		pvc			X
+var _ = Describe("syncStorageRequest", func() {
+	Context("1. with storage request", func() {
+		r := createFakeReconcileVmi()
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create probe job", func() {
+			_, err := getTestJob(r, GetProbeJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should keep the storage request", func() {
+			Expect(r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]).Should(Equal(resource.MustParse("3Gi")))
+			Expect(r.vmi.Status.StorageRequest).Should(BeNil())
+		})
+	})
+
+	Context("2. without storage request, no probeJob", func() {
+		r := createFakeReconcileVmiWithoutStorageRequest()
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create probe job which reads the url by qemu-img", func() {
+			job, err := getTestJob(r, GetProbeJobNameFromVmiName(r.vmi.Name))
+			Expect(err).Should(BeNil())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: ProbeSourceFile,
+				Value: `json:{"file.driver":"https","file.url":"` + r.vmi.Spec.Source.HTTP + `"}`}))
+		})
+		It("Should not have storage request", func() {
+			Expect(r.hasStorageRequest()).Should(BeFalse())
+		})
+	})
+
+	Context("3. without storage request, completed probeJob", func() {
+		probeJob, probePod := newTestCompletedJob(GetProbeJobNameFromVmiName(testVmiName), "virtualSize=1073741824\n")
+		r := createFakeReconcileVmiWithoutStorageRequest(probeJob, probePod)
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the storage request with the overhead of the file system", func() {
+			vmi := &hc.VirtualMachineImage{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.StorageRequest.Value()).Should(Equal(int64(1084 * 1024 * 1024)))
+		})
+		It("Should set the storage request to the spec", func() {
+			Expect(r.hasStorageRequest()).Should(BeTrue())
+		})
+		It("Should delete probe job", func() {
+			_, err := getTestJob(r, GetProbeJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("4. without storage request, running probeJob", func() {
+		r := createFakeReconcileVmiWithoutStorageRequest(newTestJob(GetProbeJobNameFromVmiName(testVmiName), "", 0))
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not have storage request", func() {
+			Expect(r.hasStorageRequest()).Should(BeFalse())
+			Expect(r.vmi.Status.StorageRequest).Should(BeNil())
+		})
+	})
+
+	Context("5. without storage request, sized storage request in status", func() {
+		r := createFakeReconcileVmiWithoutStorageRequest()
+		r.vmi.Status.StorageRequest = resource.NewQuantity(5*1024*1024*1024, resource.BinarySI)
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should set the storage request of the status to the spec", func() {
+			storageRequest := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+			Expect(storageRequest.Value()).Should(Equal(int64(5 * 1024 * 1024 * 1024)))
+		})
+		It("Should not create probe job", func() {
+			_, err := getTestJob(r, GetProbeJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("6. without storage request, pvc source", func() {
+		sourcePvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sourcepvc", Namespace: testVmiNs},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			},
+		}
+		r := createFakeReconcileVmiWithoutStorageRequest(sourcePvc)
+		r.vmi.Spec.Source.HTTP = ""
+		r.vmi.Spec.Source.PVC = &hc.VirtualMachineImageSourcePVC{Name: sourcePvc.Name}
+		err := r.syncStorageRequest()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should set the size of the source pvc with the same volume mode", func() {
+			Expect(r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]).Should(Equal(resource.MustParse("5Gi")))
+		})
+		It("Should not create probe job", func() {
+			_, err := getTestJob(r, GetProbeJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("canSizeStorageRequest", func() {
+	r := createFakeReconcileVmi()
+
+	It("Should size the http source without httpOptions", func() {
+		Expect(r.canSizeStorageRequest(SourceHTTP)).Should(BeTrue())
+	})
+	It("Should not size the s3, registry and upload sources", func() {
+		Expect(r.canSizeStorageRequest(SourceS3)).Should(BeFalse())
+		Expect(r.canSizeStorageRequest(SourceRegistry)).Should(BeFalse())
+		Expect(r.canSizeStorageRequest(SourceUpload)).Should(BeFalse())
+	})
+})
+
+var _ = Describe("getRequiredStorage", func() {
+	block := corev1.PersistentVolumeBlock
+
+	It("Should round up the size of the Block-mode pvc to MiB", func() {
+		Expect(getRequiredStorage(1000, &block, 0.055).Value()).Should(Equal(int64(1024 * 1024)))
+	})
+	It("Should add the overhead to the Filesystem-mode pvc", func() {
+		Expect(getRequiredStorage(1024*1024*1024, nil, 0.055).Value()).Should(Equal(int64(1084 * 1024 * 1024)))
+	})
+})
+
+var _ = Describe("getQemuImgURLFilename", func() {
+	It("Should disable the TLS certificate verification of the insecure https url", func() {
+		filename, err := getQemuImgURLFilename("https://example.com/disk.img", true)
+		Expect(err).Should(BeNil())
+		Expect(filename).Should(Equal(`json:{"file.driver":"https","file.sslverify":false,"file.url":"https://example.com/disk.img"}`))
+	})
+	It("Should not set sslverify to the http url", func() {
+		filename, err := getQemuImgURLFilename("http://example.com/disk.img", true)
+		Expect(err).Should(BeNil())
+		Expect(filename).Should(Equal(`json:{"file.driver":"http","file.url":"http://example.com/disk.img"}`))
+	})
+})
+
+func createFakeReconcileVmiWithoutStorageRequest(objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	r := createFakeReconcileVmi(objects...)
+	delete(r.vmi.Spec.PVC.Resources.Requests, corev1.ResourceStorage)
+	return r
+}
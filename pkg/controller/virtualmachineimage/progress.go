@@ -0,0 +1,175 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ProgressReportInterval is the interval in seconds at which the importer pod writes the progress to the log
+	ProgressReportInterval = "5"
+	// ProgressSyncInterval is the interval at which the progress is read from the log of the importer pod while importing
+	ProgressSyncInterval = 10 * time.Second
+	progressKey          = "progress"
+	progressLogTailLines = 10
+)
+
+// ProgressScript defines report_fetch_progress shell function which writes the size of the fetched file $1 to the log periodically.
+// The source image streamed into the pvc is measured by the bytes which the writer has written, because the device has no file size.
+// The total is Content-Length in CurlHeadersFile unless the source image is packed, because the size of the packed source is not the size of the fetched file
+const ProgressScript = `report_fetch_progress() {
+  while sleep ` + ProgressReportInterval + `; do
+    total=0
+    if [ ! -e ` + PackedMarkerFile + ` ]; then
+      total=$(sed -n 's/^[Cc]ontent-[Ll]ength: *\([0-9]*\).*/\1/p' ` + CurlHeadersFile + ` 2>/dev/null | tail -n 1 || true)
+    fi
+    if [ -e ` + WriterPidFile + ` ]; then
+      transferred=$(sed -n 's/^wchar: *//p' "/proc/$(cat ` + WriterPidFile + `)/io" 2>/dev/null || true)
+    else
+      transferred=$(find "$1" "$1` + UnpackedSuffix + `" -type f -exec stat -c %s {} + 2>/dev/null | awk '{ s += $1 } END { printf "%d", s }' || true)
+    fi
+    echo "` + progressKey + `=${transferred:-0}/${total:-0}"
+  done
+}
+`
+
+// podLogReader returns the last tailLines lines of the log of the container in the pod
+type podLogReader func(namespace, name, container string, tailLines int64) (string, error)
+
+func newPodLogReader(clientset kubernetes.Interface) podLogReader {
+	return func(namespace, name, container string, tailLines int64) (string, error) {
+		log, err := clientset.CoreV1().Pods(namespace).GetLogs(name, &corev1.PodLogOptions{Container: container, TailLines: &tailLines}).DoRaw()
+		return string(log), err
+	}
+}
+
+// syncProgress reads the progress of the running importer pod from its log and records it in the status
+func (r *ReconcileVirtualMachineImage) syncProgress() error {
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	} else if !found {
+		return nil
+	}
+
+	if imported {
+		if r.vmi.Status.Progress != nil && r.vmi.Status.Progress.Phase == hc.VirtualMachineImageProgressPhaseCompleted {
+			return nil
+		}
+		// 임포팅이 완료됐으니 진행률을 완료로 기록한다
+		progress := &hc.VirtualMachineImageProgress{Phase: hc.VirtualMachineImageProgressPhaseCompleted, Percentage: "100.00%"}
+		if r.vmi.Status.VirtualSize != nil {
+			progress.TransferredBytes = r.vmi.Status.VirtualSize.Value()
+			progress.TotalBytes = r.vmi.Status.VirtualSize.Value()
+		}
+		return r.updateProgress(progress)
+	}
+
+	importerPod, err := GetImporterPod(r.client, r.vmi.Namespace, r.vmi.Name)
+	if err != nil {
+		return err
+	} else if importerPod == nil {
+		return nil
+	}
+	phase, container, startedAt := getImporterPodPhase(importerPod)
+	if phase == "" {
+		return nil
+	}
+
+	// 실행 중인 컨테이너의 로그에서 진행률을 읽는다. 로그를 읽지 못해도 임포팅에는 영향이 없다
+	var transferred, total int64
+	if r.readPodLog != nil {
+		log, err := r.readPodLog(importerPod.Namespace, importerPod.Name, container, progressLogTailLines)
+		if err != nil {
+			klog.Warningf("Failed to read progress of vmi %s: %s", r.vmi.Name, err)
+		} else {
+			transferred, total = parseProgress(log)
+		}
+	}
+	// 진행률이 그대로면 예상 완료 시간을 다시 계산하지 않는다. 상태를 쓸 때마다 vmi가 다시 큐에 들어가기 때문이다
+	if p := r.vmi.Status.Progress; p != nil && p.Phase == phase && p.TransferredBytes == transferred && p.TotalBytes == total {
+		return nil
+	}
+	return r.updateProgress(newProgress(phase, transferred, total, startedAt, time.Now()))
+}
+
+// isImporting returns true if the importer pod is running, so the progress should be read again
+func (r *ReconcileVirtualMachineImage) isImporting() bool {
+	return r.vmi.Status.State == hc.VirtualMachineImageStateCreating && r.vmi.Status.Progress != nil &&
+		r.vmi.Status.Progress.Phase != hc.VirtualMachineImageProgressPhaseCompleted
+}
+
+func (r *ReconcileVirtualMachineImage) updateProgress(progress *hc.VirtualMachineImageProgress) error {
+	if reflect.DeepEqual(r.vmi.Status.Progress, progress) {
+		return nil
+	}
+	r.vmi.Status.Progress = progress
+	return r.client.Status().Update(context.TODO(), r.vmi)
+}
+
+// getImporterPodPhase returns the phase of the import, the running container and its start time
+func getImporterPodPhase(pod *corev1.Pod) (hc.VirtualMachineImageProgressPhase, string, metav1.Time) {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == FetcherContainerName && status.State.Running != nil {
+			return hc.VirtualMachineImageProgressPhaseFetching, status.Name, status.State.Running.StartedAt
+		}
+	}
+	if len(pod.Status.ContainerStatuses) != 0 && pod.Status.ContainerStatuses[0].State.Running != nil {
+		status := pod.Status.ContainerStatuses[0]
+		if status.Name == FetcherContainerName {
+			// 스트리밍하는 소스 이미지는 변환 없이 페처가 pvc에 바로 쓴다
+			return hc.VirtualMachineImageProgressPhaseFetching, status.Name, status.State.Running.StartedAt
+		}
+		return hc.VirtualMachineImageProgressPhaseConverting, status.Name, status.State.Running.StartedAt
+	}
+	return "", "", metav1.Time{}
+}
+
+// parseProgress returns the transferred and the total bytes of the last "progress={transferred}/{total}" line of the log
+func parseProgress(log string) (transferred, total int64) {
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		value := strings.TrimPrefix(strings.TrimSpace(lines[i]), progressKey+"=")
+		if value == lines[i] {
+			continue
+		}
+		bytes := strings.SplitN(value, "/", 2)
+		if len(bytes) != 2 {
+			continue
+		}
+		transferred, err1 := strconv.ParseInt(bytes[0], 10, 64)
+		total, err2 := strconv.ParseInt(bytes[1], 10, 64)
+		if err1 == nil && err2 == nil {
+			return transferred, total
+		}
+	}
+	return 0, 0
+}
+
+// newProgress returns the progress of the phase. The estimated completion time assumes the rate since the phase started doesn't change
+func newProgress(phase hc.VirtualMachineImageProgressPhase, transferred, total int64, startedAt metav1.Time, now time.Time) *hc.VirtualMachineImageProgress {
+	progress := &hc.VirtualMachineImageProgress{Phase: phase, TransferredBytes: transferred}
+	if total <= 0 {
+		return progress
+	}
+	if transferred > total {
+		transferred = total
+	}
+	progress.TotalBytes = total
+	progress.Percentage = fmt.Sprintf("%.2f%%", float64(transferred)*100/float64(total))
+	if elapsed := now.Sub(startedAt.Time); transferred > 0 && elapsed > 0 {
+		remaining := time.Duration(float64(elapsed) * float64(total-transferred) / float64(transferred))
+		eta := metav1.NewTime(now.Add(remaining).Truncate(time.Second))
+		progress.EstimatedCompletionTime = &eta
+	}
+	return progress
+}
@@ -0,0 +1,228 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"time"
+)
+
+// 번호		pvc		imported		importPod		runningContainer		log
+// 1		X
+// 2		O		no				X
+// 3		O		no				O				fetcher					progress=
+// 4		O		no				O				importer				progress=
+// 5		O		no				O				fetcher					(failed to read)
+// 6		O		yes				X
+// 7		O		no				O				fetcher(streaming)		progress=
+// 8		O		no				O				fetcher					progress=(unchanged)
+var _ = Describe("syncProgress", func() {
+	startedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+	newRunningImporterPod := func(fetcherRunning bool) *corev1.Pod {
+		running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: startedAt}}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      GetImporterJobNameFromVmiName(testVmiName) + "-abcde",
+				Namespace: testVmiNs,
+				Labels:    map[string]string{util.JobNameLabel: GetImporterJobNameFromVmiName(testVmiName)},
+			},
+		}
+		if fetcherRunning {
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: FetcherContainerName, State: running}}
+		} else {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "importer", State: running}}
+		}
+		return pod
+	}
+	getProgress := func(r *ReconcileVirtualMachineImage) *hc.VirtualMachineImageProgress {
+		vmi := &hc.VirtualMachineImage{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+		Expect(err).Should(BeNil())
+		return vmi.Status.Progress
+	}
+
+	Context("1. with no pvc", func() {
+		r := createFakeReconcileVmi()
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not update the progress", func() {
+			Expect(getProgress(r)).Should(BeNil())
+		})
+	})
+
+	Context("2. with pvc, imported=no, no importerPod", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not update the progress", func() {
+			Expect(getProgress(r)).Should(BeNil())
+		})
+	})
+
+	Context("3. with pvc, imported=no, importerPod with running fetcher", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newRunningImporterPod(true))
+		var readContainer string
+		r.readPodLog = func(namespace, name, container string, tailLines int64) (string, error) {
+			readContainer = container
+			return "progress=100/1000\nprogress=250/1000\n", nil
+		}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should read the log of the fetcher", func() {
+			Expect(readContainer).Should(Equal(FetcherContainerName))
+		})
+		It("Should update the progress with the last reported bytes", func() {
+			progress := getProgress(r)
+			Expect(progress.Phase).Should(Equal(hc.VirtualMachineImageProgressPhaseFetching))
+			Expect(progress.TransferredBytes).Should(Equal(int64(250)))
+			Expect(progress.TotalBytes).Should(Equal(int64(1000)))
+			Expect(progress.Percentage).Should(Equal("25.00%"))
+			Expect(progress.EstimatedCompletionTime).ShouldNot(BeNil())
+		})
+		It("Should read the progress again", func() {
+			r.vmi.Status.State = hc.VirtualMachineImageStateCreating
+			Expect(r.isImporting()).Should(BeTrue())
+		})
+	})
+
+	Context("4. with pvc, imported=no, importerPod with running importer", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newRunningImporterPod(false))
+		var readContainer string
+		r.readPodLog = func(namespace, name, container string, tailLines int64) (string, error) {
+			readContainer = container
+			return "progress=536870912/1073741824\n", nil
+		}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should read the log of the importer", func() {
+			Expect(readContainer).Should(Equal("importer"))
+		})
+		It("Should update the progress of converting", func() {
+			progress := getProgress(r)
+			Expect(progress.Phase).Should(Equal(hc.VirtualMachineImageProgressPhaseConverting))
+			Expect(progress.Percentage).Should(Equal("50.00%"))
+		})
+	})
+
+	Context("5. with pvc, imported=no, importerPod with running fetcher, failed to read log", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newRunningImporterPod(true))
+		r.readPodLog = func(namespace, name, container string, tailLines int64) (string, error) {
+			return "", goerrors.New("container is not ready")
+		}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update only the phase", func() {
+			progress := getProgress(r)
+			Expect(progress.Phase).Should(Equal(hc.VirtualMachineImageProgressPhaseFetching))
+			Expect(progress.Percentage).Should(BeEmpty())
+		})
+	})
+
+	Context("6. with pvc, imported=yes", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("yes"))
+		virtualSize := resource.MustParse("1Gi")
+		r.vmi.Status.VirtualSize = &virtualSize
+		r.vmi.Status.Progress = &hc.VirtualMachineImageProgress{Phase: hc.VirtualMachineImageProgressPhaseConverting}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should complete the progress", func() {
+			progress := getProgress(r)
+			Expect(progress.Phase).Should(Equal(hc.VirtualMachineImageProgressPhaseCompleted))
+			Expect(progress.TransferredBytes).Should(Equal(int64(1073741824)))
+			Expect(progress.Percentage).Should(Equal("100.00%"))
+		})
+		It("Should not read the progress again", func() {
+			Expect(r.isImporting()).Should(BeFalse())
+		})
+	})
+
+	Context("7. with pvc, imported=no, importerPod with running fetcher which streams the source image", func() {
+		streamingPod := newRunningImporterPod(false)
+		streamingPod.Status.ContainerStatuses[0].Name = FetcherContainerName
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), streamingPod)
+		var readContainer string
+		r.readPodLog = func(namespace, name, container string, tailLines int64) (string, error) {
+			readContainer = container
+			return "progress=250/1000\n", nil
+		}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update the progress of fetching from the log of the fetcher", func() {
+			Expect(readContainer).Should(Equal(FetcherContainerName))
+			progress := getProgress(r)
+			Expect(progress.Phase).Should(Equal(hc.VirtualMachineImageProgressPhaseFetching))
+			Expect(progress.Percentage).Should(Equal("25.00%"))
+		})
+	})
+
+	Context("8. with pvc, imported=no, importerPod with running fetcher, unchanged progress", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newRunningImporterPod(true))
+		eta := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+		r.vmi.Status.Progress = &hc.VirtualMachineImageProgress{Phase: hc.VirtualMachineImageProgressPhaseFetching, TransferredBytes: 250,
+			TotalBytes: 1000, Percentage: "25.00%", EstimatedCompletionTime: &eta}
+		r.readPodLog = func(namespace, name, container string, tailLines int64) (string, error) {
+			return "progress=250/1000\n", nil
+		}
+		err := r.syncProgress()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not estimate the completion time again", func() {
+			Expect(r.vmi.Status.Progress.EstimatedCompletionTime).Should(Equal(&eta))
+		})
+		It("Should not update the status", func() {
+			Expect(getProgress(r)).Should(BeNil())
+		})
+	})
+})
+
+var _ = Describe("newProgress", func() {
+	now := time.Now()
+
+	Context("1. with the total", func() {
+		progress := newProgress(hc.VirtualMachineImageProgressPhaseFetching, 250, 1000, metav1.NewTime(now.Add(-time.Minute)), now)
+
+		It("Should estimate the completion time by the rate", func() {
+			Expect(progress.EstimatedCompletionTime.Time).Should(BeTemporally("~", now.Add(3*time.Minute), time.Second))
+		})
+	})
+
+	Context("2. without the total", func() {
+		progress := newProgress(hc.VirtualMachineImageProgressPhaseFetching, 250, 0, metav1.NewTime(now.Add(-time.Minute)), now)
+
+		It("Should not have the percentage and the estimated completion time", func() {
+			Expect(progress.TransferredBytes).Should(Equal(int64(250)))
+			Expect(progress.Percentage).Should(BeEmpty())
+			Expect(progress.EstimatedCompletionTime).Should(BeNil())
+		})
+	})
+})
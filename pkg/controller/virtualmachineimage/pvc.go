@@ -3,6 +3,7 @@ package virtualmachineimage
 import (
 	"context"
 	goerrors "errors"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -10,18 +11,54 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
 )
 
 func (r *ReconcileVirtualMachineImage) syncPvc() error {
-	if _, err := r.getPvc(r.vmi); err == nil {
+	if pvc, err := r.getPvc(r.vmi); err == nil {
+		if isDataSourcePvc(pvc) && pvc.Annotations["imported"] != "yes" && pvc.Status.Phase == corev1.ClaimBound {
+			// dataSource로 프로비저닝된 pvc는 바운드되면 임포팅이 완료된 것이므로 애노테이션을 업데이트한다
+			klog.Infof("Pvc provisioned from dataSource is bound for vmi %s", r.vmi.Name)
+			capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+			if err := r.updateImageInfo(hc.VirtualMachineImageFormatRaw, &capacity); err != nil {
+				return err
+			}
+			if err := r.updatePvcImported(true); err != nil {
+				return err
+			}
+			r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, hc.ReasonImported, "Image pvc %s provisioned from dataSource is bound", pvc.Name)
+			return nil
+		}
 		return nil
 	} else if !errors.IsNotFound(err) {
 		return err
 	}
 
+	if !r.hasStorageRequest() {
+		// 소스로부터 크기를 구한 뒤에 pvc를 만든다
+		return nil
+	}
+	src, err := r.getSource()
+	if err != nil {
+		return err
+	}
+	var captureSnapshot *snapshotv1beta1.VolumeSnapshot
+	if src == SourceVolume {
+		// 볼륨의 스냅샷이 준비된 뒤에 pvc를 만든다
+		if captureSnapshot, err = r.getCaptureSnapshot(); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		} else if !isSnapshotReadyToUse(captureSnapshot) {
+			return nil
+		}
+	}
+
 	klog.Infof("Create a new pvc for vmi %s", r.vmi.Name)
-	if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateCreating, corev1.ConditionFalse, "VmiIsCreating", "VMI is in creating"); err != nil {
+	if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateCreating, corev1.ConditionFalse, hc.ReasonCreating, "VMI is in creating"); err != nil {
 		return err
 	}
 
@@ -29,9 +66,17 @@ func (r *ReconcileVirtualMachineImage) syncPvc() error {
 	if err != nil {
 		return err
 	}
+	if src == SourcePVC {
+		if err := r.setCloneDataSource(newPvc); err != nil {
+			return err
+		}
+	} else if src == SourceVolume {
+		setSnapshotDataSource(newPvc, captureSnapshot.Name)
+	}
 	if err := r.client.Create(context.TODO(), newPvc); err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
+	r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventPvcCreated, "Created image pvc %s", newPvc.Name)
 	return nil
 }
 
@@ -54,7 +99,7 @@ func newPvc(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1.Persis
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        GetPvcNameFromVmiName(vmi.Name),
 			Namespace:   vmi.Namespace,
-			Annotations: map[string]string{"imported": "no"},
+			Annotations: map[string]string{"imported": "no", RevisionAnnotation: strconv.Itoa(int(GetRevision(vmi))), SourceHashAnnotation: getSourceHash(vmi)},
 		},
 		Spec: vmi.Spec.PVC,
 	}
@@ -64,6 +109,11 @@ func newPvc(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1.Persis
 	return pvc, nil
 }
 
+// isDataSourcePvc returns true if the pvc is populated by the CSI driver from its dataSource instead of the importer pod
+func isDataSourcePvc(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Spec.DataSource != nil
+}
+
 func (r *ReconcileVirtualMachineImage) isPvcImported() (imported, found bool, err error) {
 	pvc, err := r.getPvc(r.vmi)
 	if err != nil {
@@ -15,6 +15,9 @@ import (
 // 번호		pvc
 // 1		X
 // 2		O
+// 3		O(dataSource, bound)
+// 4		O(dataSource, pending)
+// 5		X				(no storage request)
 var _ = Describe("syncPvc", func() {
 	Context("1. with no pvc", func() {
 		r := createFakeReconcileVmi()
@@ -57,6 +60,54 @@ var _ = Describe("syncPvc", func() {
 			Expect(err).Should(BeNil())
 		})
 	})
+	Context("3. with bound pvc which is provisioned from dataSource", func() {
+		pvc := newTestImporterPvc("no")
+		setSnapshotDataSource(pvc, GetCaptureSnapshotNameFromVmiName(testVmiName))
+		pvc.Status.Phase = corev1.ClaimBound
+		r := createFakeReconcileVmi(pvc)
+		err := r.syncPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update imported to yes", func() {
+			imported, _, err := r.isPvcImported()
+			Expect(err).Should(BeNil())
+			Expect(imported).Should(BeTrue())
+		})
+	})
+
+	Context("4. with pending pvc which is provisioned from dataSource", func() {
+		pvc := newTestImporterPvc("no")
+		setSnapshotDataSource(pvc, GetCaptureSnapshotNameFromVmiName(testVmiName))
+		pvc.Status.Phase = corev1.ClaimPending
+		r := createFakeReconcileVmi(pvc)
+		err := r.syncPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not update imported", func() {
+			imported, _, err := r.isPvcImported()
+			Expect(err).Should(BeNil())
+			Expect(imported).Should(BeFalse())
+		})
+	})
+
+	Context("5. with no pvc and no storage request", func() {
+		r := createFakeReconcileVmi()
+		delete(r.vmi.Spec.PVC.Resources.Requests, corev1.ResourceStorage)
+		err := r.syncPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create a pvc until the storage request is sized", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetPvcNameFromVmiName(r.vmi.Name)}, pvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
 })
 
 // pvc가 없는 경우, pvc가 있는데 애노테이션이 없는 경우, pvc가 있고 애노테이션이 no인 경우, pvc가 있고 애노테이션이 yes인 경우
@@ -0,0 +1,261 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"github.com/robfig/cron"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// MinRefreshInterval is the minimum interval of the refresh policy
+	MinRefreshInterval = time.Minute
+	// ReasonRefreshing is the reason of ReadyToUse condition when the changed source image is imported into a new revision
+	ReasonRefreshing = "Refreshing"
+	// ReasonSourceChanged is the reason of Refreshed condition when the source image is changed
+	ReasonSourceChanged = "SourceChanged"
+	// ReasonSourceUnchanged is the reason of Refreshed condition when the source image is not changed
+	ReasonSourceUnchanged = "SourceUnchanged"
+	// ReasonRefreshCheckFailed is the reason of Refreshed condition when the refresh job failed
+	ReasonRefreshCheckFailed = "RefreshCheckFailed"
+	refreshETagKey           = "etag"
+	refreshLastModifiedKey   = "lastModified"
+	refreshDigestKey         = "digest"
+)
+
+// RefreshHeaderScript defines header shell function which prints the last value of the response header from CurlHeadersFile.
+// The headers of the last response are used if the source image is redirected
+const RefreshHeaderScript = `header() {
+  tr -d '\r' < ` + CurlHeadersFile + ` | awk -v h="$1" 'tolower($1) == h ":" { sub(/^[^:]*:[ \t]*/, ""); v = $0 } END { print v }'
+}
+`
+
+// syncRefresh checks the source image of the available vmi by the refresh job when the refresh policy is due,
+// and starts to import it into a new revision if it is changed. The snapshots of the old revisions are kept for the volumes created from them
+func (r *ReconcileVirtualMachineImage) syncRefresh() error {
+	if r.vmi.Spec.RefreshPolicy == nil || r.vmi.Status.State != hc.VirtualMachineImageStateAvailable {
+		return nil
+	}
+	refreshJob := &batchv1.Job{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetRefreshJobNameFromVmiName(r.vmi.Name)}, refreshJob); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if next, err := r.getNextRefreshTime(); err != nil || time.Now().Before(next) {
+			return err
+		}
+		// 소스 이미지를 확인할 때가 됐으니 리프레시잡을 만든다
+		klog.Infof("Create refresh job for vmi %s", r.vmi.Name)
+		newJob, err := r.newRefreshJob()
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newJob); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobStarted, "Started refresh job %s", newJob.Name)
+		return nil
+	}
+
+	now := metav1.Now()
+	if failed := util.GetJobCondition(refreshJob, batchv1.JobFailed); failed != nil {
+		// 확인에 실패해도 현재 리비전은 그대로 쓸 수 있으니 기록만 하고 다음 확인 때 다시 시도한다
+		message := failed.Message
+		if pods, err := util.ListJobPods(r.client, refreshJob.Namespace, refreshJob.Name); err != nil {
+			return err
+		} else if len(pods) != 0 {
+			if failure := getPodFailure(&pods[len(pods)-1]); failure != nil && failure.Message != "" {
+				message = failure.Message
+			}
+		}
+		klog.Warningf("Refresh job of vmi %s failed: %s", r.vmi.Name, message)
+		r.recorder.Eventf(r.vmi, corev1.EventTypeWarning, ReasonRefreshCheckFailed, "Refresh job %s failed: %s", refreshJob.Name, message)
+		r.vmi.Status.LastRefreshTime = &now
+		r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionRefreshed, corev1.ConditionFalse, ReasonRefreshCheckFailed, message,
+			r.vmi.Generation)
+		if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+			return err
+		}
+		return util.DeleteJob(r.client, refreshJob)
+	} else if !util.IsJobCompleted(refreshJob) {
+		return nil
+	}
+
+	result, err := getJobResult(r.client, refreshJob)
+	if err != nil {
+		return err
+	}
+	version := parseRefreshResult(result)
+	r.vmi.Status.LastRefreshTime = &now
+	if r.vmi.Status.SourceVersion != nil && isSourceChanged(r.vmi.Status.SourceVersion, version) {
+		// 소스 이미지가 바뀌었으니 새 리비전으로 다시 임포트한다
+		revision := GetRevision(r.vmi) + 1
+		klog.Infof("Source image of vmi %s is changed, import revision %d", r.vmi.Name, revision)
+		r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionRefreshed, corev1.ConditionTrue, ReasonSourceChanged,
+			fmt.Sprintf("Source image is changed, revision %d is imported", revision), r.vmi.Generation)
+		if err := r.startRevision(version, ReasonRefreshing, fmt.Sprintf("Source image is changed, importing revision %d", revision)); err != nil {
+			return err
+		}
+	} else {
+		// 처음 확인했다면 현재 리비전의 소스 버전으로 기록한다
+		if r.vmi.Status.SourceVersion == nil {
+			r.vmi.Status.SourceVersion = version
+		}
+		r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionRefreshed, corev1.ConditionTrue, ReasonSourceUnchanged, "Source image is not changed",
+			r.vmi.Generation)
+		if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventJobCompleted, "Refresh job %s completed, source image is not changed", refreshJob.Name)
+	}
+	return util.DeleteJob(r.client, refreshJob)
+}
+
+// getNextRefreshTime returns the time when the source image should be checked. The source image of the revision which has no version
+// is checked at once to record its version
+func (r *ReconcileVirtualMachineImage) getNextRefreshTime() (time.Time, error) {
+	if r.vmi.Status.SourceVersion == nil || r.vmi.Status.LastRefreshTime == nil {
+		return time.Time{}, nil
+	}
+	policy := r.vmi.Spec.RefreshPolicy
+	last := r.vmi.Status.LastRefreshTime.Time
+	if policy.Interval != nil {
+		return last.Add(policy.Interval.Duration), nil
+	}
+	schedule, err := cron.ParseStandard(policy.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(last.UTC()), nil
+}
+
+// getRefreshRequeueAfter returns the duration until the next refresh check of the available vmi, or zero if it is not needed
+func (r *ReconcileVirtualMachineImage) getRefreshRequeueAfter() time.Duration {
+	if r.vmi.Spec.RefreshPolicy == nil || r.vmi.Status.State != hc.VirtualMachineImageStateAvailable {
+		return 0
+	}
+	next, err := r.getNextRefreshTime()
+	if err != nil {
+		return 0
+	}
+	return time.Until(next)
+}
+
+func (r *ReconcileVirtualMachineImage) validateRefreshPolicy() error {
+	policy := r.vmi.Spec.RefreshPolicy
+	if policy == nil {
+		return nil
+	}
+	if src, err := r.getSource(); err != nil {
+		return err
+	} else if src != SourceHTTP && src != SourceS3 {
+		return goerrors.New("refreshPolicy is only supported for http and s3 sources")
+	}
+	if (policy.Interval == nil) == (policy.Schedule == "") {
+		return goerrors.New("only one of interval and schedule must be set in refreshPolicy")
+	}
+	if policy.Interval != nil && policy.Interval.Duration < MinRefreshInterval {
+		return goerrors.New("interval of refreshPolicy must be at least " + MinRefreshInterval.String())
+	}
+	if policy.Schedule != "" {
+		if _, err := cron.ParseStandard(policy.Schedule); err != nil {
+			return goerrors.New("schedule of refreshPolicy is invalid: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// isSourceChanged compares the versions of the source image by the digest, the ETag or the Last-Modified, which is found in both
+func isSourceChanged(old, new *hc.VirtualMachineImageSourceVersion) bool {
+	if old.Digest != "" && new.Digest != "" {
+		return old.Digest != new.Digest
+	} else if old.ETag != "" && new.ETag != "" {
+		return old.ETag != new.ETag
+	} else if old.LastModified != "" && new.LastModified != "" {
+		return old.LastModified != new.LastModified
+	}
+	return false
+}
+
+// parseRefreshResult returns the version of the source image from the termination message of the refresh job
+func parseRefreshResult(result string) *hc.VirtualMachineImageSourceVersion {
+	values := parseTerminationMessage(result)
+	return &hc.VirtualMachineImageSourceVersion{
+		ETag:         values[refreshETagKey],
+		LastModified: values[refreshLastModifiedKey],
+		Digest:       values[refreshDigestKey],
+	}
+}
+
+// GetRefreshJobNameFromVmiName returns the name of the refresh job from vmiName
+func GetRefreshJobNameFromVmiName(vmiName string) string {
+	return vmiName + "-image-refresh"
+}
+
+// newRefreshJob returns the job which runs the refresh pod. The failed pod is retried up to the max retries
+func (r *ReconcileVirtualMachineImage) newRefreshJob() (*batchv1.Job, error) {
+	pod, err := r.newRefreshPod()
+	if err != nil {
+		return nil, err
+	}
+	job := util.NewJob(pod, r.getMaxRetries(), r.config)
+	if err := controllerutil.SetControllerReference(r.vmi, job, r.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newRefreshPod returns the template of the refresh job which writes the version of the http or s3 source image to the termination message
+func (r *ReconcileVirtualMachineImage) newRefreshPod() (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetRefreshJobNameFromVmiName(r.vmi.Name),
+			Namespace: r.vmi.Namespace,
+			Labels:    map[string]string{VmiNameLabel: r.vmi.Name},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:                     "refresh",
+					Image:                    util.GetImageOrDefault(r.config.Images.Fetcher, FetcherImage),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+		},
+	}
+
+	src, err := r.getSource()
+	if err != nil {
+		return nil, err
+	} else if src != SourceHTTP && src != SourceS3 {
+		return nil, goerrors.New("refreshPolicy is only supported for http and s3 sources")
+	}
+	// The source image is requested by HEAD with the same TLS options and credentials as the import, and only the headers are read
+	readSource := r.setCurlReadSource(pod, &pod.Spec.Containers[0], src)
+	script := "set -eo pipefail\n" + CurlScript + RefreshHeaderScript +
+		readSource + " -I > /dev/null\n" +
+		"echo " + refreshETagKey + "=$(header etag) > /dev/termination-log\n" +
+		"echo " + refreshLastModifiedKey + "=$(header last-modified) >> /dev/termination-log\n"
+	if checksum := r.vmi.Spec.Source.Checksum; checksum != nil && checksum.SHA256SumsURL != "" {
+		// The digest in the SHA256SUMS file is compared first, because it is changed only when the content is changed
+		script += refreshDigestKey + `=$(` + r.getCurlSourceCommand(src, ChecksumSumsURL) + ` | awk -v f="$` + ChecksumFileName + `" '$2 == f || $2 == "*" f {print $1; exit}')` + "\n" +
+			"echo " + refreshDigestKey + "=$" + refreshDigestKey + " >> /dev/termination-log\n"
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env,
+			corev1.EnvVar{Name: ChecksumSumsURL, Value: checksum.SHA256SumsURL},
+			corev1.EnvVar{Name: ChecksumFileName, Value: getSourceFileName(r.getSourceURL(src))})
+	}
+	pod.Spec.Containers[0].Command = []string{"/bin/sh", "-c", script}
+	util.ApplyConfigToPod(pod, r.config)
+	return pod, nil
+}
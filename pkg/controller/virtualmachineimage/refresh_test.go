@@ -0,0 +1,221 @@
+package virtualmachineimage
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"time"
+)
+
+const (
+	testETag = `"5e8f-1a2b3c"`
+)
+
+// 번호		sourceVersion		refreshJob			refreshJobState		result
+// 1		X					X										create refresh job
+// 2		O(not due)			X										no refresh job
+// 3		X					O					Complete			record source version
+// 4		O					O					Complete			unchanged
+// 5		O					O					Complete			changed, import revision 2
+// 6		O					O					Failed				refresh check failed, still available
+var _ = Describe("syncRefresh", func() {
+	Context("1. with no source version", func() {
+		r := createFakeReconcileRefreshVmi(nil, nil)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create refresh job which reads the headers of the source", func() {
+			job, err := getTestJob(r, GetRefreshJobNameFromVmiName(r.vmi.Name))
+			Expect(err).Should(BeNil())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: SourceURLVar, Value: r.vmi.Spec.Source.HTTP}))
+			Expect(job.Spec.Template.Spec.Containers[0].Command[2]).Should(ContainSubstring("-I > /dev/null"))
+		})
+	})
+
+	Context("2. with source version checked recently", func() {
+		now := metav1.Now()
+		r := createFakeReconcileRefreshVmi(&hc.VirtualMachineImageSourceVersion{ETag: testETag}, &now)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create refresh job", func() {
+			_, err := getTestJob(r, GetRefreshJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should requeue at the next refresh time", func() {
+			Expect(r.getRefreshRequeueAfter()).Should(BeNumerically("~", time.Hour, time.Minute))
+		})
+	})
+
+	Context("3. with completed refresh job and no source version", func() {
+		job, pod := newTestCompletedJob(GetRefreshJobNameFromVmiName(testVmiName), "etag="+testETag+"\nlastModified=\n")
+		r := createFakeReconcileRefreshVmi(nil, nil, job, pod)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the source version of the current revision", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.SourceVersion).Should(Equal(&hc.VirtualMachineImageSourceVersion{ETag: testETag}))
+			Expect(vmi.Status.LastRefreshTime).ShouldNot(BeNil())
+			Expect(GetRevision(vmi)).Should(Equal(int32(1)))
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateAvailable))
+		})
+		It("Should delete refresh job", func() {
+			_, err := getTestJob(r, GetRefreshJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("4. with completed refresh job and unchanged source", func() {
+		job, pod := newTestCompletedJob(GetRefreshJobNameFromVmiName(testVmiName), "etag="+testETag+"\nlastModified=Mon, 01 Jun 2026 00:00:00 GMT\n")
+		r := createFakeReconcileRefreshVmi(&hc.VirtualMachineImageSourceVersion{ETag: testETag}, nil, job, pod)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should keep the revision", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(GetRevision(vmi)).Should(Equal(int32(1)))
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateAvailable))
+			found, cond := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionRefreshed)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Reason).Should(Equal(ReasonSourceUnchanged))
+		})
+	})
+
+	Context("5. with completed refresh job and changed source", func() {
+		job, pod := newTestCompletedJob(GetRefreshJobNameFromVmiName(testVmiName), "etag=\"changed\"\nlastModified=\n")
+		r := createFakeReconcileRefreshVmi(&hc.VirtualMachineImageSourceVersion{ETag: testETag}, nil, job, pod)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should start to import revision 2", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.Revision).Should(Equal(int32(2)))
+			Expect(vmi.Status.SourceVersion.ETag).Should(Equal(`"changed"`))
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateCreating))
+			found, cond := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionReadyToUse)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Reason).Should(Equal(ReasonRefreshing))
+		})
+		It("Should use the snapshot of revision 2", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(GetImageSnapshotName(vmi)).Should(Equal(GetSnapshotNameFromVmiName(testVmiName) + "-2"))
+		})
+	})
+
+	Context("6. with failed refresh job", func() {
+		job := newTestJob(GetRefreshJobNameFromVmiName(testVmiName), batchv1.JobFailed, 1)
+		r := createFakeReconcileRefreshVmi(&hc.VirtualMachineImageSourceVersion{ETag: testETag}, nil, job)
+		err := r.syncRefresh()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the failure in the refreshed condition", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			found, cond := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionRefreshed)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(ReasonRefreshCheckFailed))
+		})
+		It("Should keep the vmi available", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateAvailable))
+		})
+		It("Should delete refresh job", func() {
+			_, err := getTestJob(r, GetRefreshJobNameFromVmiName(r.vmi.Name))
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("getNextRefreshTime", func() {
+	last := metav1.NewTime(time.Date(2026, 6, 1, 10, 30, 0, 0, time.UTC))
+	r := createFakeReconcileRefreshVmi(&hc.VirtualMachineImageSourceVersion{ETag: testETag}, &last)
+
+	It("Should add the interval to the last refresh time", func() {
+		next, err := r.getNextRefreshTime()
+		Expect(err).Should(BeNil())
+		Expect(next).Should(Equal(last.Add(time.Hour)))
+	})
+	It("Should return the next time of the schedule", func() {
+		r.vmi.Spec.RefreshPolicy = &hc.VirtualMachineImageRefreshPolicy{Schedule: "0 3 * * *"}
+		next, err := r.getNextRefreshTime()
+		Expect(err).Should(BeNil())
+		Expect(next.Equal(time.Date(2026, 6, 2, 3, 0, 0, 0, time.UTC))).Should(BeTrue())
+	})
+})
+
+var _ = Describe("validateRefreshPolicy", func() {
+	It("Should not return error with interval", func() {
+		r := createFakeReconcileRefreshVmi(nil, nil)
+		Expect(r.validateRefreshPolicy()).Should(BeNil())
+	})
+	It("Should return error with both interval and schedule", func() {
+		r := createFakeReconcileRefreshVmi(nil, nil)
+		r.vmi.Spec.RefreshPolicy.Schedule = "0 3 * * *"
+		Expect(r.validateRefreshPolicy()).ShouldNot(BeNil())
+	})
+	It("Should return error with invalid schedule", func() {
+		r := createFakeReconcileRefreshVmi(nil, nil)
+		r.vmi.Spec.RefreshPolicy = &hc.VirtualMachineImageRefreshPolicy{Schedule: "every day"}
+		Expect(r.validateRefreshPolicy()).ShouldNot(BeNil())
+	})
+	It("Should return error with upload source", func() {
+		r := createFakeReconcileRefreshVmi(nil, nil)
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{Upload: &hc.VirtualMachineImageSourceUpload{}}
+		Expect(r.validateRefreshPolicy()).ShouldNot(BeNil())
+	})
+})
+
+var _ = Describe("isSourceChanged", func() {
+	It("Should compare the digest first", func() {
+		Expect(isSourceChanged(&hc.VirtualMachineImageSourceVersion{ETag: "a", Digest: "d"},
+			&hc.VirtualMachineImageSourceVersion{ETag: "b", Digest: "d"})).Should(BeFalse())
+	})
+	It("Should compare the last modified without etag", func() {
+		Expect(isSourceChanged(&hc.VirtualMachineImageSourceVersion{LastModified: "a"},
+			&hc.VirtualMachineImageSourceVersion{LastModified: "b"})).Should(BeTrue())
+	})
+	It("Should not be changed without any version", func() {
+		Expect(isSourceChanged(&hc.VirtualMachineImageSourceVersion{}, &hc.VirtualMachineImageSourceVersion{})).Should(BeFalse())
+	})
+})
+
+// createFakeReconcileRefreshVmi returns the reconciler of the available vmi with the refresh policy of 1h interval
+func createFakeReconcileRefreshVmi(version *hc.VirtualMachineImageSourceVersion, lastRefreshTime *metav1.Time,
+	objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	vmi := newTestVmi()
+	vmi.Spec.RefreshPolicy = &hc.VirtualMachineImageRefreshPolicy{Interval: &metav1.Duration{Duration: time.Hour}}
+	vmi.Status.State = hc.VirtualMachineImageStateAvailable
+	vmi.Status.SourceVersion = version
+	vmi.Status.LastRefreshTime = lastRefreshTime
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, vmi)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileVirtualMachineImage{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), vmi: vmi}
+}
@@ -0,0 +1,191 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	// RetryAnnotation is the annotation of vmi which requests to retry the import. The failures are cleared and the annotation is removed
+	RetryAnnotation = "hypercloud.tmaxanc.com/retry"
+	// DefaultMaxRetries is the backoff limit of the worker jobs if maxRetries is not set
+	DefaultMaxRetries = 3
+	// ReasonImportFailed is the reason of ReadyToUse condition when the importer, checksum or probe job failed
+	ReasonImportFailed = "ImportFailed"
+	// ReasonRetrying is the reason of ReadyToUse condition when the retry is requested by RetryAnnotation
+	ReasonRetrying = "Retrying"
+)
+
+// syncRetry clears the failures of the import and deletes the failed jobs if RetryAnnotation is set, so the jobs are created again.
+// The annotation is removed after the failed jobs are deleted, so the failed jobs in the cache are not recorded again
+func (r *ReconcileVirtualMachineImage) syncRetry() error {
+	if _, found := r.vmi.Annotations[RetryAnnotation]; !found {
+		return nil
+	}
+
+	// 재시도가 요청됐으니 실패 기록을 지우고 실패한 잡을 삭제한다
+	if r.vmi.Status.LastFailure != nil || r.vmi.Status.State == hc.VirtualMachineImageStateError {
+		klog.Infof("Retry is requested for vmi %s", r.vmi.Name)
+		r.vmi.Status.FailedCount = 0
+		r.vmi.Status.LastFailure = nil
+		if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateCreating, corev1.ConditionFalse, ReasonRetrying, "Retry is requested"); err != nil {
+			return err
+		}
+	}
+	jobs := []types.NamespacedName{
+		{Namespace: r.vmi.Namespace, Name: GetProbeJobNameFromVmiName(r.vmi.Name)},
+		{Namespace: r.vmi.Namespace, Name: GetChecksumJobNameFromVmiName(r.vmi.Name)},
+		{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)},
+	}
+	if r.vmi.Spec.Source.PVC != nil {
+		// The clone source job is in the namespace of the source pvc
+		namespace, _ := getSourcePvcNamespacedName(r.vmi)
+		jobs = append(jobs, types.NamespacedName{Namespace: namespace, Name: GetCloneSourceJobNameFromVmi(r.vmi)})
+	}
+	deleting := false
+	for _, name := range jobs {
+		job := &batchv1.Job{}
+		if err := r.client.Get(context.TODO(), name, job); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if util.GetJobCondition(job, batchv1.JobFailed) != nil {
+			deleting = true
+			if err := util.DeleteJob(r.client, job); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	if deleting {
+		return nil
+	}
+
+	// 실패한 잡이 모두 삭제됐으니 애노테이션을 삭제한다. Only the annotation is patched, because the spec of r.vmi has the defaults of the config
+	vmi := r.vmi.DeepCopy()
+	patch := []byte(`{"metadata":{"annotations":{"` + RetryAnnotation + `":null}}}`)
+	if err := r.client.Patch(context.TODO(), vmi, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return err
+	}
+	r.vmi.Annotations = vmi.Annotations
+	r.vmi.ResourceVersion = vmi.ResourceVersion
+	return nil
+}
+
+// syncJobFailures records the failures of the pods of the running or failed worker job.
+// It returns the error if the job failed after the pod failed more than the max retries or the job exceeded the deadline
+func (r *ReconcileVirtualMachineImage) syncJobFailures(job *batchv1.Job) error {
+	if _, found := r.vmi.Annotations[RetryAnnotation]; found {
+		// The failed job is being deleted by the retry request
+		return nil
+	}
+	failed := util.GetJobCondition(job, batchv1.JobFailed)
+	if failed == nil && (job.Status.Failed == 0 || job.Status.Failed == r.vmi.Status.FailedCount) {
+		return nil
+	}
+	if failed != nil && r.vmi.Status.LastFailure != nil && r.vmi.Status.LastFailure.JobFailedReason != "" {
+		return r.getJobFailedError()
+	}
+
+	// 실패한 파드가 늘었거나 잡이 실패했으니 마지막 실패를 기록한다
+	pods, err := util.ListJobPods(r.client, job.Namespace, job.Name)
+	if err != nil {
+		return err
+	}
+	failure := &hc.VirtualMachineImageFailure{Time: metav1.Now()}
+	for i := len(pods) - 1; i >= 0; i-- {
+		if podFailure := getPodFailure(&pods[i]); podFailure != nil {
+			failure = podFailure
+			break
+		}
+	}
+	if failed != nil {
+		klog.Warningf("Job %s of vmi %s failed: %s", job.Name, r.vmi.Name, failed.Message)
+		failure.JobFailedReason = failed.Reason
+		if failure.Message == "" {
+			failure.Message = failed.Message
+		}
+		r.vmi.Status.Progress = nil
+	}
+	r.vmi.Status.FailedCount = job.Status.Failed
+	r.vmi.Status.LastFailure = failure
+	if err := r.client.Status().Update(context.TODO(), r.vmi); err != nil {
+		return err
+	}
+	if failure.Container != "" {
+		r.recorder.Eventf(r.vmi, corev1.EventTypeWarning, util.EventPodFailed, "Container %s of job %s exited with code %d: %s",
+			failure.Container, job.Name, failure.ExitCode, failure.Message)
+	}
+	return r.getJobFailedError()
+}
+
+// getJobFailedError returns the error if the worker job failed, so the job is not created again until the retry is requested
+func (r *ReconcileVirtualMachineImage) getJobFailedError() error {
+	failure := r.vmi.Status.LastFailure
+	if failure == nil || failure.JobFailedReason == "" {
+		return nil
+	}
+	message := fmt.Sprintf("job failed(%s) after %d pod failures", failure.JobFailedReason, r.vmi.Status.FailedCount)
+	if failure.Container != "" {
+		message += fmt.Sprintf(", container %s exited with code %d", failure.Container, failure.ExitCode)
+	}
+	if failure.Message != "" {
+		message += ": " + failure.Message
+	}
+	return &vmiError{reason: ReasonImportFailed, message: message + ". Set " + RetryAnnotation + " annotation to retry"}
+}
+
+// getMaxRetries returns the backoff limit of the worker jobs
+func (r *ReconcileVirtualMachineImage) getMaxRetries() int32 {
+	if r.vmi.Spec.MaxRetries != nil {
+		return *r.vmi.Spec.MaxRetries
+	}
+	return DefaultMaxRetries
+}
+
+// getPodFailure returns the failure of the container which exited with non-zero code, or nil if the pod didn't fail.
+// The pods of the worker jobs don't restart the containers, so the failed container fails the pod
+func getPodFailure(pod *corev1.Pod) *hc.VirtualMachineImageFailure {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+				return &hc.VirtualMachineImageFailure{
+					Container: status.Name,
+					ExitCode:  terminated.ExitCode,
+					Message:   getFailureMessage(terminated),
+					Time:      metav1.Now(),
+				}
+			}
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return &hc.VirtualMachineImageFailure{Message: strings.TrimSpace(pod.Status.Reason + " " + pod.Status.Message), Time: metav1.Now()}
+	}
+	return nil
+}
+
+// getFailureMessage returns the termination message without the progress, because the log is the message of the container
+// which exited without writing the termination message
+func getFailureMessage(terminated *corev1.ContainerStateTerminated) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(terminated.Message), "\n") {
+		if !strings.HasPrefix(line, progressKey+"=") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 || lines[0] == "" {
+		return terminated.Reason
+	}
+	return strings.Join(lines, "\n")
+}
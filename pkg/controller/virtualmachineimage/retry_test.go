@@ -0,0 +1,192 @@
+package virtualmachineimage
+
+import (
+	"context"
+	goerrors "errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+func newTestFailedImporterJobPod() *corev1.Pod {
+	jobName := GetImporterJobNameFromVmiName(testVmiName)
+	pod := newTestJobPod(jobName, jobName+"-abcde", corev1.PodFailed)
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: FetcherContainerName,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 22,
+					Reason:   "Error",
+					Message:  "progress=0/0\ncurl: (22) The requested URL returned error: 404 Not Found\n",
+				},
+			},
+		},
+	}
+	return pod
+}
+
+// 번호		pvc		imported		importJob		jobFailed		failedCount		lastFailure
+// 1		O		no				Running			1				0				X
+// 2		O		no				Running			1				1				X
+// 3		O		no				Failed			4				0				X
+// 4		O		no				X								4				O(job failed)
+var _ = Describe("syncImporterJob with failure", func() {
+	jobName := GetImporterJobNameFromVmiName(testVmiName)
+	getImporterJob := func(r *ReconcileVirtualMachineImage) error {
+		return r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)}, &batchv1.Job{})
+	}
+
+	Context("1. with running importerJob, a pod failed", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newTestJob(jobName, "", 1), newTestFailedImporterJobPod())
+		err := r.syncImporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should record the failure with the exit code and the termination message", func() {
+			vmi := &hc.VirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)).Should(Succeed())
+			Expect(vmi.Status.FailedCount).Should(Equal(int32(1)))
+			Expect(vmi.Status.LastFailure.Container).Should(Equal(FetcherContainerName))
+			Expect(vmi.Status.LastFailure.ExitCode).Should(Equal(int32(22)))
+			Expect(vmi.Status.LastFailure.Message).Should(Equal("curl: (22) The requested URL returned error: 404 Not Found"))
+			Expect(vmi.Status.LastFailure.JobFailedReason).Should(BeEmpty())
+		})
+		It("Should not delete importerJob", func() {
+			Expect(getImporterJob(r)).Should(Succeed())
+		})
+	})
+
+	Context("2. with running importerJob, the failed pod is already recorded", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newTestJob(jobName, "", 1), newTestFailedImporterJobPod())
+		r.vmi.Status.FailedCount = 1
+		err := r.syncImporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not record the failure again", func() {
+			Expect(r.vmi.Status.LastFailure).Should(BeNil())
+		})
+	})
+
+	Context("3. with failed importerJob", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"), newTestJob(jobName, batchv1.JobFailed, 4), newTestFailedImporterJobPod())
+		err := r.syncImporterJob()
+
+		It("Should return ImportFailed error with the job failed reason, the exit code and the termination message", func() {
+			vmiErr := (*vmiError)(nil)
+			Expect(goerrors.As(err, &vmiErr)).Should(BeTrue())
+			Expect(vmiErr.reason).Should(Equal(ReasonImportFailed))
+			Expect(vmiErr.message).Should(ContainSubstring("job failed(BackoffLimitExceeded) after 4 pod failures, container fetcher exited with code 22: curl: (22)"))
+		})
+		It("Should record the job failed reason", func() {
+			vmi := &hc.VirtualMachineImage{}
+			Expect(r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)).Should(Succeed())
+			Expect(vmi.Status.FailedCount).Should(Equal(int32(4)))
+			Expect(vmi.Status.LastFailure.JobFailedReason).Should(Equal("BackoffLimitExceeded"))
+		})
+	})
+
+	Context("4. with no importerJob, the job failed", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		r.vmi.Status.FailedCount = 4
+		r.vmi.Status.LastFailure = &hc.VirtualMachineImageFailure{JobFailedReason: "DeadlineExceeded", Message: "Job was active longer than specified deadline", Time: metav1.Now()}
+		err := r.syncImporterJob()
+
+		It("Should return ImportFailed error", func() {
+			vmiErr := (*vmiError)(nil)
+			Expect(goerrors.As(err, &vmiErr)).Should(BeTrue())
+			Expect(vmiErr.reason).Should(Equal(ReasonImportFailed))
+			Expect(vmiErr.message).Should(ContainSubstring("job failed(DeadlineExceeded)"))
+		})
+		It("Should not create importerJob again", func() {
+			Expect(errors.IsNotFound(getImporterJob(r))).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("syncRetry", func() {
+	Context("1. with retry annotation", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Annotations = map[string]string{RetryAnnotation: "true"}
+		updateErr := r.client.Update(context.TODO(), r.vmi)
+		r.vmi.Status.State = hc.VirtualMachineImageStateError
+		r.vmi.Status.FailedCount = 4
+		r.vmi.Status.LastFailure = &hc.VirtualMachineImageFailure{Time: metav1.Now()}
+		err := r.syncRetry()
+		vmi := &hc.VirtualMachineImage{}
+		getErr := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+
+		It("Should return no error", func() {
+			Expect(updateErr).Should(BeNil())
+			Expect(err).Should(BeNil())
+			Expect(getErr).Should(BeNil())
+		})
+		It("Should clear the failures", func() {
+			Expect(vmi.Status.FailedCount).Should(BeZero())
+			Expect(vmi.Status.LastFailure).Should(BeNil())
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateCreating))
+		})
+		It("Should remove the retry annotation", func() {
+			Expect(vmi.Annotations).ShouldNot(HaveKey(RetryAnnotation))
+		})
+	})
+
+	Context("2. with retry annotation, failed importerJob", func() {
+		r := createFakeReconcileVmi(newTestJob(GetImporterJobNameFromVmiName(testVmiName), batchv1.JobFailed, 4))
+		r.vmi.Annotations = map[string]string{RetryAnnotation: "true"}
+		updateErr := r.client.Update(context.TODO(), r.vmi)
+		r.vmi.Status.State = hc.VirtualMachineImageStateError
+		r.vmi.Status.FailedCount = 4
+		r.vmi.Status.LastFailure = &hc.VirtualMachineImageFailure{JobFailedReason: "BackoffLimitExceeded", Time: metav1.Now()}
+		err := r.syncRetry()
+		vmi := &hc.VirtualMachineImage{}
+		getErr := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+		jobErr := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImporterJobNameFromVmiName(r.vmi.Name)}, &batchv1.Job{})
+
+		It("Should return no error", func() {
+			Expect(updateErr).Should(BeNil())
+			Expect(err).Should(BeNil())
+			Expect(getErr).Should(BeNil())
+		})
+		It("Should clear the failures", func() {
+			Expect(vmi.Status.FailedCount).Should(BeZero())
+			Expect(vmi.Status.LastFailure).Should(BeNil())
+		})
+		It("Should delete the failed importerJob", func() {
+			Expect(errors.IsNotFound(jobErr)).Should(BeTrue())
+		})
+		It("Should keep the retry annotation until the failed job is deleted", func() {
+			Expect(vmi.Annotations).Should(HaveKey(RetryAnnotation))
+		})
+	})
+})
+
+var _ = Describe("getPodFailure", func() {
+	Context("1. with the completed pod", func() {
+		pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}}},
+		}}}
+
+		It("Should return nil", func() {
+			Expect(getPodFailure(pod)).Should(BeNil())
+		})
+	})
+
+	Context("2. with the evicted pod", func() {
+		pod := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: ephemeral-storage."}}
+
+		It("Should return the failure with the reason of the pod", func() {
+			failure := getPodFailure(pod)
+			Expect(failure.Container).Should(BeEmpty())
+			Expect(failure.Message).Should(Equal("Evicted The node was low on resource: ephemeral-storage."))
+		})
+	})
+})
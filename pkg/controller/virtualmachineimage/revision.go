@@ -0,0 +1,196 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"strconv"
+)
+
+const (
+	// RevisionAnnotation is the annotation of the image pvc which contains the revision of the vmi imported into it
+	RevisionAnnotation = "hypercloud.tmaxanc.com/revision"
+	// SourceHashAnnotation is the annotation of the image pvc which contains the hash of the source imported into it
+	SourceHashAnnotation = "hypercloud.tmaxanc.com/source-hash"
+	// ReasonSourceUpdated is the reason of ReadyToUse condition when the updated source is imported into a new revision
+	ReasonSourceUpdated = "SourceUpdated"
+)
+
+// syncRevision starts to import the available vmi into a new revision if its source is updated.
+// It returns true while the image pvc of the old revision is being deleted, so the other resources are not synced with it
+func (r *ReconcileVirtualMachineImage) syncRevision() (bool, error) {
+	pvc, err := r.getPvc(r.vmi)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if r.vmi.Status.State == hc.VirtualMachineImageStateAvailable && isSourceUpdated(pvc, r.vmi) {
+		// 소스가 수정됐으니 새 리비전으로 다시 임포트한다
+		revision := GetRevision(r.vmi) + 1
+		klog.Infof("Source of vmi %s is updated, import revision %d", r.vmi.Name, revision)
+		if err := r.startRevision(nil, ReasonSourceUpdated, fmt.Sprintf("Source is updated, importing revision %d", revision)); err != nil {
+			return false, err
+		}
+	}
+	if getPvcRevision(pvc) == GetRevision(r.vmi) {
+		return false, nil
+	}
+
+	// 이전 리비전의 pvc는 스냅샷을 만들었으니 삭제하고, 새 리비전의 pvc는 삭제된 뒤에 만든다
+	if pvc.DeletionTimestamp == nil {
+		klog.Infof("Delete pvc of revision %d for vmi %s", getPvcRevision(pvc), r.vmi.Name)
+		if err := r.client.Delete(context.TODO(), pvc); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventPvcDeleted, "Deleted image pvc of revision %d", getPvcRevision(pvc))
+	}
+	return true, nil
+}
+
+// startRevision increases the revision of the vmi with the source version, and clears the status of the import of the old revision
+func (r *ReconcileVirtualMachineImage) startRevision(version *hc.VirtualMachineImageSourceVersion, reason, message string) error {
+	r.vmi.Status.Revision = GetRevision(r.vmi) + 1
+	r.vmi.Status.SourceVersion = version
+	r.vmi.Status.Digest = ""
+	r.vmi.Status.Format = ""
+	r.vmi.Status.VirtualSize = nil
+	r.vmi.Status.StorageRequest = nil
+	r.vmi.Status.Progress = nil
+	return r.updateStateWithReadyToUse(hc.VirtualMachineImageStateCreating, corev1.ConditionFalse, reason, message)
+}
+
+// addRevision records the current revision of the vmi with its ready snapshot in the status, if it is not recorded yet
+func (r *ReconcileVirtualMachineImage) addRevision(snapshot *snapshotv1beta1.VolumeSnapshot) {
+	revision := GetRevision(r.vmi)
+	for _, rev := range r.vmi.Status.Revisions {
+		if rev.Revision == revision {
+			return
+		}
+	}
+	newRevision := hc.VirtualMachineImageRevision{
+		Revision:     revision,
+		SnapshotName: snapshot.Name,
+		Digest:       r.vmi.Status.Digest,
+		CreationTime: metav1.Now(),
+	}
+	if storageRequest, found := GetStorageRequest(r.vmi); found {
+		newRevision.StorageRequest = &storageRequest
+	}
+	r.vmi.Status.Revisions = append(r.vmi.Status.Revisions, newRevision)
+}
+
+// syncRevisionHistory deletes the snapshots of the oldest revisions more than the revision history limit.
+// The current revision and the revisions which the volumes use or pin are kept
+func (r *ReconcileVirtualMachineImage) syncRevisionHistory() error {
+	limit := r.vmi.Spec.RevisionHistoryLimit
+	if limit == nil || len(r.vmi.Status.Revisions) <= int(*limit) {
+		return nil
+	}
+
+	volumes, err := r.getImageVolumes()
+	if err != nil {
+		return err
+	}
+	usedRevisions := map[int32]bool{}
+	for i := range volumes {
+		usedRevisions[getVolumeRevision(&volumes[i])] = true
+	}
+
+	var revisions []hc.VirtualMachineImageRevision
+	excess := len(r.vmi.Status.Revisions) - int(*limit)
+	for _, rev := range r.vmi.Status.Revisions {
+		if excess == 0 || rev.Revision == GetRevision(r.vmi) || usedRevisions[rev.Revision] {
+			revisions = append(revisions, rev)
+			continue
+		}
+		// 보관할 리비전 수를 넘었고 쓰는 볼륨도 없으니 스냅샷을 삭제한다
+		klog.Infof("Delete snapshot of revision %d for vmi %s", rev.Revision, r.vmi.Name)
+		snapshot := &snapshotv1beta1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: rev.SnapshotName, Namespace: r.vmi.Namespace}}
+		if err := r.client.Delete(context.TODO(), snapshot); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotDeleted, "Deleted snapshot %s of revision %d, which exceeds the revision history limit",
+			snapshot.Name, rev.Revision)
+		excess--
+	}
+	if len(revisions) == len(r.vmi.Status.Revisions) {
+		return nil
+	}
+	r.vmi.Status.Revisions = revisions
+	return r.client.Status().Update(context.TODO(), r.vmi)
+}
+
+// GetAvailableRevision returns the revision of the image, or its latest available revision if revision is nil.
+// The image available before the revisions are recorded has only its current revision
+func GetAvailableRevision(image *hc.VirtualMachineImage, revision *int32) (*hc.VirtualMachineImageRevision, bool) {
+	revisions := image.Status.Revisions
+	if found, cond := util.GetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse); len(revisions) == 0 && found && cond.Status == corev1.ConditionTrue {
+		revisions = []hc.VirtualMachineImageRevision{{Revision: GetRevision(image), SnapshotName: GetImageSnapshotName(image)}}
+	}
+	if len(revisions) == 0 {
+		return nil, false
+	}
+	if revision == nil {
+		return &revisions[len(revisions)-1], true
+	}
+	for i := range revisions {
+		if revisions[i].Revision == *revision {
+			return &revisions[i], true
+		}
+	}
+	return nil, false
+}
+
+// GetRevision returns the revision of the vmi. The vmi created before the revision is introduced is the first revision
+func GetRevision(vmi *hc.VirtualMachineImage) int32 {
+	if vmi.Status.Revision < 1 {
+		return 1
+	}
+	return vmi.Status.Revision
+}
+
+// getPvcRevision returns the revision of the vmi imported into the image pvc
+func getPvcRevision(pvc *corev1.PersistentVolumeClaim) int32 {
+	revision, err := strconv.Atoi(pvc.Annotations[RevisionAnnotation])
+	if err != nil || revision < 1 {
+		return 1
+	}
+	return int32(revision)
+}
+
+// getVolumeRevision returns the revision of the image which the volume uses or pins.
+// The volume created before the revision is introduced uses the first revision
+func getVolumeRevision(volume *hc.VirtualMachineVolume) int32 {
+	if volume.Status.ImageRevision > 0 {
+		return volume.Status.ImageRevision
+	} else if volume.Spec.VirtualMachineImage.Revision != nil {
+		return *volume.Spec.VirtualMachineImage.Revision
+	}
+	return 1
+}
+
+// isSourceUpdated returns true if the source of the vmi is different from the source imported into the image pvc.
+// The image pvc created before the source hash is introduced is not compared
+func isSourceUpdated(pvc *corev1.PersistentVolumeClaim, vmi *hc.VirtualMachineImage) bool {
+	sourceHash, found := pvc.Annotations[SourceHashAnnotation]
+	return found && sourceHash != getSourceHash(vmi)
+}
+
+// getSourceHash returns the hash of the source of the vmi
+func getSourceHash(vmi *hc.VirtualMachineImage) string {
+	source, _ := json.Marshal(vmi.Spec.Source)
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])[:16]
+}
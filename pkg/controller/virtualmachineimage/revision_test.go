@@ -0,0 +1,261 @@
+package virtualmachineimage
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"strconv"
+)
+
+// 번호		pvc revision		vmi revision		pvc source			result
+// 1		X					1										not deleting
+// 2		1					1					same				not deleting
+// 3		1					1					updated				import revision 2, delete pvc
+// 4		1					2					same				delete pvc
+var _ = Describe("syncRevision", func() {
+	Context("1. with no pvc", func() {
+		r := createFakeReconcileRevisionVmi()
+		deleting, err := r.syncRevision()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be deleting", func() {
+			Expect(deleting).Should(BeFalse())
+		})
+	})
+
+	Context("2. with pvc of the current revision and source", func() {
+		r := createFakeReconcileRevisionVmi(newTestRevisionPvc(1, getSourceHash(newTestVmi())))
+		deleting, err := r.syncRevision()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be deleting", func() {
+			Expect(deleting).Should(BeFalse())
+			Expect(GetRevision(r.vmi)).Should(Equal(int32(1)))
+		})
+	})
+
+	Context("3. with pvc of the updated source", func() {
+		r := createFakeReconcileRevisionVmi(newTestRevisionPvc(1, "0000000000000000"))
+		deleting, err := r.syncRevision()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should start to import revision 2", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			Expect(vmi.Status.Revision).Should(Equal(int32(2)))
+			Expect(vmi.Status.State).Should(Equal(hc.VirtualMachineImageStateCreating))
+			found, cond := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionReadyToUse)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Reason).Should(Equal(ReasonSourceUpdated))
+		})
+		It("Should delete the pvc of the old revision", func() {
+			Expect(deleting).Should(BeTrue())
+			Expect(errors.IsNotFound(getTestRevisionPvc(r))).Should(BeTrue())
+		})
+	})
+
+	Context("4. with pvc of the old revision", func() {
+		r := createFakeReconcileRevisionVmi(newTestRevisionPvc(1, getSourceHash(newTestVmi())))
+		r.vmi.Status.State = hc.VirtualMachineImageStateCreating
+		r.vmi.Status.Revision = 2
+		deleting, err := r.syncRevision()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete the pvc of the old revision", func() {
+			Expect(deleting).Should(BeTrue())
+			Expect(errors.IsNotFound(getTestRevisionPvc(r))).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("addRevision", func() {
+	r := createFakeReconcileRevisionVmi()
+	r.vmi.Status.Revision = 2
+	r.vmi.Status.Digest = "sha256:" + testSHA256
+	snapshot := &snapshotv1beta1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: GetImageSnapshotName(r.vmi), Namespace: testVmiNs}}
+
+	It("Should record the current revision", func() {
+		r.addRevision(snapshot)
+		Expect(r.vmi.Status.Revisions).Should(HaveLen(1))
+		Expect(r.vmi.Status.Revisions[0].Revision).Should(Equal(int32(2)))
+		Expect(r.vmi.Status.Revisions[0].SnapshotName).Should(Equal(GetSnapshotNameFromVmiName(testVmiName) + "-2"))
+		Expect(r.vmi.Status.Revisions[0].Digest).Should(Equal("sha256:" + testSHA256))
+		Expect(r.vmi.Status.Revisions[0].StorageRequest.String()).Should(Equal("3Gi"))
+	})
+	It("Should not record the revision again", func() {
+		r.addRevision(snapshot)
+		Expect(r.vmi.Status.Revisions).Should(HaveLen(1))
+	})
+})
+
+// 번호		revisions		limit		volume				result
+// 1		1, 2, 3			X								keep all
+// 2		1, 2, 3			1								delete 1, 2
+// 3		1, 2, 3			1			revision 1			delete 2
+// 4		1, 2, 3			2			pin revision 1		delete 2
+var _ = Describe("syncRevisionHistory", func() {
+	Context("1. with no revision history limit", func() {
+		r := createFakeReconcileRevisionHistoryVmi(nil)
+		err := r.syncRevisionHistory()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should keep all revisions", func() {
+			Expect(getTestRevisionNumbers(r)).Should(Equal([]int32{1, 2, 3}))
+		})
+	})
+
+	Context("2. with revision history limit 1", func() {
+		r := createFakeReconcileRevisionHistoryVmi(&[]int32{1}[0])
+		err := r.syncRevisionHistory()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should keep only the current revision", func() {
+			Expect(getTestRevisionNumbers(r)).Should(Equal([]int32{3}))
+		})
+		It("Should delete the snapshots of the old revisions", func() {
+			Expect(errors.IsNotFound(getTestRevisionSnapshot(r, 1))).Should(BeTrue())
+			Expect(errors.IsNotFound(getTestRevisionSnapshot(r, 2))).Should(BeTrue())
+			Expect(getTestRevisionSnapshot(r, 3)).Should(BeNil())
+		})
+	})
+
+	Context("3. with revision history limit 1 and volume of revision 1", func() {
+		volume := newTestVolumeOfVmi("myvmv", testVmiNs, "")
+		volume.Status.ImageRevision = 1
+		r := createFakeReconcileRevisionHistoryVmi(&[]int32{1}[0], volume)
+		err := r.syncRevisionHistory()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should keep the revision used by the volume", func() {
+			Expect(getTestRevisionNumbers(r)).Should(Equal([]int32{1, 3}))
+			Expect(getTestRevisionSnapshot(r, 1)).Should(BeNil())
+		})
+	})
+
+	Context("4. with revision history limit 2 and volume pinning revision 1", func() {
+		volume := newTestVolumeOfVmi("myvmv", testVmiNs, "")
+		volume.Spec.VirtualMachineImage.Revision = &[]int32{1}[0]
+		r := createFakeReconcileRevisionHistoryVmi(&[]int32{2}[0], volume)
+		err := r.syncRevisionHistory()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete the oldest revision which no volume uses", func() {
+			Expect(getTestRevisionNumbers(r)).Should(Equal([]int32{1, 3}))
+			Expect(errors.IsNotFound(getTestRevisionSnapshot(r, 2))).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("GetAvailableRevision", func() {
+	vmi := newTestVmi()
+	vmi.Status.Revision = 2
+	vmi.Status.Revisions = newTestRevisions(1, 2)
+
+	It("Should return the latest revision if revision is nil", func() {
+		revision, found := GetAvailableRevision(vmi, nil)
+		Expect(found).Should(BeTrue())
+		Expect(revision.Revision).Should(Equal(int32(2)))
+	})
+	It("Should return the pinned revision", func() {
+		revision, found := GetAvailableRevision(vmi, &[]int32{1}[0])
+		Expect(found).Should(BeTrue())
+		Expect(revision.SnapshotName).Should(Equal(GetSnapshotNameFromVmiName(testVmiName)))
+	})
+	It("Should not find the revision which is not available", func() {
+		_, found := GetAvailableRevision(vmi, &[]int32{3}[0])
+		Expect(found).Should(BeFalse())
+	})
+	It("Should return the current revision of the ready vmi without revisions", func() {
+		legacy := newTestVmi()
+		legacy.Status.Conditions = util.SetConditionByType(legacy.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", legacy.Generation)
+		revision, found := GetAvailableRevision(legacy, nil)
+		Expect(found).Should(BeTrue())
+		Expect(revision.Revision).Should(Equal(int32(1)))
+		Expect(revision.SnapshotName).Should(Equal(GetSnapshotNameFromVmiName(testVmiName)))
+	})
+	It("Should not find any revision of the vmi which is not ready", func() {
+		_, found := GetAvailableRevision(newTestVmi(), nil)
+		Expect(found).Should(BeFalse())
+	})
+})
+
+func createFakeReconcileRevisionVmi(objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	r := createFakeReconcileRefreshVmi(nil, nil, objects...)
+	r.vmi.Spec.RefreshPolicy = nil
+	return r
+}
+
+// createFakeReconcileRevisionHistoryVmi returns the reconciler of the vmi of revision 3 with the snapshots of the revisions 1, 2 and 3
+func createFakeReconcileRevisionHistoryVmi(limit *int32, objects ...runtime.Object) *ReconcileVirtualMachineImage {
+	vmi := newTestVmi()
+	vmi.Spec.RevisionHistoryLimit = limit
+	vmi.Status.State = hc.VirtualMachineImageStateAvailable
+	vmi.Status.Revision = 3
+	vmi.Status.Revisions = newTestRevisions(1, 2, 3)
+	for _, revision := range vmi.Status.Revisions {
+		objects = append(objects, &snapshotv1beta1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: revision.SnapshotName, Namespace: testVmiNs}})
+	}
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, vmi)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileVirtualMachineImage{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), vmi: vmi}
+}
+
+func newTestRevisions(revisions ...int32) []hc.VirtualMachineImageRevision {
+	var result []hc.VirtualMachineImageRevision
+	for _, revision := range revisions {
+		result = append(result, hc.VirtualMachineImageRevision{Revision: revision, SnapshotName: GetRevisionSnapshotName(testVmiName, revision)})
+	}
+	return result
+}
+
+func newTestRevisionPvc(revision int32, sourceHash string) *corev1.PersistentVolumeClaim {
+	pvc := newTestImporterPvc("yes")
+	pvc.Annotations[RevisionAnnotation] = strconv.Itoa(int(revision))
+	pvc.Annotations[SourceHashAnnotation] = sourceHash
+	return pvc
+}
+
+func getTestRevisionPvc(r *ReconcileVirtualMachineImage) error {
+	return r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: GetPvcNameFromVmiName(testVmiName)}, &corev1.PersistentVolumeClaim{})
+}
+
+func getTestRevisionSnapshot(r *ReconcileVirtualMachineImage, revision int32) error {
+	return r.client.Get(context.TODO(), types.NamespacedName{Namespace: testVmiNs, Name: GetRevisionSnapshotName(testVmiName, revision)}, &snapshotv1beta1.VolumeSnapshot{})
+}
+
+func getTestRevisionNumbers(r *ReconcileVirtualMachineImage) []int32 {
+	vmi, err := getTestVmi(r)
+	Expect(err).Should(BeNil())
+	var revisions []int32
+	for _, revision := range vmi.Status.Revisions {
+		revisions = append(revisions, revision.Revision)
+	}
+	return revisions
+}
@@ -4,15 +4,22 @@ import (
 	"context"
 	corev1 "k8s.io/api/core/v1"
 	errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 func (r *ReconcileVirtualMachineImage) syncScratchPvc() error {
+	if needs, err := r.needsScratchPvc(); err != nil {
+		return err
+	} else if !needs {
+		return nil
+	}
 	imported, found, err := r.isPvcImported()
 	if err != nil {
 		return err
@@ -33,25 +40,73 @@ func (r *ReconcileVirtualMachineImage) syncScratchPvc() error {
 		if err := r.client.Delete(context.TODO(), scratchPvc); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventPvcDeleted, "Deleted scratch pvc %s", scratchPvc.Name)
 	} else if !imported && !existsScratchPvc {
 		// 임포팅을 해야하므로 scratchPvc를 만든다
 		klog.Infof("Create scratchPvc for importing vmi: %s", r.vmi.Name)
-		newScratchPvc, err := newScratchPvc(r.vmi, r.scheme)
+		storageRequest, err := r.getScratchStorageRequest()
+		if err != nil {
+			return err
+		}
+		newScratchPvc, err := newScratchPvc(r.vmi, storageRequest, r.scheme)
 		if err != nil {
 			return err
 		}
 		if err := r.client.Create(context.TODO(), newScratchPvc); err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventPvcCreated, "Created scratch pvc %s", newScratchPvc.Name)
 	}
 	return nil
 }
 
+// needsScratchPvc returns true if the source image is fetched into the scratch pvc before converting it.
+// The raw source image streamed into the image pvc and the image pvc provisioned by the CSI driver don't need it
+func (r *ReconcileVirtualMachineImage) needsScratchPvc() (bool, error) {
+	src, err := r.getSource()
+	if err != nil {
+		return false, err
+	} else if src == SourceVolume || r.isStreamedSource(src) {
+		return false, nil
+	}
+	pvc, err := r.getPvc(r.vmi)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !isDataSourcePvc(pvc), nil
+}
+
 func getScratchPvcNameFromVmiName(vmiName string) string {
 	return vmiName + "-scratch-image-pvc"
 }
 
-func newScratchPvc(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1.PersistentVolumeClaim, error) {
+// getScratchStorageRequest returns the storage request of the Filesystem-mode scratch pvc which stores the fetched source image as a file.
+// The fetched source image is not bigger than the disk of the image pvc, so the file system overhead is added to the disk of the Block-mode image pvc,
+// which has no overhead. The registry source also stores the layer of the containerDisk in the scratch pvc, so it needs twice the disk
+func (r *ReconcileVirtualMachineImage) getScratchStorageRequest() (*resource.Quantity, error) {
+	storageRequest := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	src, err := r.getSource()
+	if err != nil {
+		return nil, err
+	} else if src != SourceRegistry && !util.IsBlockVolumeMode(r.vmi.Spec.PVC.VolumeMode) {
+		return &storageRequest, nil
+	}
+	overhead, err := r.getFilesystemOverhead()
+	if err != nil {
+		return nil, err
+	}
+	size := storageRequest.Value()
+	if src == SourceRegistry {
+		size *= 2
+	}
+	volumeMode := corev1.PersistentVolumeFilesystem
+	return getRequiredStorage(size, &volumeMode, overhead), nil
+}
+
+func newScratchPvc(vmi *hc.VirtualMachineImage, storageRequest *resource.Quantity, scheme *runtime.Scheme) (*corev1.PersistentVolumeClaim, error) {
 	volumeMode := corev1.PersistentVolumeFilesystem
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
@@ -59,9 +114,11 @@ func newScratchPvc(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1
 			Namespace: vmi.Namespace,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			VolumeMode:       &volumeMode,
-			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			Resources:        vmi.Spec.PVC.Resources,
+			VolumeMode:  &volumeMode,
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: *storageRequest},
+			},
 			StorageClassName: vmi.Spec.PVC.StorageClassName,
 		},
 	}
@@ -8,6 +8,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 )
 
 // 번호		pvc		annotation		scratchPvc
@@ -17,6 +18,9 @@ import (
 // 4		O		no				O
 // 5		O		yes				X
 // 6		O		yes				O
+// 7		O		no				X				(virtualMachineVolume source)
+// 8		O		no				X				(Block-mode image pvc)
+// 9		O		no				X				(http source with raw format)
 var _ = Describe("syncScratchPvc", func() {
 	Context("1. with no pvc", func() {
 		r := createFakeReconcileVmi()
@@ -145,4 +149,52 @@ var _ = Describe("syncScratchPvc", func() {
 			Expect(errors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
+
+	Context("7. with virtualMachineVolume source, pvc(imported: no) and no scratchPvc", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{VirtualMachineVolume: &hc.VirtualMachineImageSourceVolume{Name: "testvmv"}}
+		err := r.syncScratchPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create scratchPvc", func() {
+			scratchPvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: getScratchPvcNameFromVmiName(r.vmi.Name)}, scratchPvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+	Context("8. with Block-mode image pvc, pvc(imported: no) and no scratchPvc", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		volumeMode := corev1.PersistentVolumeBlock
+		r.vmi.Spec.PVC.VolumeMode = &volumeMode
+		err := r.syncScratchPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create Filesystem-mode scratchPvc with the file system overhead", func() {
+			scratchPvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: getScratchPvcNameFromVmiName(r.vmi.Name)}, scratchPvc)
+			Expect(err).Should(BeNil())
+			Expect(*scratchPvc.Spec.VolumeMode).Should(Equal(corev1.PersistentVolumeFilesystem))
+			storageRequest := scratchPvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(storageRequest.String()).Should(Equal("3251Mi"))
+		})
+	})
+
+	Context("9. with http source with raw format, pvc(imported: no) and no scratchPvc", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		r.vmi.Spec.Source.Format = hc.VirtualMachineImageFormatRaw
+		err := r.syncScratchPvc()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create scratchPvc because the source image is streamed", func() {
+			scratchPvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: getScratchPvcNameFromVmiName(r.vmi.Name)}, scratchPvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
 })
@@ -2,7 +2,6 @@ package virtualmachineimage
 
 import (
 	"context"
-	goerrors "errors"
 	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -11,7 +10,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
 )
 
 func (r *ReconcileVirtualMachineImage) syncSnapshot() error {
@@ -23,7 +24,7 @@ func (r *ReconcileVirtualMachineImage) syncSnapshot() error {
 	}
 
 	snapshot := &snapshotv1beta1.VolumeSnapshot{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetSnapshotNameFromVmiName(r.vmi.Name)}, snapshot)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetImageSnapshotName(r.vmi)}, snapshot)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
@@ -39,12 +40,17 @@ func (r *ReconcileVirtualMachineImage) syncSnapshot() error {
 		if err := r.client.Create(context.TODO(), newSnapshot); err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotCreated, "Created snapshot %s", newSnapshot.Name)
 	} else if imported && existsSnapshot && snapshot.Status != nil {
 		if snapshot.Status.Error != nil {
-			return goerrors.New("Snapshot is error for vmi " + r.vmi.Name)
+			return &vmiError{reason: hc.ReasonSnapshotFailed, message: "Snapshot is error for vmi " + r.vmi.Name}
 		} else if *snapshot.Status.ReadyToUse {
 			// 임포트 되어 있고 스냅샷도 있다면 스냅샷의 readyToUse에 따라 상태를 변경한다.
-			if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateAvailable, corev1.ConditionTrue, "VmiIsReady", "Vmi is ready to use"); err != nil {
+			if r.vmi.Status.State != hc.VirtualMachineImageStateAvailable {
+				r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotReady, "Snapshot %s of revision %d is ready to use", snapshot.Name, GetRevision(r.vmi))
+			}
+			r.addRevision(snapshot)
+			if err := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateAvailable, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use"); err != nil {
 				return err
 			}
 		}
@@ -53,6 +59,7 @@ func (r *ReconcileVirtualMachineImage) syncSnapshot() error {
 		if err := r.client.Delete(context.TODO(), snapshot); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmi, corev1.EventTypeNormal, util.EventSnapshotDeleted, "Deleted snapshot %s of the image pvc which is not imported", snapshot.Name)
 	}
 
 	return nil
@@ -63,11 +70,24 @@ func GetSnapshotNameFromVmiName(vmiName string) string {
 	return vmiName + "-image-snapshot"
 }
 
+// GetRevisionSnapshotName returns the snapshot name of the revision of vmiName. The first revision has the name without the revision
+func GetRevisionSnapshotName(vmiName string, revision int32) string {
+	if revision <= 1 {
+		return GetSnapshotNameFromVmiName(vmiName)
+	}
+	return GetSnapshotNameFromVmiName(vmiName) + "-" + strconv.Itoa(int(revision))
+}
+
+// GetImageSnapshotName returns the snapshot name of the current revision of the vmi
+func GetImageSnapshotName(vmi *hc.VirtualMachineImage) string {
+	return GetRevisionSnapshotName(vmi.Name, GetRevision(vmi))
+}
+
 func newSnapshot(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*snapshotv1beta1.VolumeSnapshot, error) {
 	pvcName := GetPvcNameFromVmiName(vmi.Name)
 	snapshot := &snapshotv1beta1.VolumeSnapshot{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      GetSnapshotNameFromVmiName(vmi.Name),
+			Name:      GetImageSnapshotName(vmi),
 			Namespace: vmi.Namespace,
 		},
 		Spec: snapshotv1beta1.VolumeSnapshotSpec{
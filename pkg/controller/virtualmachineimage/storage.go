@@ -0,0 +1,121 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"fmt"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+)
+
+const (
+	// ReasonStorageValid is the reason of StorageValidated condition when the storage class and the snapshot class are compatible
+	ReasonStorageValid = "StorageValid"
+	// ReasonWaitForFirstConsumer is the reason of StorageValidated condition when the storage class binds the pvc late, which is a warning
+	ReasonWaitForFirstConsumer = "WaitForFirstConsumer"
+	// ReasonNoDefaultStorageClass is the reason of StorageValidated condition when the storage class is not set and there is no default
+	ReasonNoDefaultStorageClass = "NoDefaultStorageClass"
+	// ReasonStorageClassNotFound is the reason of StorageValidated condition when the storage class doesn't exist
+	ReasonStorageClassNotFound = "StorageClassNotFound"
+	// ReasonBlockNotSupported is the reason of StorageValidated condition when the storage class can't provision the Block-mode pvc
+	ReasonBlockNotSupported = "BlockNotSupported"
+	// ReasonSnapshotClassNotFound is the reason of StorageValidated condition when the snapshot class doesn't exist
+	ReasonSnapshotClassNotFound = "SnapshotClassNotFound"
+	// ReasonSnapshotClassMismatch is the reason of StorageValidated condition when the driver of the snapshot class is not the provisioner of the storage class
+	ReasonSnapshotClassMismatch = "SnapshotClassMismatch"
+)
+
+// filesystemOnlyProvisioners are the provisioners of the shared filesystems and the host paths, which can't provision the Block-mode pvc
+var filesystemOnlyProvisioners = map[string]bool{
+	"cephfs.csi.ceph.com":                         true,
+	"nfs.csi.k8s.io":                              true,
+	"efs.csi.aws.com":                             true,
+	"file.csi.azure.com":                          true,
+	"filestore.csi.storage.gke.io":                true,
+	"kubernetes.io/azure-file":                    true,
+	"kubernetes.io/cephfs":                        true,
+	"kubernetes.io/glusterfs":                     true,
+	"k8s-sigs.io/nfs-subdir-external-provisioner": true,
+	"k8s.io/minikube-hostpath":                    true,
+	"rancher.io/local-path":                       true,
+}
+
+// syncStorageValidation checks the storage class and the snapshot class before the image pvc is created, and records the result
+// in StorageValidated condition. The vmi fails with the reason of the condition if the image can't be imported or snapshotted with them
+func (r *ReconcileVirtualMachineImage) syncStorageValidation() error {
+	if _, err := r.getPvc(r.vmi); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	status, reason, message, err := r.validateStorage()
+	if err != nil {
+		return err
+	}
+	if status == corev1.ConditionFalse {
+		// ReadyToUse 조건과 함께 저장된다
+		r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionStorageValidated, status, reason, message, r.vmi.Generation)
+		return &vmiError{reason: reason, message: message}
+	}
+	if found, cond := util.GetConditionByType(r.vmi.Status.Conditions, hc.ConditionStorageValidated); found &&
+		cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return nil
+	}
+	if reason == ReasonWaitForFirstConsumer {
+		klog.Warningf("Storage of vmi %s: %s", r.vmi.Name, message)
+	}
+	r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionStorageValidated, status, reason, message, r.vmi.Generation)
+	return r.client.Status().Update(context.TODO(), r.vmi)
+}
+
+// validateStorage returns the status, the reason and the message of StorageValidated condition.
+// The pvc without the storage class uses the default StorageClass of the cluster
+func (r *ReconcileVirtualMachineImage) validateStorage() (corev1.ConditionStatus, string, string, error) {
+	storageClassName := ""
+	if r.vmi.Spec.PVC.StorageClassName != nil {
+		storageClassName = *r.vmi.Spec.PVC.StorageClassName
+	} else if name, found, err := util.GetDefaultStorageClassName(r.client); err != nil {
+		return "", "", "", err
+	} else if !found {
+		return corev1.ConditionFalse, ReasonNoDefaultStorageClass, "storageClassName is not set and there is no default StorageClass", nil
+	} else {
+		storageClassName = name
+	}
+
+	storageClass := &storagev1.StorageClass{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: storageClassName}, storageClass); err != nil {
+		if errors.IsNotFound(err) {
+			return corev1.ConditionFalse, ReasonStorageClassNotFound, fmt.Sprintf("StorageClass %s doesn't exist", storageClassName), nil
+		}
+		return "", "", "", err
+	}
+	if util.IsBlockVolumeMode(r.vmi.Spec.PVC.VolumeMode) && filesystemOnlyProvisioners[storageClass.Provisioner] {
+		return corev1.ConditionFalse, ReasonBlockNotSupported, fmt.Sprintf("provisioner %s of StorageClass %s doesn't support Block volumeMode. "+
+			"Use Filesystem volumeMode or another StorageClass", storageClass.Provisioner, storageClassName), nil
+	}
+
+	snapshotClass := &snapshotv1beta1.VolumeSnapshotClass{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: r.vmi.Spec.SnapshotClassName}, snapshotClass); err != nil {
+		if errors.IsNotFound(err) {
+			return corev1.ConditionFalse, ReasonSnapshotClassNotFound, fmt.Sprintf("VolumeSnapshotClass %s doesn't exist", r.vmi.Spec.SnapshotClassName), nil
+		}
+		return "", "", "", err
+	}
+	if snapshotClass.Driver != storageClass.Provisioner {
+		return corev1.ConditionFalse, ReasonSnapshotClassMismatch, fmt.Sprintf("driver %s of VolumeSnapshotClass %s doesn't match provisioner %s of StorageClass %s",
+			snapshotClass.Driver, snapshotClass.Name, storageClass.Provisioner, storageClassName), nil
+	}
+
+	if storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		return corev1.ConditionTrue, ReasonWaitForFirstConsumer, fmt.Sprintf("StorageClass %s binds the pvc when its first pod is scheduled, "+
+			"so the image pvc is pending until the pod which imports the image is scheduled", storageClassName), nil
+	}
+	return corev1.ConditionTrue, ReasonStorageValid, fmt.Sprintf("StorageClass %s and VolumeSnapshotClass %s are compatible",
+		storageClassName, snapshotClass.Name), nil
+}
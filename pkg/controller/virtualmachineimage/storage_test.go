@@ -0,0 +1,151 @@
+package virtualmachineimage
+
+import (
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+)
+
+const (
+	testProvisioner = "rook-ceph.rbd.csi.ceph.com"
+)
+
+// 번호		pvc		storageClass			volumeMode		snapshotClass		result
+// 1		X		X(no default)									NoDefaultStorageClass
+// 2		X		O(not exists)									StorageClassNotFound
+// 3		X		O(cephfs)				Block							BlockNotSupported
+// 4		X		O						Filesystem		X				SnapshotClassNotFound
+// 5		X		O						Filesystem		O(other driver)	SnapshotClassMismatch
+// 6		X		O(WaitForFirstConsumer)	Filesystem		O				WaitForFirstConsumer
+// 7		X		O						Filesystem		O				StorageValid
+// 8		O															not validated
+var _ = Describe("syncStorageValidation", func() {
+	Context("1. with no storage class and no default StorageClass", func() {
+		r := createFakeReconcileVmi()
+		r.vmi.Spec.PVC.StorageClassName = nil
+		err := r.syncStorageValidation()
+
+		It("Should return error of the reason", func() {
+			expectTestStorageError(r, err, ReasonNoDefaultStorageClass)
+		})
+	})
+
+	Context("2. with storage class which doesn't exist", func() {
+		r := createFakeReconcileVmi()
+		err := r.syncStorageValidation()
+
+		It("Should return error of the reason", func() {
+			expectTestStorageError(r, err, ReasonStorageClassNotFound)
+		})
+	})
+
+	Context("3. with storage class of filesystem and Block volumeMode", func() {
+		r := createFakeReconcileVmi(newTestStorageClass("cephfs.csi.ceph.com", nil), newTestSnapshotClass("cephfs.csi.ceph.com"))
+		volumeMode := corev1.PersistentVolumeBlock
+		r.vmi.Spec.PVC.VolumeMode = &volumeMode
+		err := r.syncStorageValidation()
+
+		It("Should return error of the reason", func() {
+			expectTestStorageError(r, err, ReasonBlockNotSupported)
+		})
+	})
+
+	Context("4. with snapshot class which doesn't exist", func() {
+		r := createFakeReconcileVmi(newTestStorageClass(testProvisioner, nil))
+		err := r.syncStorageValidation()
+
+		It("Should return error of the reason", func() {
+			expectTestStorageError(r, err, ReasonSnapshotClassNotFound)
+		})
+	})
+
+	Context("5. with snapshot class of other driver", func() {
+		r := createFakeReconcileVmi(newTestStorageClass(testProvisioner, nil), newTestSnapshotClass("hostpath.csi.k8s.io"))
+		err := r.syncStorageValidation()
+
+		It("Should return error of the reason", func() {
+			expectTestStorageError(r, err, ReasonSnapshotClassMismatch)
+		})
+	})
+
+	Context("6. with storage class of WaitForFirstConsumer", func() {
+		bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+		r := createFakeReconcileVmi(newTestStorageClass(testProvisioner, &bindingMode), newTestSnapshotClass(testProvisioner))
+		err := r.syncStorageValidation()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should warn in StorageValidated condition", func() {
+			expectTestStorageValidated(r, corev1.ConditionTrue, ReasonWaitForFirstConsumer)
+		})
+	})
+
+	Context("7. with compatible storage class and snapshot class", func() {
+		r := createFakeReconcileVmi(newTestStorageClass(testProvisioner, nil), newTestSnapshotClass(testProvisioner))
+		err := r.syncStorageValidation()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update StorageValidated condition", func() {
+			expectTestStorageValidated(r, corev1.ConditionTrue, ReasonStorageValid)
+		})
+	})
+
+	Context("8. with pvc", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("no"))
+		err := r.syncStorageValidation()
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not validate the storage", func() {
+			vmi, err := getTestVmi(r)
+			Expect(err).Should(BeNil())
+			found, _ := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionStorageValidated)
+			Expect(found).Should(BeFalse())
+		})
+	})
+})
+
+func expectTestStorageError(r *ReconcileVirtualMachineImage, err error, reason string) {
+	vmiErr, ok := err.(*vmiError)
+	Expect(ok).Should(BeTrue())
+	Expect(vmiErr.reason).Should(Equal(reason))
+	found, cond := util.GetConditionByType(r.vmi.Status.Conditions, hc.ConditionStorageValidated)
+	Expect(found).Should(BeTrue())
+	Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+	Expect(cond.Reason).Should(Equal(reason))
+}
+
+func expectTestStorageValidated(r *ReconcileVirtualMachineImage, status corev1.ConditionStatus, reason string) {
+	vmi, err := getTestVmi(r)
+	Expect(err).Should(BeNil())
+	found, cond := util.GetConditionByType(vmi.Status.Conditions, hc.ConditionStorageValidated)
+	Expect(found).Should(BeTrue())
+	Expect(cond.Status).Should(Equal(status))
+	Expect(cond.Reason).Should(Equal(reason))
+}
+
+func newTestStorageClass(provisioner string, bindingMode *storagev1.VolumeBindingMode) runtime.Object {
+	return &storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: testStorageClassName},
+		Provisioner:       provisioner,
+		VolumeBindingMode: bindingMode,
+	}
+}
+
+func newTestSnapshotClass(driver string) runtime.Object {
+	return &snapshotv1beta1.VolumeSnapshotClass{
+		ObjectMeta:     metav1.ObjectMeta{Name: testSnapshotClassName},
+		Driver:         driver,
+		DeletionPolicy: snapshotv1beta1.VolumeSnapshotContentDelete,
+	}
+}
@@ -1,10 +1,13 @@
 package virtualmachineimage
 
 import (
+	"context"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	"kubevirt-image-service/pkg/util"
 )
@@ -13,6 +16,7 @@ const (
 	testVmiName           = "testvmi"
 	testVmiNs             = "default"
 	testSnapshotClassName = "testSnapshotClassName"
+	testEventBufferSize   = 100
 )
 
 var (
@@ -25,7 +29,14 @@ func createFakeReconcileVmi(objects ...runtime.Object) *ReconcileVirtualMachineI
 	if err != nil {
 		panic(err)
 	}
-	return &ReconcileVirtualMachineImage{client: client, scheme: scheme, vmi: vmi}
+	return &ReconcileVirtualMachineImage{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), vmi: vmi}
+}
+
+// getTestVmi returns the vmi of r stored in the fake client
+func getTestVmi(r *ReconcileVirtualMachineImage) (*hc.VirtualMachineImage, error) {
+	vmi := &hc.VirtualMachineImage{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: r.vmi.Name}, vmi)
+	return vmi, err
 }
 
 func newTestVmi() *hc.VirtualMachineImage {
@@ -0,0 +1,64 @@
+package virtualmachineimage
+
+const (
+	// UnpackedSuffix is the suffix of the directory where the archived source image is extracted
+	UnpackedSuffix = ".unpacked"
+	// PackedMarkerFile is the file which marks the source image is packed, so the size of the source is not the total of the progress
+	PackedMarkerFile = "/tmp/source.packed"
+	// WriterPidFile is the file where unpack writes the pid of the writer which streams the source image into the pvc
+	WriterPidFile = "/tmp/writer.pid"
+	// WriterLogFile is the file where the writer which streams the source image into the pvc writes the number of the written bytes
+	WriterLogFile = "/tmp/writer.log"
+)
+
+// UnpackScript defines the shell functions which detect and unpack the compressed or archived source image.
+// packing prints gz, xz, zst or tar(including ova) by the magic bytes of the file $1, or nothing if it is not packed.
+// unpack reads the source image from stdin and writes the unpacked image to the file $1.
+// fail keeps the first message because unpack fails in the nested pipelines of the nested compression.
+// zst is detected only to fail clearly because the fetcher image has no zstd.
+// The compressed image is decompressed while streaming, and the archive is extracted into the scratch space
+// next to $1 because the disk image in it can't be found without extracting. The largest file is the disk image.
+// If streaming is set, $1 is the device or the disk file of the image pvc, which the raw image is written to by dd without the scratch space,
+// so the archive can't be streamed
+const UnpackScript = `fail() {
+  [ -s /dev/termination-log ] || echo "$1" > /dev/termination-log
+  exit 1
+}
+packing() {
+  case "$(od -An -tx1 -N6 "$1" | tr -d ' \n')" in
+    1f8b*) echo gz ;;
+    fd377a585a00) echo xz ;;
+    28b52ffd*) echo zst ;;
+    *) if [ "$(dd if="$1" bs=1 skip=257 count=5 2>/dev/null)" = ustar ]; then echo tar; fi ;;
+  esac
+}
+unpack() {
+  header="/tmp/header$2"
+  dd of="$header" bs=512 count=1 iflag=fullblock 2>/dev/null
+  packed=$(packing "$header")
+  if [ -z "$2" ] && [ -n "$packed" ]; then
+    touch ` + PackedMarkerFile + `
+  fi
+  case "$packed" in
+    gz) cat "$header" - | gzip -dc | unpack "$1" "$(($2+1))" || fail "failed to decompress gz source image" ;;
+    xz) cat "$header" - | xz -dc | unpack "$1" "$(($2+1))" || fail "failed to decompress xz source image" ;;
+    zst) fail "zst source image is not supported. Compress it with gz or xz" ;;
+    tar)
+      [ -z "$streaming" ] || fail "archived source image can't be streamed into pvc. Unset format to extract it in the scratch pvc"
+      mkdir -p "$1` + UnpackedSuffix + `"
+      cat "$header" - | tar -x -C "$1` + UnpackedSuffix + `" || fail "failed to extract source archive"
+      disk=$(find "$1` + UnpackedSuffix + `" -type f ! -name '*.ovf' ! -name '*.mf' ! -name '*.cert' -exec ls -1S {} + | head -n 1)
+      [ -n "$disk" ] || fail "disk image is not found in source archive"
+      mv "$disk" "$1"
+      rm -rf "$1` + UnpackedSuffix + `" ;;
+    *)
+      if [ -n "$streaming" ]; then
+        cat "$header" - | sh -c 'echo $$ > ` + WriterPidFile + `; exec dd of="$1" bs=1M 2> ` + WriterLogFile + `' writer "$1" ||
+          fail "failed to write source image into pvc: $(head -n 1 ` + WriterLogFile + `)"
+      else
+        cat "$header" - > "$1"
+      fi ;;
+  esac
+  rm -f "$header"
+}
+`
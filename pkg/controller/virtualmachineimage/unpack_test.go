@@ -0,0 +1,26 @@
+package virtualmachineimage
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"regexp"
+)
+
+var _ = Describe("UnpackScript", func() {
+	// busybox applets of curlimages/curl:7.75.0 which unpack the source image. zstd is not one of them
+	fetcherImageTools := []string{"gzip", "gunzip", "xz", "unxz", "tar"}
+
+	It("Should list the tools of the default fetcher image", func() {
+		Expect(FetcherImage).Should(Equal("curlimages/curl:7.75.0"))
+	})
+	It("Should unpack only with the tools of the fetcher image", func() {
+		tools := regexp.MustCompile(`\| (\w+) -(?:dc|x)`).FindAllStringSubmatch(UnpackScript, -1)
+		Expect(tools).ShouldNot(BeEmpty())
+		for _, tool := range tools {
+			Expect(fetcherImageTools).Should(ContainElement(tool[1]))
+		}
+	})
+	It("Should fail the zst source image", func() {
+		Expect(UnpackScript).Should(ContainSubstring(`zst) fail "zst source image is not supported`))
+	})
+})
@@ -0,0 +1,248 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// UploadTokenKey is the key of the upload token in the upload token secret
+	UploadTokenKey = "token"
+	// UploadTokenExpirationKey is the key of the expiration time(RFC3339) of the upload token in the upload token secret
+	UploadTokenExpirationKey = "expiration"
+	// UploadPreviousTokenKey is the key of the replaced upload token in the upload token secret. It is valid until its expiration
+	UploadPreviousTokenKey = "previousToken"
+	// UploadPreviousTokenExpirationKey is the key of the expiration time(RFC3339) of the replaced upload token in the upload token secret
+	UploadPreviousTokenExpirationKey = "previousExpiration"
+	// UploadTokenTTL is the lifetime of the upload token
+	UploadTokenTTL = 15 * time.Minute
+	// UploadTokenRefreshInterval is an interval to check the upload token. The token expiring within this interval is refreshed
+	UploadTokenRefreshInterval = 5 * time.Minute
+	uploadTokenLength          = 32
+	// UploadServerName is the dns name of the upload server certificate. The upload proxy and the clone source pod
+	// connect to the pod ip of the upload server with this server name
+	UploadServerName = "upload-server.kubevirt-image-service"
+	// UploadClientName is the common name of the client certificate which the upload server accepts
+	UploadClientName = "client.upload-server.kubevirt-image-service"
+	// UploadClientCertKey is the key of the client certificate in the upload server cert secret
+	UploadClientCertKey = "client.crt"
+	// UploadClientKeyKey is the key of the private key of the client certificate in the upload server cert secret
+	UploadClientKeyKey = "client.key"
+	// uploadServerCertValidity is the lifetime of the upload server certificates. They are deleted when the import is complete
+	uploadServerCertValidity = 365 * 24 * time.Hour
+)
+
+func (r *ReconcileVirtualMachineImage) syncUpload() error {
+	src, err := r.getSource()
+	if err != nil {
+		return err
+	} else if src != SourceUpload && src != SourcePVC {
+		return nil
+	}
+	if err := r.syncUploadServerCert(); err != nil {
+		return err
+	}
+	if src != SourceUpload {
+		return nil
+	}
+	return r.syncUploadToken()
+}
+
+// syncUploadServerCert creates the certificates of the upload server and its client until the upload or the clone is complete.
+// The upload server accepts only the client certificate, so only the upload proxy and the clone source pod can send the image
+func (r *ReconcileVirtualMachineImage) syncUploadServerCert() error {
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	} else if !found {
+		return nil
+	}
+
+	certSecret := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)}, certSecret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	existsCertSecret := err == nil
+
+	if imported && existsCertSecret {
+		// 임포팅이 완료됐으니 업로드 서버 인증서를 삭제한다
+		klog.Infof("Delete upload server cert because importing completed vmi: %s", r.vmi.Name)
+		if err := r.client.Delete(context.TODO(), certSecret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	} else if !imported && !existsCertSecret {
+		// 업로드 서버가 클라이언트 인증서로만 이미지를 받도록 인증서를 만든다
+		klog.Infof("Create upload server cert for vmi: %s", r.vmi.Name)
+		newCertSecret, err := newUploadServerCertSecret(r.vmi, r.scheme)
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newCertSecret); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ReconcileVirtualMachineImage) syncUploadToken() error {
+	imported, found, err := r.isPvcImported()
+	if err != nil {
+		return err
+	} else if !found {
+		return nil
+	}
+
+	tokenSecret := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	existsTokenSecret := err == nil
+
+	if imported && existsTokenSecret {
+		// 업로드가 완료됐으니 토큰을 삭제한다
+		klog.Infof("Delete upload token because uploading completed vmi: %s", r.vmi.Name)
+		if err := r.client.Delete(context.TODO(), tokenSecret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	} else if !imported && existsTokenSecret && isUploadTokenExpiring(tokenSecret, time.Now()) {
+		// 토큰이 곧 만료되므로 새 토큰으로 교체한다. 이미 받아간 토큰은 만료될 때까지 이전 토큰으로 유효하다
+		klog.Infof("Refresh upload token for vmi: %s", r.vmi.Name)
+		if err := setNewUploadToken(tokenSecret); err != nil {
+			return err
+		}
+		if err := r.client.Update(context.TODO(), tokenSecret); err != nil {
+			return err
+		}
+	} else if !imported && !existsTokenSecret {
+		// 업로드를 해야 하므로 토큰을 만든다
+		klog.Infof("Create upload token for vmi: %s", r.vmi.Name)
+		newTokenSecret, err := newUploadTokenSecret(r.vmi, r.scheme)
+		if err != nil {
+			return err
+		}
+		if err := r.client.Create(context.TODO(), newTokenSecret); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUploadTokenSecretNameFromVmiName returns the name of the upload token secret from vmiName
+func GetUploadTokenSecretNameFromVmiName(vmiName string) string {
+	return vmiName + "-upload-token"
+}
+
+func newUploadTokenSecret(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetUploadTokenSecretNameFromVmiName(vmi.Name),
+			Namespace: vmi.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	if err := setNewUploadToken(secret); err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(vmi, secret, scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// GetUploadServerCertSecretNameFromVmiName returns the name of the upload server cert secret from vmiName
+func GetUploadServerCertSecretNameFromVmiName(vmiName string) string {
+	return vmiName + "-upload-server-cert"
+}
+
+// newUploadServerCertSecret returns the secret of the self-signed server certificate of the upload server and the self-signed
+// client certificate. Each certificate is the CA of itself, so the peers trust only the certificates of this image
+func newUploadServerCertSecret(vmi *hc.VirtualMachineImage, scheme *runtime.Scheme) (*corev1.Secret, error) {
+	serverCert, serverKey, err := util.NewSelfSignedCert(UploadServerName, []string{UploadServerName}, x509.ExtKeyUsageServerAuth, uploadServerCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	clientCert, clientKey, err := util.NewSelfSignedCert(UploadClientName, nil, x509.ExtKeyUsageClientAuth, uploadServerCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetUploadServerCertSecretNameFromVmiName(vmi.Name),
+			Namespace: vmi.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       serverCert,
+			corev1.TLSPrivateKeyKey: serverKey,
+			UploadClientCertKey:     clientCert,
+			UploadClientKeyKey:      clientKey,
+		},
+	}
+	if err := controllerutil.SetControllerReference(vmi, secret, scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// setNewUploadToken sets a new upload token to the secret. The current token is kept as the previous token,
+// so the token which the user already got is valid until its expiration
+func setNewUploadToken(secret *corev1.Secret) error {
+	b := make([]byte, uploadTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	data := map[string][]byte{
+		UploadTokenKey:           []byte(base64.RawURLEncoding.EncodeToString(b)),
+		UploadTokenExpirationKey: []byte(time.Now().Add(UploadTokenTTL).UTC().Format(time.RFC3339)),
+	}
+	if token, ok := secret.Data[UploadTokenKey]; ok {
+		data[UploadPreviousTokenKey] = token
+		data[UploadPreviousTokenExpirationKey] = secret.Data[UploadTokenExpirationKey]
+	}
+	secret.Data = data
+	return nil
+}
+
+// GetUploadTokenExpiration returns the expiration time of the upload token in the secret
+func GetUploadTokenExpiration(secret *corev1.Secret) (time.Time, error) {
+	return time.Parse(time.RFC3339, string(secret.Data[UploadTokenExpirationKey]))
+}
+
+// FindUploadTokenExpiration returns the expiration time of the token if it is the current or the previous upload token in the secret.
+// The expiration time is zero if it is invalid
+func FindUploadTokenExpiration(secret *corev1.Secret, token string) (time.Time, bool) {
+	for _, keys := range [][2]string{{UploadTokenKey, UploadTokenExpirationKey}, {UploadPreviousTokenKey, UploadPreviousTokenExpirationKey}} {
+		if subtle.ConstantTimeCompare(secret.Data[keys[0]], []byte(token)) != 1 {
+			continue
+		}
+		expiration, err := time.Parse(time.RFC3339, string(secret.Data[keys[1]]))
+		if err != nil {
+			return time.Time{}, true
+		}
+		return expiration, true
+	}
+	return time.Time{}, false
+}
+
+func isUploadTokenExpiring(secret *corev1.Secret, now time.Time) bool {
+	expiration, err := GetUploadTokenExpiration(secret)
+	if err != nil {
+		return true
+	}
+	return expiration.Before(now.Add(UploadTokenRefreshInterval))
+}
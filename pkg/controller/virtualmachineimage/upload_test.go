@@ -0,0 +1,239 @@
+package virtualmachineimage
+
+import (
+	"context"
+	"crypto/tls"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"time"
+)
+
+// 번호		source		pvc		imported
+// 1		http		O		no
+// 2		upload		O		no
+// 3		pvc			O		no
+var _ = Describe("syncUpload", func() {
+	Context("1. with http source", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		r := createFakeReconcileVmi(notImportedPvc)
+		err := r.syncUpload()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create upload token", func() {
+			tokenSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("2. with upload source", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		r := createFakeReconcileVmi(notImportedPvc)
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{Upload: &hc.VirtualMachineImageSourceUpload{}}
+		err := r.syncUpload()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create upload token", func() {
+			tokenSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+			Expect(err).Should(BeNil())
+		})
+		It("Should create upload server cert", func() {
+			certSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)}, certSecret)
+			Expect(err).Should(BeNil())
+			_, err = tls.X509KeyPair(certSecret.Data[corev1.TLSCertKey], certSecret.Data[corev1.TLSPrivateKeyKey])
+			Expect(err).Should(BeNil())
+			_, err = tls.X509KeyPair(certSecret.Data[UploadClientCertKey], certSecret.Data[UploadClientKeyKey])
+			Expect(err).Should(BeNil())
+		})
+	})
+
+	Context("3. with pvc source", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		r := createFakeReconcileVmi(notImportedPvc)
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{PVC: &hc.VirtualMachineImageSourcePVC{Name: testSourcePvcName}}
+		err := r.syncUpload()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create upload token", func() {
+			tokenSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should create upload server cert", func() {
+			certSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)}, certSecret)
+			Expect(err).Should(BeNil())
+		})
+	})
+})
+
+// 번호		pvc		imported		cert
+// 1		O		yes				O
+var _ = Describe("syncUploadServerCert", func() {
+	Context("1. with pvc, imported=yes, cert", func() {
+		r := createFakeReconcileVmi(newTestImporterPvc("yes"), newTestUploadServerCertSecret())
+		r.vmi.Spec.Source = hc.VirtualMachineImageSource{Upload: &hc.VirtualMachineImageSourceUpload{}}
+		err := r.syncUploadServerCert()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete the cert", func() {
+			certSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadServerCertSecretNameFromVmiName(r.vmi.Name)}, certSecret)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+// 번호		pvc		imported		token		expiring
+// 1		X
+// 2		O		no				X
+// 3		O		no				O			no
+// 4		O		no				O			yes
+// 5		O		yes				O			no
+var _ = Describe("syncUploadToken", func() {
+	Context("1. with no pvc", func() {
+		r := createFakeReconcileVmi()
+		err := r.syncUploadToken()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create upload token", func() {
+			tokenSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("2. with pvc, imported=no, no token", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		r := createFakeReconcileVmi(notImportedPvc)
+		err := r.syncUploadToken()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create a token which is not expiring", func() {
+			tokenSecret := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, tokenSecret)
+			Expect(err).Should(BeNil())
+			Expect(tokenSecret.Data[UploadTokenKey]).ShouldNot(BeEmpty())
+			Expect(isUploadTokenExpiring(tokenSecret, time.Now())).Should(BeFalse())
+		})
+	})
+
+	Context("3. with pvc, imported=no, token which is not expiring", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		tokenSecret := newTestUploadTokenSecret(time.Now().Add(UploadTokenTTL))
+		r := createFakeReconcileVmi(notImportedPvc, tokenSecret)
+		err := r.syncUploadToken()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not change the token", func() {
+			s := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, s)
+			Expect(err).Should(BeNil())
+			Expect(s.Data[UploadTokenKey]).Should(Equal(tokenSecret.Data[UploadTokenKey]))
+		})
+	})
+
+	Context("4. with pvc, imported=no, token which is expiring", func() {
+		notImportedPvc := newTestImporterPvc("no")
+		tokenSecret := newTestUploadTokenSecret(time.Now().Add(time.Minute))
+		r := createFakeReconcileVmi(notImportedPvc, tokenSecret)
+		err := r.syncUploadToken()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should refresh the token", func() {
+			s := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, s)
+			Expect(err).Should(BeNil())
+			Expect(s.Data[UploadTokenKey]).ShouldNot(Equal(tokenSecret.Data[UploadTokenKey]))
+			Expect(isUploadTokenExpiring(s, time.Now())).Should(BeFalse())
+		})
+		It("Should keep the previous token until its expiration", func() {
+			s := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, s)
+			Expect(err).Should(BeNil())
+			expiration, found := FindUploadTokenExpiration(s, "testtoken")
+			Expect(found).Should(BeTrue())
+			Expect(expiration.Format(time.RFC3339)).Should(Equal(string(tokenSecret.Data[UploadTokenExpirationKey])))
+		})
+	})
+
+	Context("5. with pvc, imported=yes, token", func() {
+		importedPvc := newTestImporterPvc("yes")
+		tokenSecret := newTestUploadTokenSecret(time.Now().Add(UploadTokenTTL))
+		r := createFakeReconcileVmi(importedPvc, tokenSecret)
+		err := r.syncUploadToken()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should delete the token", func() {
+			s := &corev1.Secret{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmi.Namespace, Name: GetUploadTokenSecretNameFromVmiName(r.vmi.Name)}, s)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+func newTestImporterPvc(imported string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetPvcNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+			Annotations: map[string]string{
+				"imported": imported,
+			},
+		},
+	}
+}
+
+func newTestUploadTokenSecret(expiration time.Time) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetUploadTokenSecretNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Data: map[string][]byte{
+			UploadTokenKey:           []byte("testtoken"),
+			UploadTokenExpirationKey: []byte(expiration.UTC().Format(time.RFC3339)),
+		},
+	}
+}
+
+// newTestUploadServerCertSecret returns the upload server cert secret whose values are the names of the keys
+func newTestUploadServerCertSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetUploadServerCertSecretNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("servercert"),
+			corev1.TLSPrivateKeyKey: []byte("serverkey"),
+			UploadClientCertKey:     []byte("clientcert"),
+			UploadClientKeyKey:      []byte("clientkey"),
+		},
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	goerrors "errors"
 	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	"kubevirt-image-service/pkg/util"
@@ -24,7 +29,8 @@ func Add(mgr manager.Manager) error {
 }
 
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileVirtualMachineImage{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileVirtualMachineImage{client: mgr.GetClient(), scheme: mgr.GetScheme(), recorder: mgr.GetEventRecorderFor("virtualmachineimage-controller"),
+		readPodLog: newPodLogReader(kubernetes.NewForConfigOrDie(mgr.GetConfig()))}
 }
 
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
@@ -39,7 +45,21 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.VirtualMachineImage{}}); err != nil {
 		return err
 	}
+	if err := c.Watch(&source.Kind{Type: &batchv1.Job{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.VirtualMachineImage{}}); err != nil {
+		return err
+	}
+	// The pods of the jobs are owned by the jobs, so they are mapped to the vmi by its label to sync the progress and the upload server
 	if err := c.Watch(&source.Kind{Type: &corev1.Pod{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(mapJobPodToVmi)}); err != nil {
+		return err
+	}
+	// The clone source job is in the namespace of the source pvc, so it is mapped to the vmi by its labels
+	if err := c.Watch(&source.Kind{Type: &batchv1.Job{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(mapCloneSourceJobToVmi)}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.Secret{}},
 		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.VirtualMachineImage{}}); err != nil {
 		return err
 	}
@@ -50,6 +70,26 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+func mapJobPodToVmi(o handler.MapObject) []reconcile.Request {
+	name, found := o.Meta.GetLabels()[VmiNameLabel]
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: name}},
+	}
+}
+
+func mapCloneSourceJobToVmi(o handler.MapObject) []reconcile.Request {
+	namespace, found := o.Meta.GetLabels()[CloneTargetNamespaceLabel]
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: namespace, Name: o.Meta.GetLabels()[CloneTargetNameLabel]}},
+	}
+}
+
 // blank assignment to verify that ReconcileVirtualMachineImage implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileVirtualMachineImage{}
 
@@ -59,7 +99,12 @@ type ReconcileVirtualMachineImage struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
-	vmi    *hc.VirtualMachineImage
+	// recorder records the events of the vmi, which kubectl describe shows
+	recorder record.EventRecorder
+	vmi      *hc.VirtualMachineImage
+	config   hc.KubevirtImageServiceConfigSpec
+	// readPodLog reads the progress from the log of the importer pod
+	readPodLog podLogReader
 }
 
 // Reconcile reads that state of the cluster for a VirtualMachineImage object and makes changes based on the state read
@@ -77,66 +122,234 @@ func (r *ReconcileVirtualMachineImage) Reconcile(request reconcile.Request) (rec
 		return reconcile.Result{}, err
 	}
 	r.vmi = cachedVmi.DeepCopy()
+	config, err := util.GetConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.config = config
+	r.setConfigDefaults()
+
+	if r.vmi.DeletionTimestamp != nil {
+		// The vmi being deleted is not synced, and it is kept until the volumes created from it are deleted
+		if blocked, err := r.syncDeletion(); err != nil {
+			return reconcile.Result{}, err
+		} else if blocked {
+			return reconcile.Result{RequeueAfter: DependentVolumesSyncInterval}, nil
+		}
+		return reconcile.Result{}, nil
+	}
 
 	syncAll := func() error {
+		// Protect the snapshot of the vmi from the deletion while the volumes created from it exist
+		if err := r.syncFinalizer(); err != nil {
+			return err
+		}
+		// If the retry is requested, clear the failures of the import
+		if err := r.syncRetry(); err != nil {
+			return err
+		}
 		if err := r.validateVirtualMachineImageSpec(); err != nil {
 			return err
 		}
+		// If the refresh policy is due, check the source image by the refresh job and import it into a new revision if it is changed
+		if err := r.syncRefresh(); err != nil {
+			return err
+		}
+		// If the source is updated, import it into a new revision. The image pvc of the old revision is deleted before the new revision is imported
+		if deleting, err := r.syncRevision(); err != nil || deleting {
+			return err
+		}
+		// If the storage request is not set, size the pvc from the source. The http and hostPath source image is probed by the probe job
+		if err := r.syncStorageRequest(); err != nil {
+			return err
+		}
+		// If the source is VirtualMachineVolume, snapshot the volume pvc until the image pvc is restored from it
+		if err := r.syncCapture(); err != nil {
+			return err
+		}
+		// If the pvc doesn't exist, check the storage class and the snapshot class can import and snapshot the image before creating it
+		if err := r.syncStorageValidation(); err != nil {
+			return err
+		}
 		// If the pvc doesn't exist, create a pvc and update vmim's status to creating
 		if err := r.syncPvc(); err != nil {
 			return err
 		}
-		// If the pvc import is not complete, create a importer pod
-		// If the pvc import is complete, delete the importer pod and update imported value to true
-		if err := r.syncImporterPod(); err != nil {
+		// If the source image is fetched before converting it, create the scratch pvc until the import is complete
+		if err := r.syncScratchPvc(); err != nil {
+			return err
+		}
+		// If the source is upload or pvc, create the upload server cert, and the upload token for upload, until the import is complete
+		if err := r.syncUpload(); err != nil {
+			return err
+		}
+		// If the source is pvc, clone the source pvc by the CSI driver or the clone source job
+		if err := r.syncClone(); err != nil {
+			return err
+		}
+		// If the checksum is set, verify the digest of the source image by the checksum job before importing it
+		if err := r.syncChecksum(); err != nil {
+			return err
+		}
+		// If the pvc import is not complete, create a importer job. If the importer job fails, update vmim's status to error
+		// If the pvc import is complete, delete the importer job and update imported value to true
+		if err := r.syncImporterJob(); err != nil {
+			return err
+		}
+		// If the importer pod is running, read the progress from its log and update vmim's progress
+		if err := r.syncProgress(); err != nil {
 			return err
 		}
 		// If the pvc import is complete, create a snapshot and update vmim's status to available
 		if err := r.syncSnapshot(); err != nil {
 			return err
 		}
+		// If the revisions are more than the revision history limit, delete the snapshots of the oldest revisions which no volume uses
+		if err := r.syncRevisionHistory(); err != nil {
+			return err
+		}
 		return nil
 	}
 	if err := syncAll(); err != nil {
-		// TODO: Setup Error reason
-		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateError, corev1.ConditionFalse, "SeeMessages", err.Error()); err2 != nil {
+		reason := hc.ReasonFailed
+		if vmiErr := (*vmiError)(nil); goerrors.As(err, &vmiErr) {
+			reason = vmiErr.reason
+		}
+		if err2 := r.setStageConditions(); err2 != nil {
+			return reconcile.Result{}, err2
+		}
+		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineImageStateError, corev1.ConditionFalse, reason, err.Error()); err2 != nil {
 			return reconcile.Result{}, err2
 		}
 		return reconcile.Result{}, err
 	}
+	// 각 단계의 조건을 이미지 pvc와 스냅샷에 맞춘다
+	if err := r.syncConditions(); err != nil {
+		return reconcile.Result{}, err
+	}
+	if src, _ := r.getSource(); src == SourceUpload && r.vmi.Status.State != hc.VirtualMachineImageStateAvailable {
+		// The upload token is short-lived, so reconcile again to refresh it until the upload is complete
+		return reconcile.Result{RequeueAfter: UploadTokenRefreshInterval}, nil
+	}
+	if r.isImporting() {
+		// The progress is written to the log of the importer pod without any event, so reconcile again to read it
+		return reconcile.Result{RequeueAfter: ProgressSyncInterval}, nil
+	}
+	if requeueAfter := r.getRefreshRequeueAfter(); requeueAfter > 0 {
+		// The source image is checked again at the next time of the refresh policy
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
 	return reconcile.Result{}, nil
 }
 
+// vmiError is an error with the reason of ReadyToUse condition
+type vmiError struct {
+	reason  string
+	message string
+}
+
+func (e *vmiError) Error() string {
+	return e.message
+}
+
 // updateStateWithReadyToUse updates readyToUse and State. Other Status fields are not affected. vmi must be DeepCopy to avoid polluting the cache.
 func (r *ReconcileVirtualMachineImage) updateStateWithReadyToUse(state hc.VirtualMachineImageState, readyToUseStatus corev1.ConditionStatus,
 	reason, message string) error {
-	r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionReadyToUse, readyToUseStatus, reason, message)
+	util.RecordStateEvent(r.recorder, r.vmi, r.vmi.Status.Conditions, readyToUseStatus, reason, message, state == hc.VirtualMachineImageStateError)
+	r.vmi.Status.Conditions = util.SetConditionByType(r.vmi.Status.Conditions, hc.ConditionReadyToUse, readyToUseStatus, reason, message, r.vmi.Generation)
+	r.vmi.Status.Conditions = util.SetStateConditions(r.vmi.Status.Conditions, state == hc.VirtualMachineImageStateCreating,
+		state == hc.VirtualMachineImageStateError, reason, message, r.vmi.Generation)
 	r.vmi.Status.State = state
+	r.vmi.Status.ObservedGeneration = r.vmi.Generation
 	return r.client.Status().Update(context.TODO(), r.vmi)
 }
 
+// setConfigDefaults sets the default storage class and snapshot class of the config to the in-memory spec of vmi if they are not set.
+// The spec is never written back, so syncFinalizer and syncRetry patch only the metadata of vmi to keep the defaults from being persisted
+func (r *ReconcileVirtualMachineImage) setConfigDefaults() {
+	if r.vmi.Spec.PVC.StorageClassName == nil && r.config.DefaultStorageClassName != "" {
+		storageClassName := r.config.DefaultStorageClassName
+		r.vmi.Spec.PVC.StorageClassName = &storageClassName
+	}
+	if r.vmi.Spec.SnapshotClassName == "" {
+		r.vmi.Spec.SnapshotClassName = r.config.DefaultSnapshotClassName
+	}
+}
+
 func (r *ReconcileVirtualMachineImage) validateVirtualMachineImageSpec() error {
-	if r.vmi.Spec.PVC.VolumeMode == nil || *r.vmi.Spec.PVC.VolumeMode != corev1.PersistentVolumeBlock {
-		return goerrors.New("VolumeMode in pvc is invalid. Only 'Block' can be used")
+	if volumeMode := util.GetVolumeMode(r.vmi.Spec.PVC.VolumeMode); volumeMode != corev1.PersistentVolumeBlock && volumeMode != corev1.PersistentVolumeFilesystem {
+		return goerrors.New("VolumeMode in pvc is invalid. Only 'Block' and 'Filesystem' can be used")
 	}
-	_, found := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
-	if !found {
-		return goerrors.New("storage request in pvc is missing")
+	if r.vmi.Spec.SnapshotClassName == "" {
+		return goerrors.New("snapshotClassName is missing. Set it or defaultSnapshotClassName of KubevirtImageServiceConfig")
 	}
-	if _, err := r.getSource(); err != nil {
+	src, err := r.getSource()
+	if err != nil {
+		return err
+	}
+	if _, found := r.vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage]; !found && !r.canSizeStorageRequest(src) {
+		return goerrors.New("storage request in pvc is missing. It can be omitted only for http source without httpOptions, hostPath, pvc and virtualMachineVolume sources")
+	}
+	if err := r.validateChecksum(); err != nil {
+		return err
+	}
+	if err := r.validateFormat(); err != nil {
+		return err
+	}
+	if err := r.validateHTTPOptions(); err != nil {
+		return err
+	}
+	if err := r.validateRefreshPolicy(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ValidateVirtualMachineImageSpec validates the spec of vmi with the defaults of the config, as the controller does before importing it.
+// It is used by the validating webhook to reject the invalid vmi before it is created
+func ValidateVirtualMachineImageSpec(vmi *hc.VirtualMachineImage, config hc.KubevirtImageServiceConfigSpec) error {
+	r := &ReconcileVirtualMachineImage{vmi: vmi.DeepCopy(), config: config}
+	r.setConfigDefaults()
+	return r.validateVirtualMachineImageSpec()
+}
+
+// ValidateVirtualMachineImageSpecUpdate returns error if the immutable fields of the spec are changed.
+// The pvc is created only once, but the source can be updated to import a new revision
+func ValidateVirtualMachineImageSpecUpdate(oldSpec, newSpec *hc.VirtualMachineImageSpec) error {
+	if !equality.Semantic.DeepEqual(oldSpec.PVC, newSpec.PVC) {
+		return goerrors.New("pvc is immutable")
+	}
+	return nil
+}
+
 func (r *ReconcileVirtualMachineImage) getSource() (string, error) {
-	if r.vmi.Spec.Source.HTTP != "" && r.vmi.Spec.Source.HostPath != nil {
-		return "", goerrors.New("only one source is possible")
-	} else if r.vmi.Spec.Source.HTTP != "" {
-		return SourceHTTP, nil
-	} else if r.vmi.Spec.Source.HostPath != nil {
-		return SourceHostPath, nil
-	} else {
+	var sources []string
+	if r.vmi.Spec.Source.HTTP != "" {
+		sources = append(sources, SourceHTTP)
+	}
+	if r.vmi.Spec.Source.HostPath != nil {
+		sources = append(sources, SourceHostPath)
+	}
+	if r.vmi.Spec.Source.S3 != nil {
+		sources = append(sources, SourceS3)
+	}
+	if r.vmi.Spec.Source.Registry != nil {
+		sources = append(sources, SourceRegistry)
+	}
+	if r.vmi.Spec.Source.Upload != nil {
+		sources = append(sources, SourceUpload)
+	}
+	if r.vmi.Spec.Source.PVC != nil {
+		sources = append(sources, SourcePVC)
+	}
+	if r.vmi.Spec.Source.VirtualMachineVolume != nil {
+		sources = append(sources, SourceVolume)
+	}
+
+	if len(sources) == 0 {
 		return "", goerrors.New("vmim source is not set")
+	} else if len(sources) > 1 {
+		return "", goerrors.New("only one source is possible")
 	}
+	return sources[0], nil
 }
@@ -0,0 +1,36 @@
+package virtualmachinevolume
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"kubevirt-image-service/pkg/util"
+)
+
+// syncConditions updates PvcBound condition by the volume pvc, and records the generation of the volume which the status is based on
+func (r *ReconcileVirtualMachineVolume) syncConditions() error {
+	oldStatus := r.volume.Status.DeepCopy()
+	if err := r.setStageConditions(); err != nil {
+		return err
+	}
+	r.volume.Status.ObservedGeneration = r.volume.Generation
+	if equality.Semantic.DeepEqual(oldStatus, &r.volume.Status) {
+		return nil
+	}
+	return r.client.Status().Update(context.TODO(), r.volume)
+}
+
+// setStageConditions sets PvcBound condition of the volume. The status is not updated
+func (r *ReconcileVirtualMachineVolume) setStageConditions() error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name), Namespace: r.volume.Namespace}, pvc); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		pvc = nil
+	}
+	r.volume.Status.Conditions = util.SetPvcBoundCondition(r.volume.Status.Conditions, pvc, r.volume.Generation)
+	return nil
+}
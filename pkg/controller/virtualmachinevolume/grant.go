@@ -0,0 +1,32 @@
+package virtualmachinevolume
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// isImageGranted returns true if a VirtualMachineImageGrant in the namespace of the image grants it to the namespace of the volume
+func (r *ReconcileVirtualMachineVolume) isImageGranted(image types.NamespacedName) (bool, error) {
+	grants := &hc.VirtualMachineImageGrantList{}
+	if err := r.client.List(context.TODO(), grants, client.InNamespace(image.Namespace)); err != nil {
+		return false, err
+	}
+	for _, grant := range grants.Items {
+		if grant.Spec.VirtualMachineImageName == image.Name && isNamespaceGranted(&grant, r.volume.Namespace) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isNamespaceGranted returns true if the grant contains namespace or "*"
+func isNamespaceGranted(grant *hc.VirtualMachineImageGrant, namespace string) bool {
+	for _, ns := range grant.Spec.Namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
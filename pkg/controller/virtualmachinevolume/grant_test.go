@@ -0,0 +1,93 @@
+package virtualmachinevolume
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+// #    grant image    grant namespaces    granted
+// 1	X
+// 2	myvmi		   mynamespace		   O
+// 3	myvmi		   *				   O
+// 4	myvmi		   othernamespace	   X
+// 5	othervmi	   mynamespace		   X
+
+var _ = Describe("isImageGranted", func() {
+	image := types.NamespacedName{Namespace: testImageNamespace, Name: testImageName}
+
+	Context("1. with no grant", func() {
+		r := createFakeReconcileVmv()
+		granted, err := r.isImageGranted(image)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be granted", func() {
+			Expect(granted).Should(BeFalse())
+		})
+	})
+
+	Context("2. with grant to the namespace of the volume", func() {
+		r := createFakeReconcileVmv(newTestGrant(testImageName, testNameSpace))
+		granted, err := r.isImageGranted(image)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be granted", func() {
+			Expect(granted).Should(BeTrue())
+		})
+	})
+
+	Context("3. with grant to all namespaces", func() {
+		r := createFakeReconcileVmv(newTestGrant(testImageName, "*"))
+		granted, err := r.isImageGranted(image)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be granted", func() {
+			Expect(granted).Should(BeTrue())
+		})
+	})
+
+	Context("4. with grant to another namespace", func() {
+		r := createFakeReconcileVmv(newTestGrant(testImageName, "othernamespace"))
+		granted, err := r.isImageGranted(image)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be granted", func() {
+			Expect(granted).Should(BeFalse())
+		})
+	})
+
+	Context("5. with grant of another image", func() {
+		r := createFakeReconcileVmv(newTestGrant("othervmi", testNameSpace))
+		granted, err := r.isImageGranted(image)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be granted", func() {
+			Expect(granted).Should(BeFalse())
+		})
+	})
+})
+
+func newTestGrant(imageName string, namespaces ...string) *hc.VirtualMachineImageGrant {
+	return &hc.VirtualMachineImageGrant{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      imageName + "-grant",
+			Namespace: testImageNamespace,
+		},
+		Spec: hc.VirtualMachineImageGrantSpec{
+			VirtualMachineImageName: imageName,
+			Namespaces:              namespaces,
+		},
+	}
+}
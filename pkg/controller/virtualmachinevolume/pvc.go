@@ -10,6 +10,7 @@ import (
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -24,7 +25,11 @@ func (r *ReconcileVirtualMachineVolume) syncVolumePvc() error {
 
 	if pvcExists {
 		if pvc.Status.Phase == corev1.ClaimBound {
-			if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateAvailable, corev1.ConditionTrue, "SuccessfulCreate", "VirtualMachineVolume is available"); err != nil {
+			// pvc가 복원됐으니 다른 네임스페이스에서 복사한 스냅샷을 삭제한다
+			if err := r.deleteRestoreSnapshot(); err != nil {
+				return err
+			}
+			if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateAvailable, corev1.ConditionTrue, hc.ReasonReady, "VirtualMachineVolume is available"); err != nil {
 				return err
 			}
 		} else if pvc.Status.Phase == corev1.ClaimLost {
@@ -41,25 +46,48 @@ func (r *ReconcileVirtualMachineVolume) syncVolumePvc() error {
 
 // createVolumePvc creates pvc from volumeSnapShot created by virtualMachineImage
 func (r *ReconcileVirtualMachineVolume) createVolumePvc() (*corev1.PersistentVolumeClaim, error) {
-	image := &hc.VirtualMachineImage{}
-	if err := r.client.Get(context.Background(), types.NamespacedName{Name: r.volume.Spec.VirtualMachineImage.Name, Namespace: r.volume.Namespace}, image); err != nil {
+	image, err := r.getImage()
+	if err != nil {
 		return nil, err
 	}
+	if image.DeletionTimestamp != nil {
+		return nil, goerrors.New("VirtualMachineImage is being deleted")
+	}
+
+	revision, found := img.GetAvailableRevision(image, r.volume.Spec.VirtualMachineImage.Revision)
+	if !found {
+		return nil, goerrors.New("revision of VirtualMachineImage is not available")
+	}
 
 	// Validate Capacity
-	imagePvcSize := image.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+	imagePvcSize, _ := img.GetStorageRequest(image)
+	if revision.StorageRequest != nil {
+		imagePvcSize = *revision.StorageRequest
+	}
 	volumePvcSize := r.volume.Spec.Capacity[corev1.ResourceStorage]
 	if volumePvcSize.Value() < imagePvcSize.Value() {
 		klog.Infof("VirtualMachineVolume size(%d) should be greater than or equal to VirtualMachineImage size(%d)", volumePvcSize.Value(), imagePvcSize.Value())
 		return nil, goerrors.New("VirtualMachineVolume size should be greater than or equal to VirtualMachineImage size")
 	}
 
-	klog.Infof("Create a new pvc for volume %s", r.volume.Name)
-	if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateCreating, corev1.ConditionFalse, "CreatingPVC", "VirtualMachineVolume is creating PVC"); err != nil {
+	// pvc는 같은 네임스페이스의 스냅샷으로만 복원할 수 있으므로 다른 네임스페이스의 이미지 스냅샷은 복사해서 쓴다
+	snapshotName := revision.SnapshotName
+	if image.Namespace != r.volume.Namespace {
+		if ready, err := r.syncRestoreSnapshot(image, revision.SnapshotName); err != nil || !ready {
+			return nil, err
+		}
+		snapshotName = GetRestoreSnapshotName(r.volume.Name)
+	}
+
+	klog.Infof("Create a new pvc for volume %s from revision %d of the image", r.volume.Name, revision.Revision)
+	r.volume.Status.ImageRevision = revision.Revision
+	if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateCreating, corev1.ConditionFalse, hc.ReasonCreating, "VirtualMachineVolume is creating PVC"); err != nil {
 		return nil, err
 	}
 
 	apiGroup := "snapshot.storage.k8s.io"
+	// 스냅샷으로부터 복원하는 pvc는 이미지 pvc와 volumeMode가 같아야 함
+	volumeMode := util.GetVolumeMode(image.Spec.PVC.VolumeMode)
 	pvc := &corev1.PersistentVolumeClaim{
 		TypeMeta: v1.TypeMeta{
 			Kind:       "PersistentVolumeClaim",
@@ -70,13 +98,13 @@ func (r *ReconcileVirtualMachineVolume) createVolumePvc() (*corev1.PersistentVol
 			Namespace: r.volume.Namespace,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			StorageClassName: image.Spec.PVC.StorageClassName,
+			StorageClassName: r.getStorageClassName(image),
 			AccessModes:      image.Spec.PVC.AccessModes,
-			VolumeMode:       image.Spec.PVC.VolumeMode,
+			VolumeMode:       &volumeMode,
 			DataSource: &corev1.TypedLocalObjectReference{
 				APIGroup: &apiGroup,
 				Kind:     "VolumeSnapshot",
-				Name:     img.GetSnapshotNameFromVmiName(image.Name),
+				Name:     snapshotName,
 			},
 			Resources: corev1.ResourceRequirements{
 				Requests: r.volume.Spec.Capacity,
@@ -89,10 +117,20 @@ func (r *ReconcileVirtualMachineVolume) createVolumePvc() (*corev1.PersistentVol
 	if err := r.client.Create(context.Background(), pvc); err != nil {
 		return nil, err
 	}
+	r.recorder.Eventf(r.volume, corev1.EventTypeNormal, util.EventPvcCreated, "Created volume pvc %s from snapshot %s of revision %d",
+		pvc.Name, snapshotName, revision.Revision)
 
 	return pvc, nil
 }
 
+// getStorageClassName returns the storage class of the image, or the default storage class of the config if it is not set
+func (r *ReconcileVirtualMachineVolume) getStorageClassName(image *hc.VirtualMachineImage) *string {
+	if image.Spec.PVC.StorageClassName == nil && r.config.DefaultStorageClassName != "" {
+		return &r.config.DefaultStorageClassName
+	}
+	return image.Spec.PVC.StorageClassName
+}
+
 // GetVolumePvcName gets the name of the pvc created by virtualMachineVolume
 func GetVolumePvcName(volumeName string) string {
 	return volumeName + "-vmv-pvc"
@@ -5,6 +5,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	"kubevirt-image-service/pkg/util"
@@ -15,6 +17,7 @@ import (
 // 2	O	   bound		true       		    available
 // 3	O	   lost
 // 4	O	   pending
+// 5	X									   (image is being deleted)
 
 var _ = Describe("syncVolumePvc", func() {
 	Context("1. with no pvc", func() {
@@ -30,6 +33,13 @@ var _ = Describe("syncVolumePvc", func() {
 				Namespace: r.volume.Namespace}, pvc)
 			Expect(err).Should(BeNil())
 		})
+		It("Should create pvc with Filesystem volume mode if the image has no volume mode", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(*pvc.Spec.VolumeMode).Should(Equal(corev1.PersistentVolumeFilesystem))
+		})
 	})
 
 	Context("2. with bound pvc", func() {
@@ -78,4 +88,23 @@ var _ = Describe("syncVolumePvc", func() {
 			Expect(err).Should(BeNil())
 		})
 	})
+
+	Context("5. with no pvc, deleting image", func() {
+		image := newTestImage()
+		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", image.Generation)
+		now := v1.Now()
+		image.DeletionTimestamp = &now
+		r := createFakeReconcileVmv(image)
+		err := r.syncVolumePvc()
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should not create pvc", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
 })
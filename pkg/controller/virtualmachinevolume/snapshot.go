@@ -0,0 +1,148 @@
+package virtualmachinevolume
+
+import (
+	"context"
+	goerrors "errors"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// syncRestoreSnapshot copies the snapshot of the image in another namespace into the namespace of the volume, because the pvc
+// can be restored only from the snapshot in the same namespace. The copied VolumeSnapshotContent refers to the same snapshot handle
+// with Retain policy, so deleting the copy doesn't delete the snapshot of the image. It returns true if the copied snapshot is ready to use
+func (r *ReconcileVirtualMachineVolume) syncRestoreSnapshot(image *hc.VirtualMachineImage, snapshotName string) (bool, error) {
+	snapshot := &snapshotv1beta1.VolumeSnapshot{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.volume.Namespace, Name: GetRestoreSnapshotName(r.volume.Name)}, snapshot)
+	if err == nil {
+		if snapshot.Status != nil && snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+			return false, goerrors.New("Restore snapshot is error: " + *snapshot.Status.Error.Message)
+		}
+		ready := snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+		// 스냅샷이 준비됐다는 이벤트는 스냅샷을 복사하는 중에서 바뀔 때 한 번만 남긴다
+		if ready && r.isCopyingSnapshot() {
+			r.recorder.Eventf(r.volume, corev1.EventTypeNormal, util.EventSnapshotReady, "Snapshot %s is ready to use", snapshot.Name)
+		}
+		return ready, nil
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	// 이미지 스냅샷의 컨텐트로부터 스냅샷 핸들을 구한다
+	imageSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: image.Namespace, Name: snapshotName}, imageSnapshot); err != nil {
+		return false, err
+	}
+	if imageSnapshot.Status == nil || imageSnapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return false, goerrors.New("snapshot of VirtualMachineImage " + image.Namespace + "/" + image.Name + " is not bound to the content")
+	}
+	imageContent := &snapshotv1beta1.VolumeSnapshotContent{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: *imageSnapshot.Status.BoundVolumeSnapshotContentName}, imageContent); err != nil {
+		return false, err
+	}
+	if imageContent.Status == nil || imageContent.Status.SnapshotHandle == nil {
+		return false, goerrors.New("snapshot content " + imageContent.Name + " has no snapshot handle")
+	}
+
+	klog.Infof("Copy the snapshot of image %s/%s for volume %s", image.Namespace, image.Name, r.volume.Name)
+	if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateCreating, corev1.ConditionFalse, ReasonCopyingSnapshot, "VirtualMachineVolume is copying the snapshot of the image"); err != nil {
+		return false, err
+	}
+	if err := r.client.Create(context.TODO(), newRestoreSnapshotContent(r.volume, imageContent)); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+	newSnapshot := newRestoreSnapshot(r.volume)
+	if err := controllerutil.SetControllerReference(r.volume, newSnapshot, r.scheme); err != nil {
+		return false, err
+	}
+	if err := r.client.Create(context.TODO(), newSnapshot); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+	r.recorder.Eventf(r.volume, corev1.EventTypeNormal, util.EventSnapshotCreated, "Created snapshot %s copied from snapshot %s/%s of the image",
+		newSnapshot.Name, image.Namespace, snapshotName)
+	return false, nil
+}
+
+// isCopyingSnapshot returns true if ReadyToUse condition of the volume is still copying the snapshot of the image
+func (r *ReconcileVirtualMachineVolume) isCopyingSnapshot() bool {
+	found, cond := util.GetConditionByType(r.volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse)
+	return found && cond.Reason == ReasonCopyingSnapshot
+}
+
+// deleteRestoreSnapshot deletes the snapshot copied from another namespace and its content, which are not needed after the pvc is restored
+func (r *ReconcileVirtualMachineVolume) deleteRestoreSnapshot() error {
+	snapshot := &snapshotv1beta1.VolumeSnapshot{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.volume.Namespace, Name: GetRestoreSnapshotName(r.volume.Name)}, snapshot); err == nil {
+		if err := r.client.Delete(context.TODO(), snapshot); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(r.volume, corev1.EventTypeNormal, util.EventSnapshotDeleted, "Deleted snapshot %s, the volume pvc is restored", snapshot.Name)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	content := &snapshotv1beta1.VolumeSnapshotContent{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotContentName(r.volume)}, content); err == nil {
+		if err := r.client.Delete(context.TODO(), content); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// GetRestoreSnapshotName returns the name of the snapshot copied from the image in another namespace for volumeName
+func GetRestoreSnapshotName(volumeName string) string {
+	return volumeName + "-vmv-restore-snapshot"
+}
+
+// GetRestoreSnapshotContentName returns the name of the snapshot content copied from the image in another namespace for the volume.
+// The snapshot content is cluster-scoped, so the name has the namespace of the volume
+func GetRestoreSnapshotContentName(volume *hc.VirtualMachineVolume) string {
+	return volume.Namespace + "-" + volume.Name + "-vmv-restore-snapshotcontent"
+}
+
+// newRestoreSnapshotContent returns the pre-provisioned snapshot content of the snapshot handle of imageContent,
+// which is bound to the restore snapshot of the volume
+func newRestoreSnapshotContent(volume *hc.VirtualMachineVolume, imageContent *snapshotv1beta1.VolumeSnapshotContent) *snapshotv1beta1.VolumeSnapshotContent {
+	snapshotHandle := *imageContent.Status.SnapshotHandle
+	return &snapshotv1beta1.VolumeSnapshotContent{
+		ObjectMeta: v1.ObjectMeta{
+			Name: GetRestoreSnapshotContentName(volume),
+		},
+		Spec: snapshotv1beta1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: corev1.ObjectReference{
+				Namespace: volume.Namespace,
+				Name:      GetRestoreSnapshotName(volume.Name),
+			},
+			DeletionPolicy:          snapshotv1beta1.VolumeSnapshotContentRetain,
+			Driver:                  imageContent.Spec.Driver,
+			VolumeSnapshotClassName: imageContent.Spec.VolumeSnapshotClassName,
+			Source: snapshotv1beta1.VolumeSnapshotContentSource{
+				SnapshotHandle: &snapshotHandle,
+			},
+		},
+	}
+}
+
+// newRestoreSnapshot returns the snapshot of the volume which is bound to the pre-provisioned restore snapshot content
+func newRestoreSnapshot(volume *hc.VirtualMachineVolume) *snapshotv1beta1.VolumeSnapshot {
+	contentName := GetRestoreSnapshotContentName(volume)
+	return &snapshotv1beta1.VolumeSnapshot{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      GetRestoreSnapshotName(volume.Name),
+			Namespace: volume.Namespace,
+		},
+		Spec: snapshotv1beta1.VolumeSnapshotSpec{
+			Source: snapshotv1beta1.VolumeSnapshotSource{
+				VolumeSnapshotContentName: &contentName,
+			},
+		},
+	}
+}
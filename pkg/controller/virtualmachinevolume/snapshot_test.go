@@ -0,0 +1,184 @@
+package virtualmachinevolume
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"kubevirt-image-service/pkg/util"
+)
+
+// #    image snapshot    restore snapshot    result
+// 1	bound			  X					  create snapshot and content
+// 2	not bound		  X					  error
+// 3	bound			  not ready			  not ready
+// 4	bound			  ready				  ready, event (copying snapshot)
+// 5	bound			  ready				  ready, no event (not copying snapshot)
+
+var _ = Describe("syncRestoreSnapshot", func() {
+	Context("1. with bound image snapshot, no restore snapshot", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileVmv(image, snapshot, content)
+		ready, err := r.syncRestoreSnapshot(image, img.GetImageSnapshotName(image))
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be ready", func() {
+			Expect(ready).Should(BeFalse())
+		})
+		It("Should create the snapshot content with the snapshot handle of the image", func() {
+			restoreContent := &snapshotv1beta1.VolumeSnapshotContent{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotContentName(r.volume)}, restoreContent)
+			Expect(err).Should(BeNil())
+			Expect(*restoreContent.Spec.Source.SnapshotHandle).Should(Equal(*content.Status.SnapshotHandle))
+			Expect(restoreContent.Spec.DeletionPolicy).Should(Equal(snapshotv1beta1.VolumeSnapshotContentRetain))
+			Expect(restoreContent.Spec.VolumeSnapshotRef.Namespace).Should(Equal(r.volume.Namespace))
+		})
+		It("Should create the restore snapshot in the namespace of the volume", func() {
+			restoreSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotName(r.volume.Name), Namespace: r.volume.Namespace}, restoreSnapshot)
+			Expect(err).Should(BeNil())
+			Expect(*restoreSnapshot.Spec.Source.VolumeSnapshotContentName).Should(Equal(GetRestoreSnapshotContentName(r.volume)))
+			Expect(v1.IsControlledBy(restoreSnapshot, r.volume)).Should(BeTrue())
+		})
+		It("Should update state to creating", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err := r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStateCreating))
+		})
+	})
+
+	Context("2. with not bound image snapshot", func() {
+		image, snapshot, _ := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		snapshot.Status = nil
+		r := createFakeReconcileVmv(image, snapshot)
+		_, err := r.syncRestoreSnapshot(image, img.GetImageSnapshotName(image))
+
+		It("Should return error", func() {
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("3. with not ready restore snapshot", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileVmv(image, snapshot, content, newTestRestoreSnapshot(false))
+		ready, err := r.syncRestoreSnapshot(image, img.GetImageSnapshotName(image))
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not be ready", func() {
+			Expect(ready).Should(BeFalse())
+		})
+	})
+
+	Context("4. with ready restore snapshot, copying snapshot", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileVmv(image, snapshot, content, newTestRestoreSnapshot(true))
+		r.volume.Status.Conditions = util.SetConditionByType(r.volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse, corev1.ConditionFalse,
+			ReasonCopyingSnapshot, "VirtualMachineVolume is copying the snapshot of the image", r.volume.Generation)
+		ready, err := r.syncRestoreSnapshot(image, img.GetImageSnapshotName(image))
+		events := getTestEvents(r)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be ready", func() {
+			Expect(ready).Should(BeTrue())
+		})
+		It("Should record the event of the ready snapshot", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventSnapshotReady)))
+		})
+	})
+
+	Context("5. with ready restore snapshot, not copying snapshot", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileVmv(image, snapshot, content, newTestRestoreSnapshot(true))
+		r.volume.Status.Conditions = util.SetConditionByType(r.volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse, corev1.ConditionFalse,
+			hc.ReasonFailed, "failed to create pvc", r.volume.Generation)
+		ready, err := r.syncRestoreSnapshot(image, img.GetImageSnapshotName(image))
+		events := getTestEvents(r)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should be ready", func() {
+			Expect(ready).Should(BeTrue())
+		})
+		It("Should not record the event of the ready snapshot again", func() {
+			Expect(events).ShouldNot(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventSnapshotReady)))
+		})
+	})
+})
+
+var _ = Describe("deleteRestoreSnapshot", func() {
+	r := createFakeReconcileVmv(newTestRestoreSnapshot(true), newRestoreSnapshotContent(newTestVolume(), newTestImageSnapshotContent()))
+	err := r.deleteRestoreSnapshot()
+
+	It("Should not return error", func() {
+		Expect(err).Should(BeNil())
+	})
+	It("Should delete the restore snapshot and its content", func() {
+		restoreSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotName(r.volume.Name), Namespace: r.volume.Namespace}, restoreSnapshot)
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+		restoreContent := &snapshotv1beta1.VolumeSnapshotContent{}
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotContentName(r.volume)}, restoreContent)
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+	It("Should not return error if they don't exist", func() {
+		Expect(r.deleteRestoreSnapshot()).Should(BeNil())
+	})
+})
+
+// newTestImageWithSnapshot returns the ready image in namespace with its bound snapshot and content
+func newTestImageWithSnapshot(namespace string) (*hc.VirtualMachineImage, *snapshotv1beta1.VolumeSnapshot, *snapshotv1beta1.VolumeSnapshotContent) {
+	image := newTestImage()
+	image.Namespace = namespace
+	image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", image.Generation)
+	content := newTestImageSnapshotContent()
+	snapshot := &snapshotv1beta1.VolumeSnapshot{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      img.GetSnapshotNameFromVmiName(image.Name),
+			Namespace: image.Namespace,
+		},
+		Status: &snapshotv1beta1.VolumeSnapshotStatus{
+			BoundVolumeSnapshotContentName: &content.Name,
+		},
+	}
+	return image, snapshot, content
+}
+
+func newTestImageSnapshotContent() *snapshotv1beta1.VolumeSnapshotContent {
+	snapshotHandle := "0001-0009-rook-ceph-0000000000000001-snapshot"
+	snapshotClassName := "mysnapshotclass"
+	return &snapshotv1beta1.VolumeSnapshotContent{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "snapcontent-myvmi",
+		},
+		Spec: snapshotv1beta1.VolumeSnapshotContentSpec{
+			Driver:                  "rook-ceph.rbd.csi.ceph.com",
+			VolumeSnapshotClassName: &snapshotClassName,
+			DeletionPolicy:          snapshotv1beta1.VolumeSnapshotContentDelete,
+		},
+		Status: &snapshotv1beta1.VolumeSnapshotContentStatus{
+			SnapshotHandle: &snapshotHandle,
+		},
+	}
+}
+
+func newTestRestoreSnapshot(readyToUse bool) *snapshotv1beta1.VolumeSnapshot {
+	snapshot := newRestoreSnapshot(newTestVolume())
+	snapshot.Status = &snapshotv1beta1.VolumeSnapshotStatus{
+		ReadyToUse: &readyToUse,
+	}
+	return snapshot
+}
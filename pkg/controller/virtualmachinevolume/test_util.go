@@ -6,6 +6,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
 	"kubevirt-image-service/pkg/util"
@@ -15,6 +16,9 @@ const (
 	testVolumeName = "myvmv"
 	testImageName  = "myvmi"
 	testNameSpace  = "mynamespace"
+	// testImageNamespace is the namespace of the image shared with testNameSpace
+	testImageNamespace  = "imagenamespace"
+	testEventBufferSize = 100
 )
 
 var (
@@ -28,18 +32,52 @@ func createFakeReconcileVmv(objects ...runtime.Object) *ReconcileVirtualMachineV
 	if err != nil {
 		panic(err)
 	}
-	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, volume: v}
+	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), volume: v}
 }
 
 func createFakeReconcileVolumeWithImage(objects ...runtime.Object) *ReconcileVirtualMachineVolume {
 	v := newTestVolume()
 	i := newTestImage()
-	i.Status.Conditions = util.SetConditionByType(i.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, "VmiIsReady", "Vmi is ready to use")
+	i.Status.Conditions = util.SetConditionByType(i.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", i.Generation)
 	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, v, i)...)
 	if err != nil {
 		panic(err)
 	}
-	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, volume: v}
+	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), volume: v}
+}
+
+func createFakeReconcileClusterImageVolume(objects ...runtime.Object) *ReconcileVirtualMachineVolume {
+	v := newTestVolume()
+	v.Spec.VirtualMachineImage.Kind = hc.ClusterVirtualMachineImageKind
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, v)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), volume: v}
+}
+
+func createFakeReconcileSharedImageVolume(objects ...runtime.Object) *ReconcileVirtualMachineVolume {
+	v := newTestVolume()
+	v.Spec.VirtualMachineImage.Namespace = testImageNamespace
+	client, scheme, err := util.CreateFakeClientAndScheme(append(objects, v)...)
+	if err != nil {
+		panic(err)
+	}
+	return &ReconcileVirtualMachineVolume{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), volume: v}
+}
+
+// getTestEvents returns the events which the fake recorder of r has recorded since the last call
+func getTestEvents(r *ReconcileVirtualMachineVolume) []string {
+	recorder := r.recorder.(*record.FakeRecorder)
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
 }
 
 func newTestVolume() *hc.VirtualMachineVolume {
@@ -111,3 +149,17 @@ func newTestImage() *hc.VirtualMachineImage {
 		},
 	}
 }
+
+// newTestImageWithRevisions returns the image which is importing the revision after the available revisions
+func newTestImageWithRevisions(revisions ...int32) *hc.VirtualMachineImage {
+	image := newTestImage()
+	for _, revision := range revisions {
+		image.Status.Revisions = append(image.Status.Revisions, hc.VirtualMachineImageRevision{
+			Revision:     revision,
+			SnapshotName: img.GetRevisionSnapshotName(image.Name, revision),
+		})
+	}
+	image.Status.Revision = revisions[len(revisions)-1] + 1
+	image.Status.State = hc.VirtualMachineImageStateCreating
+	return image
+}
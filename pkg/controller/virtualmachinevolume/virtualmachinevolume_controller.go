@@ -3,12 +3,18 @@ package virtualmachinevolume
 import (
 	"context"
 	goerrors "errors"
+	"fmt"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	cvmi "kubevirt-image-service/pkg/controller/clustervirtualmachineimage"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
 	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -22,6 +28,9 @@ import (
 // ReconcileInterval is an time to reconcile again when in Pending State
 const ReconcileInterval = 1 * time.Second
 
+// ReasonCopyingSnapshot is the reason of ReadyToUse condition while the snapshot of the image in another namespace is copied into the volume namespace
+const ReasonCopyingSnapshot = "CopyingSnapshot"
+
 // Add creates a new VirtualMachineVolume Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
@@ -30,7 +39,7 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileVirtualMachineVolume{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileVirtualMachineVolume{client: mgr.GetClient(), scheme: mgr.GetScheme(), recorder: mgr.GetEventRecorderFor("virtualmachinevolume-controller")}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -46,6 +55,10 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.VirtualMachineVolume{}}); err != nil {
 		return err
 	}
+	if err := c.Watch(&source.Kind{Type: &snapshotv1beta1.VolumeSnapshot{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &hc.VirtualMachineVolume{}}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -58,7 +71,10 @@ type ReconcileVirtualMachineVolume struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
-	volume *hc.VirtualMachineVolume
+	// recorder records the events of the volume, which kubectl describe shows
+	recorder record.EventRecorder
+	volume   *hc.VirtualMachineVolume
+	config   hc.KubevirtImageServiceConfigSpec
 }
 
 // Reconcile reads that state of the cluster for a VirtualMachineVolume object and makes changes based on the state read
@@ -77,36 +93,63 @@ func (r *ReconcileVirtualMachineVolume) Reconcile(request reconcile.Request) (re
 		return reconcile.Result{}, err
 	}
 	r.volume = cachedVolume.DeepCopy()
+	config, err := util.GetConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.config = config
 
 	if err := r.validateVolumeSpec(); err != nil {
-		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStatePending, corev1.ConditionFalse, "VmVolumeIsInPending", err.Error()); err2 != nil {
+		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStatePending, corev1.ConditionFalse, hc.ReasonPending, err.Error()); err2 != nil {
 			return reconcile.Result{}, err2
 		}
 		return reconcile.Result{RequeueAfter: ReconcileInterval}, nil
 	}
 
 	if err := r.syncVolumePvc(); err != nil {
-		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateError, corev1.ConditionFalse, "VmVolumeIsInError", err.Error()); err2 != nil {
+		if err2 := r.setStageConditions(); err2 != nil {
+			return reconcile.Result{}, err2
+		}
+		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateError, corev1.ConditionFalse, hc.ReasonFailed, err.Error()); err2 != nil {
 			return reconcile.Result{}, err2
 		}
 		return reconcile.Result{}, err
 	}
+	// 각 단계의 조건을 볼륨 pvc에 맞춘다
+	if err := r.syncConditions(); err != nil {
+		return reconcile.Result{}, err
+	}
 	return reconcile.Result{}, nil
 }
 
 func (r *ReconcileVirtualMachineVolume) validateVolumeSpec() error {
+	if err := ValidateVolumeSpec(r.volume); err != nil {
+		return err
+	}
+
+	// The volume pvc already restored doesn't depend on the image, even if it is refreshing into a new revision
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name), Namespace: r.volume.Namespace}, pvc); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
 	// Validate VirtualMachineImageName
-	image := &hc.VirtualMachineImage{}
-	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: r.volume.Spec.VirtualMachineImage.Name, Namespace: r.volume.Namespace}, image); err != nil {
+	image, err := r.getImage()
+	if err != nil {
 		if errors.IsNotFound(err) {
-			return goerrors.New("VirtualMachineImage is not exists")
+			return goerrors.New(r.getImageKind() + " is not exists")
 		}
 		return err
 	}
 
-	// Check virtualMachineImage state is available
-	found, cond := util.GetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse)
-	if !found || cond.Status != corev1.ConditionTrue {
+	// Check the pinned revision or any revision of virtualMachineImage is available
+	if revision := r.volume.Spec.VirtualMachineImage.Revision; revision != nil {
+		if _, found := img.GetAvailableRevision(image, revision); !found {
+			return fmt.Errorf("revision %d of VirtualMachineImage is not available", *revision)
+		}
+	} else if _, found := img.GetAvailableRevision(image, nil); !found {
 		klog.Info("VirtualMachineImage state is not available")
 		return goerrors.New("VirtualMachineImage state is not available")
 	}
@@ -114,10 +157,72 @@ func (r *ReconcileVirtualMachineVolume) validateVolumeSpec() error {
 	return nil
 }
 
+// ValidateVolumeSpec validates the spec of the volume which doesn't depend on the image.
+// It is used by the validating webhook to reject the invalid volume before it is created
+func ValidateVolumeSpec(volume *hc.VirtualMachineVolume) error {
+	if volume.Spec.VirtualMachineImage.Kind == hc.ClusterVirtualMachineImageKind && volume.Spec.VirtualMachineImage.Namespace != "" {
+		return goerrors.New("namespace can't be set for ClusterVirtualMachineImage")
+	}
+	if _, found := volume.Spec.Capacity[corev1.ResourceStorage]; !found {
+		return goerrors.New("storage in capacity is missing")
+	}
+	return nil
+}
+
+// ValidateVolumeSpecUpdate returns error if the spec is changed. The volume pvc is restored from the image only once
+func ValidateVolumeSpecUpdate(oldSpec, newSpec *hc.VirtualMachineVolumeSpec) error {
+	if !equality.Semantic.DeepEqual(oldSpec, newSpec) {
+		return goerrors.New("spec of VirtualMachineVolume is immutable")
+	}
+	return nil
+}
+
+// getImageKind returns the kind of the image of the volume
+func (r *ReconcileVirtualMachineVolume) getImageKind() string {
+	if r.volume.Spec.VirtualMachineImage.Kind == "" {
+		return hc.VirtualMachineImageKind
+	}
+	return r.volume.Spec.VirtualMachineImage.Kind
+}
+
+// getImageNamespace returns the namespace of the VirtualMachineImage of the volume
+func (r *ReconcileVirtualMachineVolume) getImageNamespace() string {
+	if r.volume.Spec.VirtualMachineImage.Namespace == "" {
+		return r.volume.Namespace
+	}
+	return r.volume.Spec.VirtualMachineImage.Namespace
+}
+
+// getImage returns the VirtualMachineImage of the volume. The ClusterVirtualMachineImage is imported into the VirtualMachineImage
+// in the cluster image namespace, and the VirtualMachineImage in another namespace must be granted to the namespace of the volume
+func (r *ReconcileVirtualMachineVolume) getImage() (*hc.VirtualMachineImage, error) {
+	namespacedName := types.NamespacedName{Namespace: r.getImageNamespace(), Name: r.volume.Spec.VirtualMachineImage.Name}
+	if r.getImageKind() == hc.ClusterVirtualMachineImageKind {
+		namespacedName = cvmi.GetImageNamespacedName(r.volume.Spec.VirtualMachineImage.Name, r.config)
+	} else if namespacedName.Namespace != r.volume.Namespace {
+		if granted, err := r.isImageGranted(namespacedName); err != nil {
+			return nil, err
+		} else if !granted {
+			return nil, goerrors.New("VirtualMachineImage " + namespacedName.String() + " is not granted to namespace " + r.volume.Namespace +
+				". Create VirtualMachineImageGrant in namespace " + namespacedName.Namespace)
+		}
+	}
+	image := &hc.VirtualMachineImage{}
+	if err := r.client.Get(context.TODO(), namespacedName, image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
 // updateStateWithReadyToUse updates readyToUse condition type and State.
 func (r *ReconcileVirtualMachineVolume) updateStateWithReadyToUse(state hc.VirtualMachineVolumeState, readyToUseStatus corev1.ConditionStatus,
 	reason, message string) error {
-	r.volume.Status.Conditions = util.SetConditionByType(r.volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse, readyToUseStatus, reason, message)
+	util.RecordStateEvent(r.recorder, r.volume, r.volume.Status.Conditions, readyToUseStatus, reason, message, state == hc.VirtualMachineVolumeStateError)
+	r.volume.Status.Conditions = util.SetConditionByType(r.volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse, readyToUseStatus, reason, message,
+		r.volume.Generation)
+	r.volume.Status.Conditions = util.SetStateConditions(r.volume.Status.Conditions, state == hc.VirtualMachineVolumeStateCreating ||
+		state == hc.VirtualMachineVolumeStatePending, state == hc.VirtualMachineVolumeStateError, reason, message, r.volume.Generation)
 	r.volume.Status.State = state
+	r.volume.Status.ObservedGeneration = r.volume.Generation
 	return r.client.Status().Update(context.TODO(), r.volume)
 }
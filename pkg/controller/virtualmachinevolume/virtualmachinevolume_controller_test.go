@@ -2,6 +2,7 @@ package virtualmachinevolume
 
 import (
 	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -10,6 +11,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
 	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -18,6 +20,7 @@ var _ = Describe("Reconcile", func() {
 	Context("1. with no image", func() {
 		r := createFakeReconcileVmv()
 		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
 
 		It("Should be nil", func() {
 			Expect(err).Should(BeNil())
@@ -46,12 +49,15 @@ var _ = Describe("Reconcile", func() {
 			Expect(found).Should(BeTrue())
 			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
 		})
+		It("Should record the event of pending", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + hc.ReasonPending)))
+		})
 	})
 
 	Context("2. with false status image", func() {
 		image := newTestImage()
 		r := createFakeReconcileVmv(image)
-		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionFalse, "VmiIsReady", "Vmi is ready to use")
+		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionFalse, hc.ReasonReady, "Vmi is ready to use", image.Generation)
 		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
 
 		It("Should be nil", func() {
@@ -120,9 +126,10 @@ var _ = Describe("Reconcile", func() {
 	Context("4. with true status, invalid size image", func() {
 		image := newTestImage()
 		image.Spec.PVC.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("7Gi")
-		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, "VmiIsReady", "Vmi is ready to use")
+		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionTrue, hc.ReasonReady, "Vmi is ready to use", image.Generation)
 		r := createFakeReconcileVmv(image)
 		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
 
 		It("Should return error", func() {
 			Expect(err).ShouldNot(BeNil())
@@ -150,11 +157,15 @@ var _ = Describe("Reconcile", func() {
 			Expect(found).Should(BeTrue())
 			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
 		})
+		It("Should record the warning event of the failure", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeWarning + " " + hc.ReasonFailed)))
+		})
 	})
 
 	Context("5. with true status, valid size image", func() {
 		r := createFakeReconcileVolumeWithImage()
 		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
 
 		It("Should not return error", func() {
 			Expect(err).Should(BeNil())
@@ -183,6 +194,10 @@ var _ = Describe("Reconcile", func() {
 			Expect(found).Should(BeTrue())
 			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
 		})
+		It("Should record the events of creating pvc", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + hc.ReasonCreating)))
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventPvcCreated)))
+		})
 	})
 
 	Context("6. with valid image, lost phase pvc", func() {
@@ -213,4 +228,201 @@ var _ = Describe("Reconcile", func() {
 			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
 		})
 	})
+
+	Context("7. with valid cluster image", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileClusterImageVolume(image, snapshot, content)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should copy the snapshot of the image in the cluster image namespace", func() {
+			restoreSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotName(r.volume.Name),
+				Namespace: r.volume.Namespace}, restoreSnapshot)
+			Expect(err).Should(BeNil())
+		})
+		It("Should not create pvc until the copied snapshot is ready", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should update state to creating", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStateCreating))
+		})
+		It("Should record the events of copying the snapshot", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + ReasonCopyingSnapshot)))
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventSnapshotCreated)))
+			Expect(events).ShouldNot(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventSnapshotReady)))
+		})
+	})
+
+	Context("8. with not granted image in another namespace", func() {
+		image, snapshot, content := newTestImageWithSnapshot(testImageNamespace)
+		r := createFakeReconcileSharedImageVolume(image, snapshot, content, newTestGrant(testImageName, "othernamespace"))
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+
+		It("Should be nil", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update state to pending", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStatePending))
+			found, cond := util.GetConditionByType(volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Message).Should(ContainSubstring("is not granted"))
+		})
+		It("Should not copy the snapshot of the image", func() {
+			restoreSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotName(r.volume.Name),
+				Namespace: r.volume.Namespace}, restoreSnapshot)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("9. with granted image in another namespace", func() {
+		image, snapshot, content := newTestImageWithSnapshot(testImageNamespace)
+		r := createFakeReconcileSharedImageVolume(image, snapshot, content, newTestGrant(testImageName, testNameSpace))
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should copy the snapshot of the image", func() {
+			restoreSnapshot := &snapshotv1beta1.VolumeSnapshot{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetRestoreSnapshotName(r.volume.Name),
+				Namespace: r.volume.Namespace}, restoreSnapshot)
+			Expect(err).Should(BeNil())
+		})
+		It("Should update state to creating", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStateCreating))
+		})
+	})
+
+	Context("10. with bound pvc, refreshing image", func() {
+		image := newTestImage()
+		image.Status.Conditions = util.SetConditionByType(image.Status.Conditions, hc.ConditionReadyToUse, corev1.ConditionFalse, "Refreshing", "Source image is changed", image.Generation)
+		pvc := newTestPvc()
+		pvc.Status.Phase = corev1.ClaimBound
+		r := createFakeReconcileVmv(image, pvc)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update state to available", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStateAvailable))
+		})
+		It("Should record the event of ready", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + hc.ReasonReady)))
+		})
+	})
+
+	Context("11. with pinned revision which is not available", func() {
+		image := newTestImageWithRevisions(1, 2)
+		r := createFakeReconcileVmv(image)
+		r.volume.Spec.VirtualMachineImage.Revision = &[]int32{3}[0]
+		if err := r.client.Update(context.TODO(), r.volume); err != nil {
+			panic(err)
+		}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+
+		It("Should be nil", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should update state to pending", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.State).Should(Equal(hc.VirtualMachineVolumeStatePending))
+			found, cond := util.GetConditionByType(volume.Status.Conditions, hc.VirtualMachineVolumeConditionReadyToUse)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Message).Should(ContainSubstring("revision 3"))
+		})
+	})
+
+	Context("12. with pinned revision", func() {
+		r := createFakeReconcileVmv(newTestImageWithRevisions(1, 2))
+		r.volume.Spec.VirtualMachineImage.Revision = &[]int32{1}[0]
+		if err := r.client.Update(context.TODO(), r.volume); err != nil {
+			panic(err)
+		}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create pvc from the snapshot of the pinned revision", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Spec.DataSource.Name).Should(Equal(img.GetRevisionSnapshotName(testImageName, 1)))
+		})
+		It("Should record the revision of the image", func() {
+			volume := &hc.VirtualMachineVolume{}
+			err = r.client.Get(context.TODO(), testVolumeNamespacedName, volume)
+			Expect(err).Should(BeNil())
+			Expect(volume.Status.ImageRevision).Should(Equal(int32(1)))
+		})
+	})
+
+	Context("13. with refreshing image", func() {
+		r := createFakeReconcileVmv(newTestImageWithRevisions(1, 2))
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create pvc from the snapshot of the latest available revision", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Spec.DataSource.Name).Should(Equal(img.GetRevisionSnapshotName(testImageName, 2)))
+		})
+	})
+
+	Context("14. with valid cluster image, ready copied snapshot", func() {
+		image, snapshot, content := newTestImageWithSnapshot(util.DefaultOperatorNamespace)
+		r := createFakeReconcileClusterImageVolume(image, snapshot, content, newTestRestoreSnapshot(true))
+		if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeStateCreating, corev1.ConditionFalse, ReasonCopyingSnapshot,
+			"VirtualMachineVolume is copying the snapshot of the image"); err != nil {
+			panic(err)
+		}
+		getTestEvents(r)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: testVolumeNamespacedName})
+		events := getTestEvents(r)
+
+		It("Should not return error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should create pvc from the copied snapshot", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: GetVolumePvcName(r.volume.Name),
+				Namespace: r.volume.Namespace}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Spec.DataSource.Name).Should(Equal(GetRestoreSnapshotName(r.volume.Name)))
+		})
+		It("Should record the events of the ready snapshot and creating pvc", func() {
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventSnapshotReady)))
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + hc.ReasonCreating)))
+			Expect(events).Should(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + util.EventPvcCreated)))
+		})
+	})
 })
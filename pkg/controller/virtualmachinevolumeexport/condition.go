@@ -0,0 +1,34 @@
+package virtualmachinevolumeexport
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"kubevirt-image-service/pkg/util"
+)
+
+// syncConditions updates PvcBound condition by the export pvc, and records the generation of the vmvExport which the status is based on
+func (r *ReconcileVirtualMachineVolumeExport) syncConditions() error {
+	oldStatus := r.vmvExport.Status.DeepCopy()
+	if err := r.setStageConditions(); err != nil {
+		return err
+	}
+	r.vmvExport.Status.ObservedGeneration = r.vmvExport.Generation
+	if equality.Semantic.DeepEqual(oldStatus, &r.vmvExport.Status) {
+		return nil
+	}
+	return r.client.Status().Update(context.TODO(), r.vmvExport)
+}
+
+// setStageConditions sets PvcBound condition of the vmvExport. The status is not updated
+func (r *ReconcileVirtualMachineVolumeExport) setStageConditions() error {
+	var pvc *corev1.PersistentVolumeClaim
+	if found, err := r.getPvc(GetExportPvcName(r.vmvExport.Name)); err == nil {
+		pvc = found
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	r.vmvExport.Status.Conditions = util.SetPvcBoundCondition(r.vmvExport.Status.Conditions, pvc, r.vmvExport.Generation)
+	return nil
+}
@@ -2,6 +2,8 @@ package virtualmachinevolumeexport
 
 import (
 	"context"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -11,6 +13,7 @@ import (
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	vmv "kubevirt-image-service/pkg/controller/virtualmachinevolume"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -45,62 +48,89 @@ const (
 	AccessKeyID = "AWS_ACCESS_KEY_ID"
 	// SecretAccessKey is one of AWS-style credential which is needed when export volume to external object storage
 	SecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	// ExporterBackoffLimit is the number of retries of the failed exporter pod
+	ExporterBackoffLimit = 3
+	// ReasonExportFailed is the reason of ReadyToUse condition when the exporter job failed
+	ReasonExportFailed = "ExportFailed"
 )
 
-func (r *ReconcileVirtualMachineVolumeExport) syncExporterPod() error {
+func (r *ReconcileVirtualMachineVolumeExport) syncExporterJob() error {
 	// completed indicates if pvc export is completed
 	completed, found, err := r.isPvcExportCompleted()
 	if err != nil {
 		return err
 	} else if !found {
-		klog.Warningf("syncExporterPod without pvc in vmvExport %s", r.vmvExport.Name)
+		klog.Warningf("syncExporterJob without pvc in vmvExport %s", r.vmvExport.Name)
 		return nil
 	}
 
-	exporterPod := &corev1.Pod{}
-	err = r.client.Get(context.Background(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
+	exporterJob := &batchv1.Job{}
+	err = r.client.Get(context.Background(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	existsExporterPod := err == nil
+	existsExporterJob := err == nil
 
-	if !completed && existsExporterPod && isPodCompleted(exporterPod) {
-		// pvc export is completed, update completed to yes and delete exporter pod
-		klog.Infof("syncExporterPod finish for vmvExport %s, delete exporterPod", r.vmvExport.Name)
+	if !completed && existsExporterJob && util.IsJobCompleted(exporterJob) {
+		// pvc export is completed, update completed to yes and delete exporter job
+		klog.Infof("syncExporterJob finish for vmvExport %s, delete exporterJob", r.vmvExport.Name)
 		if err := r.updatePvcCompleted(true); err != nil {
 			return err
 		}
-		if err := r.client.Delete(context.TODO(), exporterPod); err != nil && !errors.IsNotFound(err) {
+		if err := util.DeleteJob(r.client, exporterJob); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmvExport, corev1.EventTypeNormal, util.EventJobCompleted, "Exporter job %s completed, the volume is exported", exporterJob.Name)
 		if destination := r.getDestination(); destination != ExporterDestinationLocal {
-			if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCompleted, corev1.ConditionTrue, "vmvExportIsCompleted", "vmvExport is completed"); err != nil {
+			if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCompleted, corev1.ConditionTrue, hc.ReasonCompleted, "vmvExport is completed"); err != nil {
 				return err
 			}
 		}
-	} else if !completed && !existsExporterPod {
-		// pvc export is not completed, should create exporter pod
-		klog.Infof("syncExporterPod create new exporterPod for vmvExport %s", r.vmvExport.Name)
-		newPod, err := r.newExporterPod(r.vmvExport, r.scheme)
+	} else if failed := util.GetJobCondition(exporterJob, batchv1.JobFailed); !completed && existsExporterJob && failed != nil {
+		// exporter job failed after the backoff limit or the deadline, the job is not created again
+		return &vmvExportError{reason: ReasonExportFailed, message: fmt.Sprintf("exporter job failed(%s): %s", failed.Reason, failed.Message)}
+	} else if !completed && !existsExporterJob {
+		// the failed exporter job may be deleted by ttl, so check the condition not to create it again
+		if found, cond := util.GetConditionByType(r.vmvExport.Status.Conditions, hc.VirtualMachineVolumeExportConditionReadyToUse); found && cond.Reason == ReasonExportFailed {
+			return &vmvExportError{reason: ReasonExportFailed, message: cond.Message}
+		}
+		// pvc export is not completed, should create exporter job
+		sourcePvc, err := r.getPvc(vmv.GetVolumePvcName(r.vmvExport.Spec.VirtualMachineVolume.Name))
+		if err != nil {
+			return err
+		}
+		klog.Infof("syncExporterJob create new exporterJob for vmvExport %s", r.vmvExport.Name)
+		newJob, err := r.newExporterJob(r.vmvExport, sourcePvc.Spec.VolumeMode, r.scheme)
 		if err != nil {
 			return err
 		}
-		if err := r.client.Create(context.Background(), newPod); err != nil && !errors.IsAlreadyExists(err) {
+		if err := r.client.Create(context.Background(), newJob); err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
+		r.recorder.Eventf(r.vmvExport, corev1.EventTypeNormal, util.EventJobStarted, "Started exporter job %s", newJob.Name)
 	}
 	return nil
 }
 
-// GetExporterPodName returns exporter pod name from vmvExport name
-func GetExporterPodName(vmvExportName string) string {
+// GetExporterJobName returns exporter job name from vmvExport name
+func GetExporterJobName(vmvExportName string) string {
 	return vmvExportName + "-exporter"
 }
 
-func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.VirtualMachineVolumeExport, scheme *runtime.Scheme) (*corev1.Pod, error) {
+// newExporterJob returns the job which runs the exporter pod. The failed pod is retried up to ExporterBackoffLimit times
+func (r *ReconcileVirtualMachineVolumeExport) newExporterJob(vmvExport *hc.VirtualMachineVolumeExport, sourceVolumeMode *corev1.PersistentVolumeMode, scheme *runtime.Scheme) (*batchv1.Job, error) {
+	job := util.NewJob(r.newExporterPod(vmvExport, sourceVolumeMode), ExporterBackoffLimit, r.config)
+	if err := controllerutil.SetControllerReference(vmvExport, job, scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newExporterPod returns the template of the exporter job. The source pvc is attached as the device or mounted by sourceVolumeMode
+func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.VirtualMachineVolumeExport, sourceVolumeMode *corev1.PersistentVolumeMode) *corev1.Pod {
 	ep := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      GetExporterPodName(vmvExport.Name),
+			Name:      GetExporterJobName(vmvExport.Name),
 			Namespace: vmvExport.Namespace,
 			Labels: map[string]string{
 				"app": vmvExport.Name,
@@ -110,12 +140,11 @@ func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.Virtu
 			Containers: []corev1.Container{
 				{
 					Name:            ExporterName,
-					Image:           ExporterImage,
+					Image:           util.GetImageOrDefault(r.config.Images.Exporter, ExporterImage),
 					ImagePullPolicy: corev1.PullPolicy("IfNotPresent"),
 					Args:            []string{},
 					Env: []corev1.EnvVar{
 						{Name: ExporterDestination, Value: r.getDestination()},
-						{Name: ExporterSourcePath, Value: SourceDevicePath},
 						{Name: ExporterExportDir, Value: ExportDataDir},
 					},
 					Resources: corev1.ResourceRequirements{
@@ -129,9 +158,6 @@ func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.Virtu
 					VolumeMounts: []corev1.VolumeMount{
 						{Name: ExportVolumeName, MountPath: ExportDataDir},
 					},
-					VolumeDevices: []corev1.VolumeDevice{
-						{Name: SourceVolumeName, DevicePath: SourceDevicePath},
-					},
 				},
 			},
 			Volumes: []corev1.Volume{
@@ -155,9 +181,11 @@ func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.Virtu
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser: &[]int64{0}[0],
 			},
-			RestartPolicy: corev1.RestartPolicyOnFailure,
+			RestartPolicy: corev1.RestartPolicyNever,
 		},
 	}
+	sourcePath := util.AttachDiskVolume(&ep.Spec.Containers[0], SourceVolumeName, sourceVolumeMode, SourceDevicePath, SourceDataDir, true)
+	ep.Spec.Containers[0].Env = append(ep.Spec.Containers[0].Env, corev1.EnvVar{Name: ExporterSourcePath, Value: sourcePath})
 
 	if r.getDestination() == ExporterDestinationS3 {
 		ep.Spec.Containers[0].Env = append(ep.Spec.Containers[0].Env, corev1.EnvVar{
@@ -186,14 +214,6 @@ func (r *ReconcileVirtualMachineVolumeExport) newExporterPod(vmvExport *hc.Virtu
 		})
 	}
 
-	if err := controllerutil.SetControllerReference(vmvExport, ep, scheme); err != nil {
-		return nil, err
-	}
-	return ep, nil
-}
-
-func isPodCompleted(pod *corev1.Pod) bool {
-	return len(pod.Status.ContainerStatuses) != 0 &&
-		pod.Status.ContainerStatuses[0].State.Terminated != nil &&
-		pod.Status.ContainerStatuses[0].State.Terminated.Reason == "Completed"
+	util.ApplyConfigToPod(ep, r.config)
+	return ep
 }
@@ -2,38 +2,45 @@ package virtualmachinevolumeexport
 
 import (
 	"context"
+	goerrors "errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 )
 
-// no.		pvc		completed		exporterPod		exporterPodState
+// no.		pvc		completed		exporterJob		exporterJobState
 // 1		X
 // 2		O		yes				X
 // 3		O		no				X
 // 4		O		no				O				Running
 // 5		O		no				O				Complete
-var _ = Describe("syncExporterPod", func() {
+// 6		O		no				O				Failed
+// 7		O		no				X								(ExportFailed)
+// 8		O		no				X								(Filesystem-mode source pvc)
+var _ = Describe("syncExporterJob", func() {
 	Context("1. with no pvc", func() {
 		vmvPvc := newVmvPvc()
 
 		r := createFakeReconcileVmvExport(vmvPvc)
-		err := r.syncExporterPod()
+		err := r.syncExporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not create exporterPod", func() {
-			exporterPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
+		It("Should not create exporterJob", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
 			Expect(errors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
 
-	Context("2. with pvc, completed=yes, no exporterPod", func() {
+	Context("2. with pvc, completed=yes, no exporterJob", func() {
 		completedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -44,19 +51,19 @@ var _ = Describe("syncExporterPod", func() {
 			},
 		}
 		r := createFakeReconcileVmvExport(completedPvc)
-		err := r.syncExporterPod()
+		err := r.syncExporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not create exporterPod", func() {
-			exporterPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
+		It("Should not create exporterJob", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
 			Expect(errors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
 
-	Context("3. with pvc, completed=no, no exporterPod", func() {
+	Context("3. with pvc, completed=no, no exporterJob", func() {
 		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -66,20 +73,28 @@ var _ = Describe("syncExporterPod", func() {
 				},
 			},
 		}
-		r := createFakeReconcileVmvExport(notCompletedPvc)
-		err := r.syncExporterPod()
+		r := createFakeReconcileVmvExport(notCompletedPvc, newVmvPvc())
+		err := r.syncExporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should create exporterPod", func() {
-			exporterPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
+		It("Should create exporterJob", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
+			Expect(err).Should(BeNil())
+		})
+		It("Should attach the Block-mode source pvc as the device", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
 			Expect(err).Should(BeNil())
+			container := exporterJob.Spec.Template.Spec.Containers[0]
+			Expect(container.VolumeDevices).Should(ContainElement(corev1.VolumeDevice{Name: SourceVolumeName, DevicePath: SourceDevicePath}))
+			Expect(container.Env).Should(ContainElement(corev1.EnvVar{Name: ExporterSourcePath, Value: SourceDevicePath}))
 		})
 	})
 
-	Context("4. with pvc, completed=no, exporterPod with running", func() {
+	Context("4. with pvc, completed=no, exporterJob with running", func() {
 		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -89,26 +104,51 @@ var _ = Describe("syncExporterPod", func() {
 				},
 			},
 		}
-		exporterPod := &corev1.Pod{
+		exporterJob := newTestJob(GetExporterJobName(vmvExportName), "")
+		r := createFakeReconcileVmvExport(notCompletedPvc, exporterJob)
+		err := r.syncExporterJob()
+
+		It("Should return no error", func() {
+			Expect(err).Should(BeNil())
+		})
+		It("Should not delete exporterJob", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
+			Expect(err).Should(BeNil())
+		})
+	})
+
+	Context("5. with pvc, completed=no, exporterJob with complete", func() {
+		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetExporterPodName(vmvExportName),
+				Name:      GetExportPvcName(vmvExportName),
 				Namespace: defaultNamespace,
+				Annotations: map[string]string{
+					"completed": "no",
+				},
 			},
 		}
-		r := createFakeReconcileVmvExport(notCompletedPvc, exporterPod)
-		err := r.syncExporterPod()
+		exporterJob := newTestJob(GetExporterJobName(vmvExportName), batchv1.JobComplete)
+		r := createFakeReconcileVmvExport(notCompletedPvc, exporterJob)
+		err := r.syncExporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not delete exporterPod", func() {
-			exporterPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
+		It("Should delete exporterJob", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+		It("Should update pvc annotation(completed=yes)", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExportPvcName(r.vmvExport.Name)}, pvc)
 			Expect(err).Should(BeNil())
+			Expect(pvc.Annotations["completed"]).Should(Equal("yes"))
 		})
 	})
 
-	Context("5. with pvc, completed=no, exporterPod with complete", func() {
+	Context("6. with pvc, completed=no, exporterJob with failed", func() {
 		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -118,39 +158,78 @@ var _ = Describe("syncExporterPod", func() {
 				},
 			},
 		}
-		exporterPod := &corev1.Pod{
+		exporterJob := newTestJob(GetExporterJobName(vmvExportName), batchv1.JobFailed)
+		r := createFakeReconcileVmvExport(notCompletedPvc, exporterJob)
+		err := r.syncExporterJob()
+
+		It("Should return ExportFailed error", func() {
+			exportErr := (*vmvExportError)(nil)
+			Expect(goerrors.As(err, &exportErr)).Should(BeTrue())
+			Expect(exportErr.reason).Should(Equal(ReasonExportFailed))
+			Expect(exportErr.message).Should(ContainSubstring("BackoffLimitExceeded"))
+		})
+		It("Should not update pvc annotation(completed=no)", func() {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExportPvcName(r.vmvExport.Name)}, pvc)
+			Expect(err).Should(BeNil())
+			Expect(pvc.Annotations["completed"]).Should(Equal("no"))
+		})
+	})
+
+	Context("7. with pvc, completed=no, no exporterJob, export failed", func() {
+		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      GetExporterPodName(vmvExportName),
+				Name:      GetExportPvcName(vmvExportName),
 				Namespace: defaultNamespace,
+				Annotations: map[string]string{
+					"completed": "no",
+				},
 			},
-			Status: corev1.PodStatus{
-				ContainerStatuses: []corev1.ContainerStatus{
-					{
-						State: corev1.ContainerState{
-							Terminated: &corev1.ContainerStateTerminated{
-								Reason: "Completed",
-							},
-						},
-					},
+		}
+		r := createFakeReconcileVmvExport(notCompletedPvc)
+		r.vmvExport.Status.Conditions = util.SetConditionByType(r.vmvExport.Status.Conditions, hc.VirtualMachineVolumeExportConditionReadyToUse,
+			corev1.ConditionFalse, ReasonExportFailed, "exporter job failed(DeadlineExceeded): Job was active longer than specified deadline", r.vmvExport.Generation)
+		err := r.syncExporterJob()
+
+		It("Should return ExportFailed error", func() {
+			exportErr := (*vmvExportError)(nil)
+			Expect(goerrors.As(err, &exportErr)).Should(BeTrue())
+			Expect(exportErr.reason).Should(Equal(ReasonExportFailed))
+		})
+		It("Should not create exporterJob again", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("8. with pvc, completed=no, no exporterJob, Filesystem-mode source pvc", func() {
+		notCompletedPvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      GetExportPvcName(vmvExportName),
+				Namespace: defaultNamespace,
+				Annotations: map[string]string{
+					"completed": "no",
 				},
 			},
 		}
-		r := createFakeReconcileVmvExport(notCompletedPvc, exporterPod)
-		err := r.syncExporterPod()
+		vmvPvc := newVmvPvc()
+		volumeMode := corev1.PersistentVolumeFilesystem
+		vmvPvc.Spec.VolumeMode = &volumeMode
+		r := createFakeReconcileVmvExport(notCompletedPvc, vmvPvc)
+		err := r.syncExporterJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should delete exporterPod", func() {
-			exporterPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterPodName(r.vmvExport.Name)}, exporterPod)
-			Expect(errors.IsNotFound(err)).Should(BeTrue())
-		})
-		It("Should update pvc annotation(completed=yes)", func() {
-			pvc := &corev1.PersistentVolumeClaim{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExportPvcName(r.vmvExport.Name)}, pvc)
+		It("Should mount the source pvc and export the disk image in it", func() {
+			exporterJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: GetExporterJobName(r.vmvExport.Name)}, exporterJob)
 			Expect(err).Should(BeNil())
-			Expect(pvc.Annotations["completed"]).Should(Equal("yes"))
+			container := exporterJob.Spec.Template.Spec.Containers[0]
+			Expect(container.VolumeDevices).Should(BeEmpty())
+			Expect(container.VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: SourceVolumeName, MountPath: SourceDataDir, ReadOnly: true}))
+			Expect(container.Env).Should(ContainElement(corev1.EnvVar{Name: ExporterSourcePath, Value: SourceDataDir + "/" + util.DiskImageName}))
 		})
 	})
 })
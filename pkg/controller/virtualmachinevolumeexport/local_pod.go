@@ -2,6 +2,7 @@ package virtualmachinevolumeexport
 
 import (
 	"context"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -10,56 +11,75 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func (r *ReconcileVirtualMachineVolumeExport) syncLocalPod() error {
+const (
+	// LocalPodImage indicates the default image of the local pod which holds the exported disk to be copied to the local
+	LocalPodImage = "busybox"
+)
+
+func (r *ReconcileVirtualMachineVolumeExport) syncLocalJob() error {
 	// completed indicates if pvc export is completed
 	completed, found, err := r.isPvcExportCompleted()
 	if err != nil {
 		return err
 	} else if !found {
-		klog.Warningf("syncLocalPod without pvc in vmvExport %s", r.vmvExport.Name)
+		klog.Warningf("syncLocalJob without pvc in vmvExport %s", r.vmvExport.Name)
 		return nil
 	}
 
-	localPod := &corev1.Pod{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalPodName(r.vmvExport.Name)}, localPod)
+	localJob := &batchv1.Job{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	existsLocalPod := err == nil
+	existsLocalJob := err == nil
 
-	if completed && !existsLocalPod {
-		// pvc export is completed but there is no local pod, so create a local pod and update readytouse to true
-		klog.Infof("syncLocalPod create new localPod for vmvExport %s", r.vmvExport.Name)
-		newPod, err := newLocalPod(r.vmvExport, r.scheme)
+	if completed && !existsLocalJob {
+		// pvc export is completed but there is no local job, so create a local job and update readytouse to true
+		klog.Infof("syncLocalJob create new localJob for vmvExport %s", r.vmvExport.Name)
+		newJob, err := newLocalJob(r.vmvExport, r.scheme, r.config)
 		if err != nil {
 			return err
 		}
-		if err := r.client.Create(context.TODO(), newPod); err != nil && !errors.IsAlreadyExists(err) {
+		if err := r.client.Create(context.TODO(), newJob); err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
-		if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCompleted, corev1.ConditionTrue, "VmvExportIsReady", "VmvExport is ready to use"); err != nil {
+		r.recorder.Eventf(r.vmvExport, corev1.EventTypeNormal, util.EventJobStarted, "Started local job %s", newJob.Name)
+		if err := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCompleted, corev1.ConditionTrue, hc.ReasonCompleted, "VmvExport is ready to use"); err != nil {
 			return err
 		}
-	} else if !completed && existsLocalPod {
-		// pvc export is not completed but there is a local pod, so delete the local pod
-		if err := r.client.Delete(context.TODO(), localPod); err != nil && !errors.IsNotFound(err) {
+	} else if !completed && existsLocalJob {
+		// pvc export is not completed but there is a local job, so delete the local job
+		if err := util.DeleteJob(r.client, localJob); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
 	}
 	return nil
 }
 
-func getLocalPodName(vmvExportName string) string {
+func getLocalJobName(vmvExportName string) string {
 	return vmvExportName + "-exporter-local"
 }
 
-func newLocalPod(vmvExport *hc.VirtualMachineVolumeExport, scheme *runtime.Scheme) (*corev1.Pod, error) {
+// newLocalJob returns the job which runs the local pod. The local pod never exits, so its name is kept until the vmvExport is deleted
+func newLocalJob(vmvExport *hc.VirtualMachineVolumeExport, scheme *runtime.Scheme, config hc.KubevirtImageServiceConfigSpec) (*batchv1.Job, error) {
+	job := util.NewJob(newLocalPod(vmvExport, config), ExporterBackoffLimit, config)
+	// 로컬 파드는 복사가 끝날 때까지 떠 있어야 하므로 활성 기한으로 종료시키지 않는다
+	job.Spec.ActiveDeadlineSeconds = nil
+	if err := controllerutil.SetControllerReference(vmvExport, job, scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newLocalPod returns the template of the local job which holds the export pvc until the exported disk is copied
+func newLocalPod(vmvExport *hc.VirtualMachineVolumeExport, config hc.KubevirtImageServiceConfigSpec) *corev1.Pod {
 	lp := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      getLocalPodName(vmvExport.Name),
+			Name:      getLocalJobName(vmvExport.Name),
 			Namespace: vmvExport.Namespace,
 			Labels: map[string]string{
 				"app": vmvExport.Name,
@@ -69,8 +89,8 @@ func newLocalPod(vmvExport *hc.VirtualMachineVolumeExport, scheme *runtime.Schem
 			Containers: []corev1.Container{
 				{
 					Name:            "busybox",
-					Image:           "busybox",
-					Command:         []string{"sleep", "600"},
+					Image:           util.GetImageOrDefault(config.Images.Local, LocalPodImage),
+					Command:         []string{"tail", "-f", "/dev/null"},
 					ImagePullPolicy: corev1.PullPolicy("IfNotPresent"),
 					Resources: corev1.ResourceRequirements{
 						Limits: map[corev1.ResourceName]resource.Quantity{
@@ -95,11 +115,9 @@ func newLocalPod(vmvExport *hc.VirtualMachineVolumeExport, scheme *runtime.Schem
 					},
 				},
 			},
-			RestartPolicy: corev1.RestartPolicyAlways,
+			RestartPolicy: corev1.RestartPolicyOnFailure,
 		},
 	}
-	if err := controllerutil.SetControllerReference(vmvExport, lp, scheme); err != nil {
-		return nil, err
-	}
-	return lp, nil
+	util.ApplyConfigToPod(lp, config)
+	return lp
 }
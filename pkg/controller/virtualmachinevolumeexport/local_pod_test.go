@@ -4,6 +4,7 @@ import (
 	"context"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -12,17 +13,17 @@ import (
 	"kubevirt-image-service/pkg/util"
 )
 
-// no.		pvc		completed		localPod
+// no.		pvc		completed		localJob
 // 1		X
 // 2		O		X
 // 3		O		no				X
 // 4		O		no				O
 // 5		O		yes				X
-// 6		O		yes				O
-var _ = Describe("syncLocalPod", func() {
+// 6		O		yes				O(running)
+var _ = Describe("syncLocalJob", func() {
 	Context("1. with no pvc", func() {
 		r := createFakeReconcileVmvExport()
-		err := r.syncLocalPod()
+		err := r.syncLocalJob()
 
 		It("Should return no error", func() {
 			Expect(err).Should(BeNil())
@@ -37,14 +38,14 @@ var _ = Describe("syncLocalPod", func() {
 			},
 		}
 		r := createFakeReconcileVmvExport(pvcWithoutAnnotation)
-		err := r.syncLocalPod()
+		err := r.syncLocalJob()
 
 		It("Should return error", func() {
 			Expect(err).ShouldNot(BeNil())
 		})
 	})
 
-	Context("3. with pvc(completed: no) and no localPod", func() {
+	Context("3. with pvc(completed: no) and no localJob", func() {
 		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -55,19 +56,19 @@ var _ = Describe("syncLocalPod", func() {
 			},
 		}
 		r := createFakeReconcileVmvExport(notCompletedPvc)
-		err := r.syncLocalPod()
+		err := r.syncLocalJob()
 
 		It("Should not return error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not create localPod", func() {
-			localPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalPodName(r.vmvExport.Name)}, localPod)
+		It("Should not create localJob", func() {
+			localJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
 			Expect(errors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
 
-	Context("4. with pvc(completed: no) and localPod", func() {
+	Context("4. with pvc(completed: no) and localJob", func() {
 		notCompletedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -77,26 +78,21 @@ var _ = Describe("syncLocalPod", func() {
 				},
 			},
 		}
-		localPod := &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      getLocalPodName(vmvExportName),
-				Namespace: defaultNamespace,
-			},
-		}
-		r := createFakeReconcileVmvExport(notCompletedPvc, localPod)
-		err := r.syncLocalPod()
+		localJob := newTestJob(getLocalJobName(vmvExportName), "")
+		r := createFakeReconcileVmvExport(notCompletedPvc, localJob)
+		err := r.syncLocalJob()
 
 		It("Should not return error", func() {
 			Expect(err).Should(BeNil())
 		})
 		It("Should Delete snapshot", func() {
-			localPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalPodName(r.vmvExport.Name)}, localPod)
+			localJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
 			Expect(errors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
 
-	Context("5. with pvc(completed: yes) and no localPod", func() {
+	Context("5. with pvc(completed: yes) and no localJob", func() {
 		completedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -107,16 +103,23 @@ var _ = Describe("syncLocalPod", func() {
 			},
 		}
 		r := createFakeReconcileVmvExport(completedPvc)
-		err := r.syncLocalPod()
+		err := r.syncLocalJob()
 
 		It("Should not return error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should Create localPod", func() {
-			localPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalPodName(r.vmvExport.Name)}, localPod)
+		It("Should Create localJob", func() {
+			localJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
 			Expect(errors.IsNotFound(err)).Should(BeFalse())
 		})
+		It("Should run the local pod which never exits", func() {
+			localJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
+			Expect(err).Should(BeNil())
+			Expect(localJob.Spec.Template.Spec.Containers[0].Command).Should(Equal([]string{"tail", "-f", "/dev/null"}))
+			Expect(localJob.Spec.ActiveDeadlineSeconds).Should(BeNil())
+		})
 		It("Should update state to available", func() {
 			vmvExport := &hc.VirtualMachineVolumeExport{}
 			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: r.vmvExport.Name}, vmvExport)
@@ -133,7 +136,7 @@ var _ = Describe("syncLocalPod", func() {
 		})
 	})
 
-	Context("6. with pvc(completed: yes) and localPod", func() {
+	Context("6. with pvc(completed: yes) and localJob", func() {
 		completedPvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      GetExportPvcName(vmvExportName),
@@ -143,21 +146,16 @@ var _ = Describe("syncLocalPod", func() {
 				},
 			},
 		}
-		localPod := &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      getLocalPodName(vmvExportName),
-				Namespace: defaultNamespace,
-			},
-		}
-		r := createFakeReconcileVmvExport(completedPvc, localPod)
-		err := r.syncLocalPod()
+		localJob := newTestJob(getLocalJobName(vmvExportName), "")
+		r := createFakeReconcileVmvExport(completedPvc, localJob)
+		err := r.syncLocalJob()
 
 		It("Should not return error", func() {
 			Expect(err).Should(BeNil())
 		})
-		It("Should not Delete localPod", func() {
-			localPod := &corev1.Pod{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalPodName(r.vmvExport.Name)}, localPod)
+		It("Should not Delete localJob", func() {
+			localJob := &batchv1.Job{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: r.vmvExport.Namespace, Name: getLocalJobName(r.vmvExport.Name)}, localJob)
 			Expect(err).Should(BeNil())
 			Expect(errors.IsNotFound(err)).Should(BeFalse())
 		})
@@ -12,6 +12,7 @@ import (
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	vmv "kubevirt-image-service/pkg/controller/virtualmachinevolume"
+	"kubevirt-image-service/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -29,7 +30,7 @@ func (r *ReconcileVirtualMachineVolumeExport) syncExportPvc() error {
 	}
 
 	klog.Infof("Create a new pvc for vmvExport %s", r.vmvExport.Name)
-	if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCreating, corev1.ConditionFalse, "VmvExportIsCreating", "VmvExport is in creating"); err2 != nil {
+	if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateCreating, corev1.ConditionFalse, hc.ReasonCreating, "VmvExport is in creating"); err2 != nil {
 		return err2
 	}
 
@@ -40,6 +41,7 @@ func (r *ReconcileVirtualMachineVolumeExport) syncExportPvc() error {
 	if err := r.client.Create(context.TODO(), newPvc); err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
+	r.recorder.Eventf(r.vmvExport, corev1.EventTypeNormal, util.EventPvcCreated, "Created export pvc %s from volume pvc %s", newPvc.Name, sourcePvc.Name)
 	return nil
 }
 
@@ -1,10 +1,12 @@
 package virtualmachinevolumeexport
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
 	vmv "kubevirt-image-service/pkg/controller/virtualmachinevolume"
@@ -12,10 +14,11 @@ import (
 )
 
 const (
-	vmiName          = "testvmi"
-	vmvName          = "testvmv"
-	vmvExportName    = "testvmvexport"
-	defaultNamespace = "default"
+	vmiName             = "testvmi"
+	vmvName             = "testvmv"
+	vmvExportName       = "testvmvexport"
+	defaultNamespace    = "default"
+	testEventBufferSize = 100
 )
 
 func createFakeReconcileVmvExport(objects ...runtime.Object) *ReconcileVirtualMachineVolumeExport {
@@ -24,7 +27,7 @@ func createFakeReconcileVmvExport(objects ...runtime.Object) *ReconcileVirtualMa
 	if err != nil {
 		panic(err)
 	}
-	return &ReconcileVirtualMachineVolumeExport{client: client, scheme: scheme, vmvExport: vmvExport}
+	return &ReconcileVirtualMachineVolumeExport{client: client, scheme: scheme, recorder: record.NewFakeRecorder(testEventBufferSize), vmvExport: vmvExport}
 }
 
 func newTestVmvExport() *hc.VirtualMachineVolumeExport {
@@ -74,3 +77,21 @@ func newVmvPvc() *corev1.PersistentVolumeClaim {
 		},
 	}
 }
+
+// newTestJob returns the job with the true condition of conditionType, or the running job if conditionType is empty
+func newTestJob(name string, conditionType batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: defaultNamespace,
+		},
+	}
+	if conditionType != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+	}
+	if conditionType == batchv1.JobFailed {
+		job.Status.Conditions[0].Reason = "BackoffLimitExceeded"
+		job.Status.Conditions[0].Message = "Job has reached the specified backoff limit"
+	}
+	return job
+}
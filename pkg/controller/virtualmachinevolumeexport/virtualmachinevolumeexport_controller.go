@@ -3,10 +3,13 @@ package virtualmachinevolumeexport
 import (
 	"context"
 	goerrors "errors"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
 	"kubevirt-image-service/pkg/util"
@@ -30,7 +33,7 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileVirtualMachineVolumeExport{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileVirtualMachineVolumeExport{client: mgr.GetClient(), scheme: mgr.GetScheme(), recorder: mgr.GetEventRecorderFor("virtualmachinevolumeexport-controller")}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -55,8 +58,8 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	if err != nil {
 		return err
 	}
-	// Watch for changes to secondary resource Pods and requeue the owner VirtualMachineVolumeExport
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+	// Watch for changes to secondary resource Jobs and requeue the owner VirtualMachineVolumeExport
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &hc.VirtualMachineVolumeExport{},
 	})
@@ -74,9 +77,12 @@ var _ reconcile.Reconciler = &ReconcileVirtualMachineVolumeExport{}
 type ReconcileVirtualMachineVolumeExport struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client    client.Client
-	scheme    *runtime.Scheme
+	client client.Client
+	scheme *runtime.Scheme
+	// recorder records the events of the vmvExport, which kubectl describe shows
+	recorder  record.EventRecorder
 	vmvExport *hc.VirtualMachineVolumeExport
+	config    hc.KubevirtImageServiceConfigSpec
 }
 
 // Reconcile reads that state of the cluster for a VirtualMachineVolumeExport object and makes changes based on the state read
@@ -98,10 +104,15 @@ func (r *ReconcileVirtualMachineVolumeExport) Reconcile(request reconcile.Reques
 		return reconcile.Result{}, err
 	}
 	r.vmvExport = cachedVmvExport.DeepCopy()
+	config, err := util.GetConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.config = config
 
 	// check if virtual machine volume to export is available
 	if err := r.validateVirtualMachineVolume(); err != nil {
-		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStatePending, corev1.ConditionFalse, "VmvExportIsInPending", err.Error()); err2 != nil {
+		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStatePending, corev1.ConditionFalse, hc.ReasonPending, err.Error()); err2 != nil {
 			return reconcile.Result{}, err2
 		}
 		return reconcile.Result{RequeueAfter: ReconcileInterval}, nil
@@ -113,15 +124,15 @@ func (r *ReconcileVirtualMachineVolumeExport) Reconcile(request reconcile.Reques
 			return err
 		}
 
-		// if pvc export is not completed, create exporter pod if it not exist
-		// if there is an exporter pod and state is complete, update completed to yes and delete it
-		if err := r.syncExporterPod(); err != nil {
+		// if pvc export is not completed, create exporter job if it not exist
+		// if there is an exporter job and it is complete, update completed to yes and delete it
+		if err := r.syncExporterJob(); err != nil {
 			return err
 		}
 
-		// if destination is local, create local pod and update readytouse to true if it not exist
+		// if destination is local, create local job and update readytouse to true if it not exist
 		if destination := r.getDestination(); destination == ExporterDestinationLocal {
-			if err := r.syncLocalPod(); err != nil {
+			if err := r.syncLocalJob(); err != nil {
 				return err
 			}
 		}
@@ -129,19 +140,46 @@ func (r *ReconcileVirtualMachineVolumeExport) Reconcile(request reconcile.Reques
 	}
 
 	if err := syncExport(); err != nil {
-		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateError, corev1.ConditionFalse, "VmvExportIsInError", err.Error()); err2 != nil {
+		reason := hc.ReasonFailed
+		if exportErr := (*vmvExportError)(nil); goerrors.As(err, &exportErr) {
+			reason = exportErr.reason
+		}
+		if err2 := r.setStageConditions(); err2 != nil {
+			return reconcile.Result{}, err2
+		}
+		if err2 := r.updateStateWithReadyToUse(hc.VirtualMachineVolumeExportStateError, corev1.ConditionFalse, reason, err.Error()); err2 != nil {
 			return reconcile.Result{}, err2
 		}
 		return reconcile.Result{}, err
 	}
+	// 각 단계의 조건을 익스포트 pvc에 맞춘다
+	if err := r.syncConditions(); err != nil {
+		return reconcile.Result{}, err
+	}
 	return reconcile.Result{}, nil
 }
 
+// vmvExportError is an error with the reason of ReadyToUse condition
+type vmvExportError struct {
+	reason  string
+	message string
+}
+
+func (e *vmvExportError) Error() string {
+	return e.message
+}
+
 // updateStateWithReadyToUse updates conditions and state. Other Status fields are not affected. vmvExport must be DeepCopy to avoid polluting the cache.
 func (r *ReconcileVirtualMachineVolumeExport) updateStateWithReadyToUse(state hc.VirtualMachineVolumeExportState, readyToUseStatus corev1.ConditionStatus,
 	reason, message string) error {
-	r.vmvExport.Status.Conditions = util.SetConditionByType(r.vmvExport.Status.Conditions, hc.VirtualMachineVolumeExportConditionReadyToUse, readyToUseStatus, reason, message)
+	util.RecordStateEvent(r.recorder, r.vmvExport, r.vmvExport.Status.Conditions, readyToUseStatus, reason, message,
+		state == hc.VirtualMachineVolumeExportStateError)
+	r.vmvExport.Status.Conditions = util.SetConditionByType(r.vmvExport.Status.Conditions, hc.VirtualMachineVolumeExportConditionReadyToUse, readyToUseStatus, reason, message,
+		r.vmvExport.Generation)
+	r.vmvExport.Status.Conditions = util.SetStateConditions(r.vmvExport.Status.Conditions, state == hc.VirtualMachineVolumeExportStateCreating ||
+		state == hc.VirtualMachineVolumeExportStatePending, state == hc.VirtualMachineVolumeExportStateError, reason, message, r.vmvExport.Generation)
 	r.vmvExport.Status.State = state
+	r.vmvExport.Status.ObservedGeneration = r.vmvExport.Generation
 	return r.client.Status().Update(context.TODO(), r.vmvExport)
 }
 
@@ -162,16 +200,35 @@ func (r *ReconcileVirtualMachineVolumeExport) validateVirtualMachineVolume() err
 		return goerrors.New("VirtualMachineVolume state is not in the condition")
 	}
 
+	return ValidateVirtualMachineVolumeExportSpec(&r.vmvExport.Spec)
+}
+
+// ValidateVirtualMachineVolumeExportSpec validates the destination of the export.
+// It is used by the validating webhook to reject the invalid export before it is created
+func ValidateVirtualMachineVolumeExportSpec(spec *hc.VirtualMachineVolumeExportSpec) error {
 	// check if destination is set
-	if r.vmvExport.Spec.Destination.Local == nil && r.vmvExport.Spec.Destination.S3 == nil {
+	if spec.Destination.Local == nil && spec.Destination.S3 == nil {
 		return goerrors.New("export destination is not provided")
 	}
 
 	// check if multiple destination is set
-	if r.vmvExport.Spec.Destination.Local != nil && r.vmvExport.Spec.Destination.S3 != nil {
+	if spec.Destination.Local != nil && spec.Destination.S3 != nil {
 		return goerrors.New("can not export to multiple destination at a time")
 	}
 
+	// check if s3 url is set
+	if spec.Destination.S3 != nil && spec.Destination.S3.URL == "" {
+		return goerrors.New("url of s3 destination is not provided")
+	}
+
+	return nil
+}
+
+// ValidateVirtualMachineVolumeExportSpecUpdate returns error if the spec is changed. The volume is exported only once
+func ValidateVirtualMachineVolumeExportSpecUpdate(oldSpec, newSpec *hc.VirtualMachineVolumeExportSpec) error {
+	if !equality.Semantic.DeepEqual(oldSpec, newSpec) {
+		return goerrors.New("spec of VirtualMachineVolumeExport is immutable")
+	}
 	return nil
 }
 
@@ -0,0 +1,192 @@
+package uploadproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	goerrors "errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// UploadPath is the path of the upload api. The image is uploaded to /v1alpha1/upload/{namespace}/{vmiName}
+	UploadPath = "/v1alpha1/upload/"
+)
+
+var (
+	errInvalidToken = goerrors.New("upload token is invalid")
+	errExpiredToken = goerrors.New("upload token is expired")
+)
+
+// blank assignment to verify that Server implements manager.Runnable
+var _ manager.Runnable = &Server{}
+
+// Server is the upload proxy which authenticates the upload token and streams the uploaded image to the upload server pod
+type Server struct {
+	client           client.Reader
+	bindAddress      string
+	certDir          string
+	uploadServerPort int
+}
+
+// New returns a new upload proxy server which listens on bindAddress. It serves https with tls.crt and tls.key in certDir,
+// or http if certDir is empty
+func New(c client.Reader, bindAddress, certDir string) *Server {
+	return &Server{client: c, bindAddress: bindAddress, certDir: certDir, uploadServerPort: img.UploadServerPort}
+}
+
+// Start starts the upload proxy server and blocks until the stop channel is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{Addr: s.bindAddress, Handler: s}
+	errCh := make(chan error, 1)
+	go func() {
+		if s.certDir == "" {
+			klog.Infof("Start upload proxy on %s", s.bindAddress)
+			errCh <- srv.ListenAndServe()
+			return
+		}
+		// The certificate is loaded for each handshake, so the certificate renewed by cert-manager is served without restart
+		srv.TLSConfig = &tls.Config{GetCertificate: s.getCertificate}
+		klog.Infof("Start upload proxy on %s with the certificate in %s", s.bindAddress, s.certDir)
+		errCh <- srv.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case <-stop:
+		return srv.Shutdown(context.Background())
+	case err := <-errCh:
+		return err
+	}
+}
+
+// ServeHTTP proxies the upload request of the VirtualMachineImage to its upload server pod
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		http.Error(w, "only POST and PUT are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name, ok := parseUploadPath(req.URL.Path)
+	if !ok {
+		http.Error(w, "upload path must be "+UploadPath+"{namespace}/{name}", http.StatusNotFound)
+		return
+	}
+	vmi := types.NamespacedName{Namespace: namespace, Name: name}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if err := s.validateToken(vmi, token); err != nil {
+		klog.Warningf("Reject upload for vmi %s: %s", vmi, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	uploadServer, err := s.getUploadServerURL(vmi)
+	if err != nil {
+		klog.Warningf("Upload server is not ready for vmi %s: %s", vmi, err.Error())
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	transport, err := s.newUploadServerTransport(vmi)
+	if err != nil {
+		klog.Warningf("Upload server cert is not ready for vmi %s: %s", vmi, err.Error())
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	klog.Infof("Start upload for vmi %s", vmi)
+	req.URL.Path = img.UploadServerPath
+	req.Header.Del("Authorization")
+	proxy := httputil.NewSingleHostReverseProxy(uploadServer)
+	proxy.Transport = transport
+	proxy.ServeHTTP(w, req)
+}
+
+func (s *Server) validateToken(vmi types.NamespacedName, token string) error {
+	if token == "" {
+		return errInvalidToken
+	}
+	secret := &corev1.Secret{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Namespace: vmi.Namespace, Name: img.GetUploadTokenSecretNameFromVmiName(vmi.Name)}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return errInvalidToken
+		}
+		return err
+	}
+	expiration, found := img.FindUploadTokenExpiration(secret, token)
+	if !found {
+		return errInvalidToken
+	} else if time.Now().After(expiration) {
+		return errExpiredToken
+	}
+	return nil
+}
+
+func (s *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(s.certDir, corev1.TLSCertKey), filepath.Join(s.certDir, corev1.TLSPrivateKeyKey))
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (s *Server) getUploadServerURL(vmi types.NamespacedName) (*url.URL, error) {
+	pod, err := img.GetImporterPod(s.client, vmi.Namespace, vmi.Name)
+	if err != nil {
+		return nil, err
+	} else if pod == nil {
+		return nil, goerrors.New("upload server pod does not exist")
+	}
+	if !img.IsUploadServerRunning(pod) {
+		return nil, goerrors.New("upload server pod is not running")
+	}
+	return &url.URL{Scheme: "https", Host: net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(s.uploadServerPort))}, nil
+}
+
+// newUploadServerTransport returns the transport which trusts only the server certificate of the upload server of the vmi and
+// sends the client certificate, because the upload server accepts only the client certificate
+func (s *Server) newUploadServerTransport(vmi types.NamespacedName) (*http.Transport, error) {
+	secret := &corev1.Secret{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Namespace: vmi.Namespace, Name: img.GetUploadServerCertSecretNameFromVmiName(vmi.Name)}, secret); err != nil {
+		return nil, err
+	}
+	clientCert, err := tls.X509KeyPair(secret.Data[img.UploadClientCertKey], secret.Data[img.UploadClientKeyKey])
+	if err != nil {
+		return nil, err
+	}
+	serverCAs := x509.NewCertPool()
+	if !serverCAs.AppendCertsFromPEM(secret.Data[corev1.TLSCertKey]) {
+		return nil, goerrors.New("upload server cert is invalid")
+	}
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      serverCAs,
+			ServerName:   img.UploadServerName,
+		},
+	}, nil
+}
+
+// parseUploadPath returns namespace and name from /v1alpha1/upload/{namespace}/{name}
+func parseUploadPath(path string) (namespace, name string, ok bool) {
+	if !strings.HasPrefix(path, UploadPath) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(path, UploadPath), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
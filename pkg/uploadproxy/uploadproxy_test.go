@@ -0,0 +1,247 @@
+package uploadproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	"kubevirt-image-service/pkg/util"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	testVmiName = "testvmi"
+	testVmiNs   = "default"
+	testToken   = "testtoken"
+	testImage   = "qcow2 image"
+)
+
+// 번호		token secret		token		upload server pod
+// 1		X
+// 2		O					invalid
+// 3		O(expired)			valid
+// 4		O					valid		X
+// 5		O					valid		Running
+// 6		O(refreshed)		previous	Running
+// 7		O					valid		Running(no cert)
+var _ = Describe("ServeHTTP", func() {
+	Context("1. with no token secret", func() {
+		s := createFakeServer()
+		rr := upload(s, testToken)
+
+		It("Should return unauthorized", func() {
+			Expect(rr.Code).Should(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("2. with invalid token", func() {
+		s := createFakeServer(newTokenSecret(time.Now().Add(time.Minute)))
+		rr := upload(s, "invalidtoken")
+
+		It("Should return unauthorized", func() {
+			Expect(rr.Code).Should(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("3. with expired token", func() {
+		s := createFakeServer(newTokenSecret(time.Now().Add(-time.Minute)))
+		rr := upload(s, testToken)
+
+		It("Should return unauthorized", func() {
+			Expect(rr.Code).Should(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("4. with valid token and no upload server pod", func() {
+		s := createFakeServer(newTokenSecret(time.Now().Add(time.Minute)))
+		rr := upload(s, testToken)
+
+		It("Should return service unavailable", func() {
+			Expect(rr.Code).Should(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Context("5. with valid token and running upload server pod", func() {
+		var uploadedReq *http.Request
+		var uploaded []byte
+		uploadServer, certSecret := newUploadServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			uploadedReq = req
+			uploaded, _ = ioutil.ReadAll(req.Body)
+		}))
+		defer uploadServer.Close()
+		host, port := splitHostPort(uploadServer.URL)
+
+		s := createFakeServer(newTokenSecret(time.Now().Add(time.Minute)), newUploadServerPod(host), certSecret)
+		s.uploadServerPort = port
+		rr := upload(s, testToken)
+
+		It("Should return ok", func() {
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+		})
+		It("Should stream the image to the upload server with the client certificate", func() {
+			Expect(uploadedReq.TLS.PeerCertificates[0].Subject.CommonName).Should(Equal(img.UploadClientName))
+			Expect(uploadedReq.URL.Path).Should(Equal(img.UploadServerPath))
+			Expect(string(uploaded)).Should(Equal(testImage))
+		})
+		It("Should not pass the upload token to the upload server", func() {
+			Expect(uploadedReq.Header.Get("Authorization")).Should(BeEmpty())
+		})
+	})
+
+	Context("6. with previous token of refreshed token secret and running upload server pod", func() {
+		uploadServer, certSecret := newUploadServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		defer uploadServer.Close()
+		host, port := splitHostPort(uploadServer.URL)
+
+		tokenSecret := newTokenSecret(time.Now().Add(img.UploadTokenTTL))
+		tokenSecret.Data[img.UploadTokenKey] = []byte("newtoken")
+		tokenSecret.Data[img.UploadPreviousTokenKey] = []byte(testToken)
+		tokenSecret.Data[img.UploadPreviousTokenExpirationKey] = []byte(time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+		s := createFakeServer(tokenSecret, newUploadServerPod(host), certSecret)
+		s.uploadServerPort = port
+		rr := upload(s, testToken)
+
+		It("Should return ok", func() {
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+		})
+	})
+
+	Context("7. with valid token and running upload server pod without upload server cert", func() {
+		s := createFakeServer(newTokenSecret(time.Now().Add(time.Minute)), newUploadServerPod("10.0.0.1"))
+		rr := upload(s, testToken)
+
+		It("Should return service unavailable", func() {
+			Expect(rr.Code).Should(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
+
+var _ = Describe("parseUploadPath", func() {
+	It("Should parse namespace and name", func() {
+		ns, name, ok := parseUploadPath(UploadPath + "default/myvmim")
+		Expect(ok).Should(BeTrue())
+		Expect(ns).Should(Equal("default"))
+		Expect(name).Should(Equal("myvmim"))
+	})
+	It("Should reject invalid path", func() {
+		for _, path := range []string{"/upload/default/myvmim", UploadPath + "default", UploadPath + "default/", UploadPath + "a/b/c"} {
+			_, _, ok := parseUploadPath(path)
+			Expect(ok).Should(BeFalse())
+		}
+	})
+})
+
+func createFakeServer(objects ...runtime.Object) *Server {
+	client, _, err := util.CreateFakeClientAndScheme(objects...)
+	if err != nil {
+		panic(err)
+	}
+	return New(client, ":0", "")
+}
+
+func upload(s *Server, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, UploadPath+testVmiNs+"/"+testVmiName, strings.NewReader(testImage))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	return rr
+}
+
+func newTokenSecret(expiration time.Time) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      img.GetUploadTokenSecretNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Data: map[string][]byte{
+			img.UploadTokenKey:           []byte(testToken),
+			img.UploadTokenExpirationKey: []byte(expiration.UTC().Format(time.RFC3339)),
+		},
+	}
+}
+
+// newUploadServer returns the upload server which serves https and accepts only the client certificate, as the cdi upload server does,
+// and the upload server cert secret of its certificates
+func newUploadServer(handler http.Handler) (*httptest.Server, *corev1.Secret) {
+	serverCert, serverKey, err := util.NewSelfSignedCert(img.UploadServerName, []string{img.UploadServerName}, x509.ExtKeyUsageServerAuth, time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	clientCert, clientKey, err := util.NewSelfSignedCert(img.UploadClientName, nil, x509.ExtKeyUsageClientAuth, time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	serverKeyPair, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		panic(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCert)
+
+	uploadServer := httptest.NewUnstartedServer(handler)
+	uploadServer.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	uploadServer.StartTLS()
+	return uploadServer, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      img.GetUploadServerCertSecretNameFromVmiName(testVmiName),
+			Namespace: testVmiNs,
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       serverCert,
+			corev1.TLSPrivateKeyKey: serverKey,
+			img.UploadClientCertKey: clientCert,
+			img.UploadClientKeyKey:  clientKey,
+		},
+	}
+}
+
+func newUploadServerPod(podIP string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      img.GetImporterJobNameFromVmiName(testVmiName) + "-abcde",
+			Namespace: testVmiNs,
+			Labels:    map[string]string{util.JobNameLabel: img.GetImporterJobNameFromVmiName(testVmiName)},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			PodIP: podIP,
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  img.FetcherContainerName,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+}
+
+func splitHostPort(rawURL string) (string, int) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		panic(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		panic(err)
+	}
+	return host, p
+}
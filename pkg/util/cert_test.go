@@ -0,0 +1,31 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("NewSelfSignedCert", func() {
+	certPEM, keyPEM, err := NewSelfSignedCert("upload-server", []string{"upload-server"}, x509.ExtKeyUsageServerAuth, time.Hour)
+
+	It("Should return no error", func() {
+		Expect(err).Should(BeNil())
+	})
+	It("Should return the key pair", func() {
+		_, err := tls.X509KeyPair(certPEM, keyPEM)
+		Expect(err).Should(BeNil())
+	})
+	It("Should be verified by itself", func() {
+		block, _ := pem.Decode(certPEM)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).Should(BeNil())
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		_, err = cert.Verify(x509.VerifyOptions{DNSName: "upload-server", Roots: roots})
+		Expect(err).Should(BeNil())
+	})
+})
@@ -16,23 +16,57 @@ func GetConditionByType(conditions []v1alpha1.Condition, conditionType string) (
 	return false, v1alpha1.Condition{}
 }
 
-// SetConditionByType sets condition to conditions. If there is a matching condition.Type, update it, if not, add it. It Returns the new slice
-func SetConditionByType(conditions []v1alpha1.Condition, conditionType string, status corev1.ConditionStatus, reason, message string) []v1alpha1.Condition {
+// SetConditionByType sets condition to conditions. If there is a matching condition.Type, update it, if not, add it. It Returns the new slice.
+// The generation is the .metadata.generation which the condition is based on. LastTransitionTime is changed only when the status is changed
+func SetConditionByType(conditions []v1alpha1.Condition, conditionType string, status corev1.ConditionStatus, reason, message string,
+	generation int64) []v1alpha1.Condition {
 	for i := range conditions {
 		if conditions[i].Type != conditionType {
 			continue
 		}
+		if conditions[i].Status != status {
+			conditions[i].LastTransitionTime = metav1.Now()
+		}
 		conditions[i].Status = status
 		conditions[i].Reason = reason
 		conditions[i].Message = message
-		conditions[i].LastTransitionTime = metav1.Now()
+		conditions[i].ObservedGeneration = generation
 		return conditions
 	}
 	return append(conditions, v1alpha1.Condition{
 		Type:               conditionType,
 		Status:             status,
+		ObservedGeneration: generation,
 		LastTransitionTime: metav1.Now(),
 		Reason:             reason,
 		Message:            message,
 	})
 }
+
+// SetStateConditions sets Progressing and Degraded conditions by the state of the resource. The resource is progressing while it is created
+// or pending, and degraded when it failed. The reason and the message of ReadyToUse condition tell why
+func SetStateConditions(conditions []v1alpha1.Condition, progressing, degraded bool, reason, message string, generation int64) []v1alpha1.Condition {
+	if progressing {
+		conditions = SetConditionByType(conditions, v1alpha1.ConditionProgressing, corev1.ConditionTrue, reason, message, generation)
+	} else {
+		conditions = SetConditionByType(conditions, v1alpha1.ConditionProgressing, corev1.ConditionFalse, v1alpha1.ReasonAsExpected, "", generation)
+	}
+	if degraded {
+		return SetConditionByType(conditions, v1alpha1.ConditionDegraded, corev1.ConditionTrue, reason, message, generation)
+	}
+	return SetConditionByType(conditions, v1alpha1.ConditionDegraded, corev1.ConditionFalse, v1alpha1.ReasonAsExpected, "", generation)
+}
+
+// SetPvcBoundCondition sets PvcBound condition by the phase of the pvc, which is nil if it doesn't exist
+func SetPvcBoundCondition(conditions []v1alpha1.Condition, pvc *corev1.PersistentVolumeClaim, generation int64) []v1alpha1.Condition {
+	switch {
+	case pvc == nil:
+		return SetConditionByType(conditions, v1alpha1.ConditionPvcBound, corev1.ConditionFalse, v1alpha1.ReasonPvcNotFound, "Pvc is not created", generation)
+	case pvc.Status.Phase == corev1.ClaimBound:
+		return SetConditionByType(conditions, v1alpha1.ConditionPvcBound, corev1.ConditionTrue, v1alpha1.ReasonPvcBound, "Pvc "+pvc.Name+" is bound", generation)
+	case pvc.Status.Phase == corev1.ClaimLost:
+		return SetConditionByType(conditions, v1alpha1.ConditionPvcBound, corev1.ConditionFalse, v1alpha1.ReasonPvcLost, "Volume of pvc "+pvc.Name+" is lost", generation)
+	default:
+		return SetConditionByType(conditions, v1alpha1.ConditionPvcBound, corev1.ConditionFalse, v1alpha1.ReasonPvcPending, "Pvc "+pvc.Name+" is pending", generation)
+	}
+}
@@ -98,13 +98,15 @@ var _ = Describe("SetConditionByType", func() {
 				Message: "Message2",
 			},
 		}
-		conditionsAfterSet := SetConditionByType(conditions, "type3", corev1.ConditionFalse, "TestReason3", "Message3")
+		conditionsAfterSet := SetConditionByType(conditions, "type3", corev1.ConditionFalse, "TestReason3", "Message3", 34)
 
 		It("should append it", func() {
 			Expect(conditionsAfterSet[2].Type).Should(Equal("type3"))
 			Expect(conditionsAfterSet[2].Status).Should(Equal(corev1.ConditionFalse))
 			Expect(conditionsAfterSet[2].Reason).Should(Equal("TestReason3"))
 			Expect(conditionsAfterSet[2].Message).Should(Equal("Message3"))
+			Expect(conditionsAfterSet[2].ObservedGeneration).Should(Equal(int64(34)))
+			Expect(conditionsAfterSet[2].LastTransitionTime.IsZero()).Should(BeFalse())
 		})
 
 		It("should not change or delete other conditions", func() {
@@ -137,13 +139,18 @@ var _ = Describe("SetConditionByType", func() {
 				Message: "Message2",
 			},
 		}
-		conditionsAfterSet := SetConditionByType(conditions, "type2", corev1.ConditionTrue, "TestReasonNew", "MessageNew")
+		conditionsAfterSet := SetConditionByType(conditions, "type2", corev1.ConditionTrue, "TestReasonNew", "MessageNew", 34)
 
 		It("should update it", func() {
 			Expect(conditionsAfterSet[1].Type).Should(Equal("type2"))
 			Expect(conditionsAfterSet[1].Status).Should(Equal(corev1.ConditionTrue))
 			Expect(conditionsAfterSet[1].Reason).Should(Equal("TestReasonNew"))
 			Expect(conditionsAfterSet[1].Message).Should(Equal("MessageNew"))
+			Expect(conditionsAfterSet[1].ObservedGeneration).Should(Equal(int64(34)))
+		})
+
+		It("should change lastTransitionTime as the status is changed", func() {
+			Expect(conditionsAfterSet[1].LastTransitionTime.IsZero()).Should(BeFalse())
 		})
 
 		It("should not change or delete other conditions", func() {
@@ -151,4 +158,123 @@ var _ = Describe("SetConditionByType", func() {
 			Expect(conditionsAfterSet[0]).Should(Equal(conditions[0]))
 		})
 	})
+
+	Context("if conditions has matching conditionType with the same status", func() {
+		conditions := []v1alpha1.Condition{
+			{
+				Type:               "type1",
+				Status:             corev1.ConditionTrue,
+				ObservedGeneration: 32,
+				LastTransitionTime: v1.Time{
+					Time: time.Time{},
+				},
+				Reason:  "TestReason",
+				Message: "Message",
+			},
+		}
+		conditionsAfterSet := SetConditionByType(conditions, "type1", corev1.ConditionTrue, "TestReasonNew", "MessageNew", 34)
+
+		It("should update the reason, the message and observedGeneration", func() {
+			Expect(conditionsAfterSet[0].Reason).Should(Equal("TestReasonNew"))
+			Expect(conditionsAfterSet[0].Message).Should(Equal("MessageNew"))
+			Expect(conditionsAfterSet[0].ObservedGeneration).Should(Equal(int64(34)))
+		})
+
+		It("should not change lastTransitionTime", func() {
+			Expect(conditionsAfterSet[0].LastTransitionTime.IsZero()).Should(BeTrue())
+		})
+	})
 })
+
+var _ = Describe("SetStateConditions", func() {
+	Context("if the resource is progressing", func() {
+		conditions := SetStateConditions(nil, true, false, "Creating", "Resource is in creating", 3)
+
+		It("should set Progressing true with the reason", func() {
+			found, cond := GetConditionByType(conditions, v1alpha1.ConditionProgressing)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Status).Should(Equal(corev1.ConditionTrue))
+			Expect(cond.Reason).Should(Equal("Creating"))
+			Expect(cond.ObservedGeneration).Should(Equal(int64(3)))
+		})
+
+		It("should set Degraded false", func() {
+			found, cond := GetConditionByType(conditions, v1alpha1.ConditionDegraded)
+			Expect(found).Should(BeTrue())
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonAsExpected))
+		})
+	})
+
+	Context("if the resource is degraded", func() {
+		conditions := SetStateConditions(nil, true, false, "Creating", "Resource is in creating", 3)
+		conditions = SetStateConditions(conditions, false, true, "ImportFailed", "Import failed", 3)
+
+		It("should set Progressing false", func() {
+			_, cond := GetConditionByType(conditions, v1alpha1.ConditionProgressing)
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonAsExpected))
+		})
+
+		It("should set Degraded true with the reason and the message", func() {
+			_, cond := GetConditionByType(conditions, v1alpha1.ConditionDegraded)
+			Expect(cond.Status).Should(Equal(corev1.ConditionTrue))
+			Expect(cond.Reason).Should(Equal("ImportFailed"))
+			Expect(cond.Message).Should(Equal("Import failed"))
+		})
+	})
+})
+
+var _ = Describe("SetPvcBoundCondition", func() {
+	Context("1. with no pvc", func() {
+		_, cond := GetConditionByType(SetPvcBoundCondition(nil, nil, 5), v1alpha1.ConditionPvcBound)
+
+		It("should set PvcBound by the phase", func() {
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonPvcNotFound))
+			Expect(cond.ObservedGeneration).Should(Equal(int64(5)))
+		})
+	})
+
+	Context("2. with pending pvc", func() {
+		_, cond := GetConditionByType(SetPvcBoundCondition(nil, newTestPvc(corev1.ClaimPending), 5), v1alpha1.ConditionPvcBound)
+
+		It("should set PvcBound by the phase", func() {
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonPvcPending))
+			Expect(cond.ObservedGeneration).Should(Equal(int64(5)))
+		})
+	})
+
+	Context("3. with bound pvc", func() {
+		_, cond := GetConditionByType(SetPvcBoundCondition(nil, newTestPvc(corev1.ClaimBound), 5), v1alpha1.ConditionPvcBound)
+
+		It("should set PvcBound by the phase", func() {
+			Expect(cond.Status).Should(Equal(corev1.ConditionTrue))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonPvcBound))
+			Expect(cond.ObservedGeneration).Should(Equal(int64(5)))
+		})
+	})
+
+	Context("4. with lost pvc", func() {
+		_, cond := GetConditionByType(SetPvcBoundCondition(nil, newTestPvc(corev1.ClaimLost), 5), v1alpha1.ConditionPvcBound)
+
+		It("should set PvcBound by the phase", func() {
+			Expect(cond.Status).Should(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).Should(Equal(v1alpha1.ReasonPvcLost))
+			Expect(cond.ObservedGeneration).Should(Equal(int64(5)))
+		})
+	})
+})
+
+func newTestPvc(phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "testpvc",
+			Namespace: "default",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: phase,
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"context"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+)
+
+// GetConfig returns the spec of the KubevirtImageServiceConfig named "default". It returns the empty spec if the config or its CRD doesn't exist,
+// so the built-in values are used
+func GetConfig(c client.Client) (v1alpha1.KubevirtImageServiceConfigSpec, error) {
+	config := &v1alpha1.KubevirtImageServiceConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: v1alpha1.KubevirtImageServiceConfigName}, config); err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return v1alpha1.KubevirtImageServiceConfigSpec{}, nil
+		}
+		return v1alpha1.KubevirtImageServiceConfigSpec{}, err
+	}
+	return config.Spec, nil
+}
+
+// DefaultOperatorNamespace is the namespace of the operator in deploy/operator.yaml, which is used if the operator runs out of the cluster
+const DefaultOperatorNamespace = "kis"
+
+// GetClusterImageNamespace returns the namespace where ClusterVirtualMachineImages are imported, which is set in the config
+// or the namespace of the operator
+func GetClusterImageNamespace(config v1alpha1.KubevirtImageServiceConfigSpec) string {
+	if config.ClusterImageNamespace != "" {
+		return config.ClusterImageNamespace
+	}
+	if namespace, err := k8sutil.GetOperatorNamespace(); err == nil {
+		return namespace
+	}
+	return DefaultOperatorNamespace
+}
+
+// GetImageOrDefault returns image if it is set in the config, otherwise defaultImage
+func GetImageOrDefault(image, defaultImage string) string {
+	if image == "" {
+		return defaultImage
+	}
+	return image
+}
+
+// GetLogVerbosityOrDefault returns the log verbosity of the config if it is set, otherwise defaultVerbosity
+func GetLogVerbosityOrDefault(config v1alpha1.KubevirtImageServiceConfigSpec, defaultVerbosity string) string {
+	if config.LogVerbosity == nil {
+		return defaultVerbosity
+	}
+	return strconv.Itoa(int(*config.LogVerbosity))
+}
+
+// ApplyConfigToPod sets the image pull policy, the image pull secrets and the resources of the config to the worker pod.
+// The fields which are not set in the config are not changed
+func ApplyConfigToPod(pod *corev1.Pod, config v1alpha1.KubevirtImageServiceConfigSpec) {
+	pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, config.ImagePullSecrets...)
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if config.ImagePullPolicy != "" {
+				containers[i].ImagePullPolicy = config.ImagePullPolicy
+			}
+			if config.Resources != nil {
+				containers[i].Resources = *config.Resources.DeepCopy()
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+var _ = Describe("GetConfig", func() {
+	Context("if the config doesn't exist", func() {
+		c, _, _ := CreateFakeClientAndScheme()
+		config, err := GetConfig(c)
+
+		It("Should return the empty config", func() {
+			Expect(err).Should(BeNil())
+			Expect(config).Should(Equal(v1alpha1.KubevirtImageServiceConfigSpec{}))
+		})
+	})
+
+	Context("if the default config exists", func() {
+		c, _, _ := CreateFakeClientAndScheme(&v1alpha1.KubevirtImageServiceConfig{
+			ObjectMeta: v1.ObjectMeta{Name: v1alpha1.KubevirtImageServiceConfigName},
+			Spec: v1alpha1.KubevirtImageServiceConfigSpec{
+				Images: v1alpha1.KubevirtImageServiceConfigImages{Importer: "registry.local/cdi-importer:v1.13.0"},
+			},
+		})
+		config, err := GetConfig(c)
+
+		It("Should return the spec of the config", func() {
+			Expect(err).Should(BeNil())
+			Expect(config.Images.Importer).Should(Equal("registry.local/cdi-importer:v1.13.0"))
+		})
+	})
+})
+
+var _ = Describe("GetClusterImageNamespace", func() {
+	It("Should return the namespace of the config", func() {
+		Expect(GetClusterImageNamespace(v1alpha1.KubevirtImageServiceConfigSpec{ClusterImageNamespace: "images"})).Should(Equal("images"))
+	})
+	It("Should return the default namespace out of the cluster", func() {
+		Expect(GetClusterImageNamespace(v1alpha1.KubevirtImageServiceConfigSpec{})).Should(Equal(DefaultOperatorNamespace))
+	})
+})
+
+var _ = Describe("GetImageOrDefault", func() {
+	It("Should return the default image if the image is not set", func() {
+		Expect(GetImageOrDefault("", "busybox")).Should(Equal("busybox"))
+	})
+	It("Should return the image if it is set", func() {
+		Expect(GetImageOrDefault("registry.local/busybox", "busybox")).Should(Equal("registry.local/busybox"))
+	})
+})
+
+var _ = Describe("GetLogVerbosityOrDefault", func() {
+	It("Should return the default verbosity if it is not set", func() {
+		Expect(GetLogVerbosityOrDefault(v1alpha1.KubevirtImageServiceConfigSpec{}, "1")).Should(Equal("1"))
+	})
+	It("Should return the verbosity of the config", func() {
+		verbosity := int32(3)
+		Expect(GetLogVerbosityOrDefault(v1alpha1.KubevirtImageServiceConfigSpec{LogVerbosity: &verbosity}, "1")).Should(Equal("3"))
+	})
+})
+
+var _ = Describe("ApplyConfigToPod", func() {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", ImagePullPolicy: corev1.PullIfNotPresent}},
+				Containers:     []corev1.Container{{Name: "main", ImagePullPolicy: corev1.PullIfNotPresent}},
+			},
+		}
+	}
+
+	Context("with the empty config", func() {
+		pod := newPod()
+		ApplyConfigToPod(pod, v1alpha1.KubevirtImageServiceConfigSpec{})
+
+		It("Should not change the pod", func() {
+			Expect(pod).Should(Equal(newPod()))
+		})
+	})
+
+	Context("with the config", func() {
+		resources := corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		}
+		pod := newPod()
+		ApplyConfigToPod(pod, v1alpha1.KubevirtImageServiceConfigSpec{
+			ImagePullPolicy:  corev1.PullAlways,
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "mirror-secret"}},
+			Resources:        &resources,
+		})
+
+		It("Should set the image pull secrets", func() {
+			Expect(pod.Spec.ImagePullSecrets).Should(Equal([]corev1.LocalObjectReference{{Name: "mirror-secret"}}))
+		})
+		It("Should set the image pull policy and the resources of all containers", func() {
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				Expect(container.ImagePullPolicy).Should(Equal(corev1.PullAlways))
+				Expect(container.Resources).Should(Equal(resources))
+			}
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package util
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+// Reasons of the events of the objects which the controllers create and delete for the resources. The events of the state transitions
+// have the reason of ReadyToUse condition
+const (
+	EventPvcCreated      = "PvcCreated"
+	EventPvcDeleted      = "PvcDeleted"
+	EventSnapshotCreated = "SnapshotCreated"
+	EventSnapshotReady   = "SnapshotReady"
+	EventSnapshotDeleted = "SnapshotDeleted"
+	EventJobStarted      = "JobStarted"
+	EventJobCompleted    = "JobCompleted"
+	EventJobDeleted      = "JobDeleted"
+	EventPodFailed       = "PodFailed"
+)
+
+// RecordStateEvent records the event of the state transition with the reason and the message of ReadyToUse condition, before the condition
+// is set. The event is recorded only when the condition is changed, and it is a warning if the resource failed
+func RecordStateEvent(recorder record.EventRecorder, obj runtime.Object, conditions []v1alpha1.Condition, status corev1.ConditionStatus,
+	reason, message string, failed bool) {
+	if found, cond := GetConditionByType(conditions, v1alpha1.ConditionReadyToUse); found &&
+		cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if failed {
+		eventType = corev1.EventTypeWarning
+	}
+	recorder.Event(obj, eventType, reason, message)
+}
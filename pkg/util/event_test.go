@@ -0,0 +1,61 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+)
+
+var _ = Describe("RecordStateEvent", func() {
+	// 번호 ReadyToUse condition          상태 변경   실패   기록되는 이벤트
+	// 1    없음                            -           false  Normal
+	// 2    같은 status, reason, message    없음        false  없음
+	// 3    다른 message                    있음        false  Normal
+	// 4    다른 reason                     있음        true   Warning
+	existing := []v1alpha1.Condition{
+		{
+			Type:    v1alpha1.ConditionReadyToUse,
+			Status:  corev1.ConditionFalse,
+			Reason:  v1alpha1.ReasonCreating,
+			Message: "Image pvc is being created",
+		},
+	}
+
+	Context("1. if ReadyToUse condition does not exist", func() {
+		recorder := record.NewFakeRecorder(1)
+		RecordStateEvent(recorder, &v1alpha1.VirtualMachineImage{}, nil, corev1.ConditionFalse, v1alpha1.ReasonCreating, "Image pvc is being created", false)
+
+		It("Should record the normal event", func() {
+			Expect(recorder.Events).Should(Receive(Equal("Normal Creating Image pvc is being created")))
+		})
+	})
+
+	Context("2. if ReadyToUse condition is not changed", func() {
+		recorder := record.NewFakeRecorder(1)
+		RecordStateEvent(recorder, &v1alpha1.VirtualMachineImage{}, existing, corev1.ConditionFalse, v1alpha1.ReasonCreating, "Image pvc is being created", false)
+
+		It("Should not record any event", func() {
+			Expect(recorder.Events).ShouldNot(Receive())
+		})
+	})
+
+	Context("3. if the message of ReadyToUse condition is changed", func() {
+		recorder := record.NewFakeRecorder(1)
+		RecordStateEvent(recorder, &v1alpha1.VirtualMachineImage{}, existing, corev1.ConditionFalse, v1alpha1.ReasonCreating, "Importer job is running", false)
+
+		It("Should record the normal event", func() {
+			Expect(recorder.Events).Should(Receive(Equal("Normal Creating Importer job is running")))
+		})
+	})
+
+	Context("4. if the resource failed", func() {
+		recorder := record.NewFakeRecorder(1)
+		RecordStateEvent(recorder, &v1alpha1.VirtualMachineImage{}, existing, corev1.ConditionFalse, v1alpha1.ReasonFailed, "Importer job failed", true)
+
+		It("Should record the warning event", func() {
+			Expect(recorder.Events).Should(Receive(Equal("Warning Failed Importer job failed")))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+)
+
+const (
+	// JobNameLabel is the label of the job pods which indicates the name of the job. The job controller sets the same label
+	JobNameLabel = "job-name"
+	// DefaultJobActiveDeadlineSeconds is the active deadline of the worker job if it is not set in the config
+	DefaultJobActiveDeadlineSeconds = 24 * 60 * 60
+	// DefaultJobTTLSecondsAfterFinished is the ttl of the finished worker job if it is not set in the config
+	DefaultJobTTLSecondsAfterFinished = 60 * 60
+)
+
+// NewJob returns the job which runs the pod until it succeeds or fails backoffLimit+1 times. The job has the name, the namespace and
+// the labels of the pod, and the active deadline and the ttl of the config. The restart policy of the pod must be Never or OnFailure
+func NewJob(pod *corev1.Pod, backoffLimit int32, config v1alpha1.KubevirtImageServiceConfigSpec) *batchv1.Job {
+	activeDeadlineSeconds := int64(DefaultJobActiveDeadlineSeconds)
+	if config.JobActiveDeadlineSeconds != nil {
+		activeDeadlineSeconds = *config.JobActiveDeadlineSeconds
+	}
+	ttlSecondsAfterFinished := int32(DefaultJobTTLSecondsAfterFinished)
+	if config.JobTTLSecondsAfterFinished != nil {
+		ttlSecondsAfterFinished = *config.JobTTLSecondsAfterFinished
+	}
+
+	template := corev1.PodTemplateSpec{ObjectMeta: *pod.ObjectMeta.DeepCopy(), Spec: *pod.Spec.DeepCopy()}
+	template.Name = ""
+	template.Namespace = ""
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels[JobNameLabel] = pod.Name
+	return &batchv1.Job{
+		ObjectMeta: *pod.ObjectMeta.DeepCopy(),
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadlineSeconds,
+			TTLSecondsAfterFinished: &ttlSecondsAfterFinished,
+			Template:                template,
+		},
+	}
+}
+
+// GetJobCondition returns the condition of the type if it is true, otherwise nil
+func GetJobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if job.Status.Conditions[i].Type == conditionType && job.Status.Conditions[i].Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsJobCompleted returns true if the job succeeded
+func IsJobCompleted(job *batchv1.Job) bool {
+	return GetJobCondition(job, batchv1.JobComplete) != nil
+}
+
+// IsJobFinished returns true if the job succeeded or failed
+func IsJobFinished(job *batchv1.Job) bool {
+	return IsJobCompleted(job) || GetJobCondition(job, batchv1.JobFailed) != nil
+}
+
+// ListJobPods returns the pods of the job in the order of the creation
+func ListJobPods(c client.Reader, namespace, jobName string) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), pods, client.InNamespace(namespace), client.MatchingLabels{JobNameLabel: jobName}); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+	return pods.Items, nil
+}
+
+// GetActiveJobPod returns the newest pod of the job which is not terminated, or nil if there is no such pod
+func GetActiveJobPod(c client.Reader, namespace, jobName string) (*corev1.Pod, error) {
+	pods, err := ListJobPods(c, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(pods) - 1; i >= 0; i-- {
+		if pods[i].Status.Phase != corev1.PodSucceeded && pods[i].Status.Phase != corev1.PodFailed && pods[i].DeletionTimestamp == nil {
+			return &pods[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteJob deletes the job with its pods
+func DeleteJob(c client.Client, job *batchv1.Job) error {
+	return c.Delete(context.TODO(), job, client.PropagationPolicy("Background"))
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"time"
+)
+
+var _ = Describe("NewJob", func() {
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "testvmi-image-importer", Namespace: "default", Labels: map[string]string{"app": "testvmi"}},
+		Spec:       corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever},
+	}
+
+	Context("with the empty config", func() {
+		job := NewJob(pod, 3, v1alpha1.KubevirtImageServiceConfigSpec{})
+
+		It("Should have the name, the namespace and the labels of the pod", func() {
+			Expect(job.Name).Should(Equal(pod.Name))
+			Expect(job.Namespace).Should(Equal(pod.Namespace))
+			Expect(job.Labels).Should(Equal(pod.Labels))
+		})
+		It("Should have the pod template with the job name label", func() {
+			Expect(job.Spec.Template.Name).Should(BeEmpty())
+			Expect(job.Spec.Template.Labels).Should(HaveKeyWithValue("app", "testvmi"))
+			Expect(job.Spec.Template.Labels).Should(HaveKeyWithValue(JobNameLabel, pod.Name))
+			Expect(job.Spec.Template.Spec.RestartPolicy).Should(Equal(corev1.RestartPolicyNever))
+			Expect(pod.Labels).ShouldNot(HaveKey(JobNameLabel))
+		})
+		It("Should have the backoff limit and the defaults", func() {
+			Expect(*job.Spec.BackoffLimit).Should(Equal(int32(3)))
+			Expect(*job.Spec.ActiveDeadlineSeconds).Should(Equal(int64(DefaultJobActiveDeadlineSeconds)))
+			Expect(*job.Spec.TTLSecondsAfterFinished).Should(Equal(int32(DefaultJobTTLSecondsAfterFinished)))
+		})
+	})
+
+	Context("with the deadline and the ttl in the config", func() {
+		job := NewJob(pod, 0, v1alpha1.KubevirtImageServiceConfigSpec{
+			JobActiveDeadlineSeconds:   &[]int64{600}[0],
+			JobTTLSecondsAfterFinished: &[]int32{0}[0],
+		})
+
+		It("Should have the deadline and the ttl of the config", func() {
+			Expect(*job.Spec.ActiveDeadlineSeconds).Should(Equal(int64(600)))
+			Expect(*job.Spec.TTLSecondsAfterFinished).Should(BeZero())
+		})
+	})
+})
+
+var _ = Describe("IsJobFinished", func() {
+	It("Should return false if the job is running", func() {
+		Expect(IsJobFinished(&batchv1.Job{})).Should(BeFalse())
+	})
+	It("Should return true if the job failed", func() {
+		job := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}}}
+		Expect(IsJobFinished(job)).Should(BeTrue())
+		Expect(IsJobCompleted(job)).Should(BeFalse())
+	})
+})
+
+var _ = Describe("GetActiveJobPod", func() {
+	newPod := func(name string, phase corev1.PodPhase, created time.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{JobNameLabel: "testjob"}, CreationTimestamp: v1.NewTime(created)},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	Context("with the failed pod and the running pod", func() {
+		now := time.Now()
+		c, _, _ := CreateFakeClientAndScheme(newPod("testjob-2", corev1.PodRunning, now), newPod("testjob-1", corev1.PodFailed, now.Add(-time.Minute)))
+		pod, err := GetActiveJobPod(c, "default", "testjob")
+
+		It("Should return the running pod", func() {
+			Expect(err).Should(BeNil())
+			Expect(pod.Name).Should(Equal("testjob-2"))
+		})
+	})
+
+	Context("with the failed pod", func() {
+		c, _, _ := CreateFakeClientAndScheme(newPod("testjob-1", corev1.PodFailed, time.Now()))
+		pod, err := GetActiveJobPod(c, "default", "testjob")
+
+		It("Should return nil", func() {
+			Expect(err).Should(BeNil())
+			Expect(pod).Should(BeNil())
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultStorageClassAnnotation is the annotation of the default StorageClass of the cluster
+	DefaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// BetaDefaultStorageClassAnnotation is the beta annotation of the default StorageClass, which old clusters still use
+	BetaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+	// DefaultSnapshotClassAnnotation is the annotation of the default VolumeSnapshotClass of the driver
+	DefaultSnapshotClassAnnotation = "snapshot.storage.kubernetes.io/is-default-class"
+)
+
+// GetDefaultStorageClassName returns the name of the default StorageClass of the cluster. It returns false if there is no default StorageClass
+func GetDefaultStorageClassName(c client.Client) (string, bool, error) {
+	storageClasses := &storagev1.StorageClassList{}
+	if err := c.List(context.TODO(), storageClasses); err != nil {
+		return "", false, err
+	}
+	for _, storageClass := range storageClasses.Items {
+		if storageClass.Annotations[DefaultStorageClassAnnotation] == "true" || storageClass.Annotations[BetaDefaultStorageClassAnnotation] == "true" {
+			return storageClass.Name, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// GetSnapshotClassNameOfStorageClass returns the name of the VolumeSnapshotClass whose driver is the provisioner of the StorageClass.
+// The default VolumeSnapshotClass is preferred if there are several. It returns false if there is no VolumeSnapshotClass of the driver
+func GetSnapshotClassNameOfStorageClass(c client.Client, storageClassName string) (string, bool, error) {
+	storageClass := &storagev1.StorageClass{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: storageClassName}, storageClass); err != nil {
+		return "", false, err
+	}
+	snapshotClasses := &snapshotv1beta1.VolumeSnapshotClassList{}
+	if err := c.List(context.TODO(), snapshotClasses); err != nil {
+		return "", false, err
+	}
+	snapshotClassName, found := "", false
+	for _, snapshotClass := range snapshotClasses.Items {
+		if snapshotClass.Driver != storageClass.Provisioner {
+			continue
+		}
+		if snapshotClass.Annotations[DefaultSnapshotClassAnnotation] == "true" {
+			return snapshotClass.Name, true, nil
+		}
+		if !found {
+			snapshotClassName, found = snapshotClass.Name, true
+		}
+	}
+	return snapshotClassName, found, nil
+}
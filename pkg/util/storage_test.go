@@ -0,0 +1,85 @@
+package util
+
+import (
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("GetDefaultStorageClassName", func() {
+	Context("if there is no default StorageClass", func() {
+		c, _, _ := CreateFakeClientAndScheme(newTestStorageClass("rook-ceph-block", "rook-ceph.rbd.csi.ceph.com", nil))
+		_, found, err := GetDefaultStorageClassName(c)
+
+		It("Should not find the default StorageClass", func() {
+			Expect(err).Should(BeNil())
+			Expect(found).Should(BeFalse())
+		})
+	})
+
+	Context("if the StorageClass has the beta default annotation", func() {
+		c, _, _ := CreateFakeClientAndScheme(newTestStorageClass("rook-ceph-block", "rook-ceph.rbd.csi.ceph.com", nil),
+			newTestStorageClass("standard", "hostpath.csi.k8s.io", map[string]string{BetaDefaultStorageClassAnnotation: "true"}))
+		name, found, err := GetDefaultStorageClassName(c)
+
+		It("Should return the default StorageClass", func() {
+			Expect(err).Should(BeNil())
+			Expect(found).Should(BeTrue())
+			Expect(name).Should(Equal("standard"))
+		})
+	})
+})
+
+var _ = Describe("GetSnapshotClassNameOfStorageClass", func() {
+	Context("if the StorageClass doesn't exist", func() {
+		c, _, _ := CreateFakeClientAndScheme()
+		_, _, err := GetSnapshotClassNameOfStorageClass(c, "rook-ceph-block")
+
+		It("Should return not found error", func() {
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("if there is no VolumeSnapshotClass of the provisioner", func() {
+		c, _, _ := CreateFakeClientAndScheme(newTestStorageClass("rook-ceph-block", "rook-ceph.rbd.csi.ceph.com", nil),
+			newTestSnapshotClass("csi-hostpath-snapclass", "hostpath.csi.k8s.io", nil))
+		_, found, err := GetSnapshotClassNameOfStorageClass(c, "rook-ceph-block")
+
+		It("Should not find the VolumeSnapshotClass", func() {
+			Expect(err).Should(BeNil())
+			Expect(found).Should(BeFalse())
+		})
+	})
+
+	Context("if there are VolumeSnapshotClasses of the provisioner", func() {
+		c, _, _ := CreateFakeClientAndScheme(newTestStorageClass("rook-ceph-block", "rook-ceph.rbd.csi.ceph.com", nil),
+			newTestSnapshotClass("csi-hostpath-snapclass", "hostpath.csi.k8s.io", map[string]string{DefaultSnapshotClassAnnotation: "true"}),
+			newTestSnapshotClass("csi-rbdplugin-a-snapclass", "rook-ceph.rbd.csi.ceph.com", nil),
+			newTestSnapshotClass("csi-rbdplugin-snapclass", "rook-ceph.rbd.csi.ceph.com", map[string]string{DefaultSnapshotClassAnnotation: "true"}))
+		name, found, err := GetSnapshotClassNameOfStorageClass(c, "rook-ceph-block")
+
+		It("Should return the default VolumeSnapshotClass of the provisioner", func() {
+			Expect(err).Should(BeNil())
+			Expect(found).Should(BeTrue())
+			Expect(name).Should(Equal("csi-rbdplugin-snapclass"))
+		})
+	})
+})
+
+func newTestStorageClass(name, provisioner string, annotations map[string]string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta:  v1.ObjectMeta{Name: name, Annotations: annotations},
+		Provisioner: provisioner,
+	}
+}
+
+func newTestSnapshotClass(name, driver string, annotations map[string]string) *snapshotv1beta1.VolumeSnapshotClass {
+	return &snapshotv1beta1.VolumeSnapshotClass{
+		ObjectMeta:     v1.ObjectMeta{Name: name, Annotations: annotations},
+		Driver:         driver,
+		DeletionPolicy: snapshotv1beta1.VolumeSnapshotContentDelete,
+	}
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DiskImageName is the file name of the disk image in the Filesystem-mode pvc, which KubeVirt expects
+	DiskImageName = "disk.img"
+)
+
+// GetVolumeMode returns the volume mode, or Filesystem which is the default of kubernetes if it is not set
+func GetVolumeMode(volumeMode *corev1.PersistentVolumeMode) corev1.PersistentVolumeMode {
+	if volumeMode == nil {
+		return corev1.PersistentVolumeFilesystem
+	}
+	return *volumeMode
+}
+
+// IsBlockVolumeMode returns true if the volume mode is Block
+func IsBlockVolumeMode(volumeMode *corev1.PersistentVolumeMode) bool {
+	return GetVolumeMode(volumeMode) == corev1.PersistentVolumeBlock
+}
+
+// AttachDiskVolume attaches the volume of the disk pvc to the container and returns the path of the disk.
+// The Block-mode volume is attached as the device at devicePath, and the Filesystem-mode volume is mounted at mountPath
+// with the disk at DiskImageName under it
+func AttachDiskVolume(container *corev1.Container, volumeName string, volumeMode *corev1.PersistentVolumeMode, devicePath, mountPath string, readOnly bool) string {
+	if IsBlockVolumeMode(volumeMode) {
+		container.VolumeDevices = append(container.VolumeDevices, corev1.VolumeDevice{Name: volumeName, DevicePath: devicePath})
+		return devicePath
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: volumeName, MountPath: mountPath, ReadOnly: readOnly})
+	return mountPath + "/" + DiskImageName
+}
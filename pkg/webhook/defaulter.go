@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// defaulter sets the defaults to the spec of the image when it is created, and keeps them when it is updated
+type defaulter struct {
+	client    client.Client
+	decoder   *admission.Decoder
+	newObject func() runtime.Object
+	getSpec   func(obj runtime.Object) *hc.VirtualMachineImageSpec
+}
+
+// blank assignment to verify that defaulter implements admission.Handler
+var _ admission.Handler = &defaulter{}
+
+// newDefaulters returns the defaulters of the images by the path of the webhook
+func newDefaulters(c client.Client, decoder *admission.Decoder) map[string]admission.Handler {
+	return map[string]admission.Handler{
+		MutateVirtualMachineImagePath: &defaulter{
+			client:    c,
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.VirtualMachineImage{} },
+			getSpec: func(obj runtime.Object) *hc.VirtualMachineImageSpec {
+				return &obj.(*hc.VirtualMachineImage).Spec
+			},
+		},
+		MutateClusterVirtualMachineImagePath: &defaulter{
+			client:    c,
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.ClusterVirtualMachineImage{} },
+			getSpec: func(obj runtime.Object) *hc.VirtualMachineImageSpec {
+				return &obj.(*hc.ClusterVirtualMachineImage).Spec
+			},
+		},
+	}
+}
+
+// Handle sets the defaults to the image which is created. The image which is updated gets the defaults of the old image
+// instead of the current ones, because the pvc is immutable. So the original manifest without the defaults can be applied again
+func (d *defaulter) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+	obj := d.newObject()
+	if err := d.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1beta1.Update {
+		oldObj := d.newObject()
+		if err := d.decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		setDefaultsFromOldSpec(d.getSpec(obj), d.getSpec(oldObj))
+	} else if err := d.setDefaults(d.getSpec(obj)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// setDefaults sets the storage class and the snapshot class of the config, or the default StorageClass of the cluster and
+// the VolumeSnapshotClass of its provisioner. The access modes and the volume mode are set to the ones which the volumes of VMs can use
+func (d *defaulter) setDefaults(spec *hc.VirtualMachineImageSpec) error {
+	config, err := util.GetConfig(d.client)
+	if err != nil {
+		return err
+	}
+
+	if spec.PVC.StorageClassName == nil {
+		storageClassName, found := config.DefaultStorageClassName, config.DefaultStorageClassName != ""
+		if !found {
+			if storageClassName, found, err = util.GetDefaultStorageClassName(d.client); err != nil {
+				return err
+			}
+		}
+		if found {
+			spec.PVC.StorageClassName = &storageClassName
+		}
+	}
+
+	if spec.SnapshotClassName == "" {
+		spec.SnapshotClassName = config.DefaultSnapshotClassName
+	}
+	if spec.SnapshotClassName == "" && spec.PVC.StorageClassName != nil {
+		// 스토리지 클래스가 없으면 기본값을 채우지 않고, 컨트롤러가 pvc를 만들 때 에러를 남긴다
+		snapshotClassName, found, err := util.GetSnapshotClassNameOfStorageClass(d.client, *spec.PVC.StorageClassName)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if found {
+			spec.SnapshotClassName = snapshotClassName
+		}
+	}
+
+	if len(spec.PVC.AccessModes) == 0 {
+		spec.PVC.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	if spec.PVC.VolumeMode == nil {
+		volumeMode := util.GetVolumeMode(nil)
+		spec.PVC.VolumeMode = &volumeMode
+	}
+	return nil
+}
+
+// setDefaultsFromOldSpec sets the fields which setDefaults sets from the old spec if they are not set in the spec
+func setDefaultsFromOldSpec(spec, oldSpec *hc.VirtualMachineImageSpec) {
+	if spec.PVC.StorageClassName == nil {
+		spec.PVC.StorageClassName = oldSpec.PVC.StorageClassName
+	}
+	if spec.SnapshotClassName == "" {
+		spec.SnapshotClassName = oldSpec.SnapshotClassName
+	}
+	if len(spec.PVC.AccessModes) == 0 {
+		spec.PVC.AccessModes = oldSpec.PVC.AccessModes
+	}
+	if spec.PVC.VolumeMode == nil {
+		spec.PVC.VolumeMode = oldSpec.PVC.VolumeMode
+	}
+}
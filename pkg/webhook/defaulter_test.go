@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	snapshotv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	testProvisioner = "rook-ceph.rbd.csi.ceph.com"
+)
+
+// 번호		operation		config		default StorageClass		VolumeSnapshotClass		spec			result
+// 1		create			X			X							X						empty			access modes and volume mode
+// 2		create			X			O							O						empty			default StorageClass and its VolumeSnapshotClass
+// 3		create			O			O							O						empty			classes of config
+// 4		create			X			O							O						classes set		not changed
+// 5		update			X			O							O						empty			not changed
+// 6		update			X			O							O						empty, old set	classes, access modes and volume mode of old
+var _ = Describe("Handle defaults", func() {
+	Context("1. with no default StorageClass", func() {
+		resp := handle(MutateVirtualMachineImagePath, admissionv1beta1.Create, newTestEmptyVmi(), nil)
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+		It("Should not set the storage class and the snapshot class", func() {
+			Expect(getTestPatch(resp, "/spec/pvc/storageClassName")).Should(BeNil())
+			Expect(getTestPatch(resp, "/spec/snapshotClassName")).Should(BeNil())
+		})
+		It("Should set the access modes and the volume mode", func() {
+			Expect(getTestPatch(resp, "/spec/pvc/accessModes")).Should(Equal([]interface{}{string(corev1.ReadWriteOnce)}))
+			Expect(getTestPatch(resp, "/spec/pvc/volumeMode")).Should(Equal(string(corev1.PersistentVolumeFilesystem)))
+		})
+	})
+
+	Context("2. with default StorageClass and VolumeSnapshotClass of its provisioner", func() {
+		resp := handle(MutateVirtualMachineImagePath, admissionv1beta1.Create, newTestEmptyVmi(), nil, newTestStorageClasses()...)
+
+		It("Should set the default StorageClass and the VolumeSnapshotClass of its provisioner", func() {
+			Expect(getTestPatch(resp, "/spec/pvc/storageClassName")).Should(Equal("rook-ceph-block"))
+			Expect(getTestPatch(resp, "/spec/snapshotClassName")).Should(Equal("csi-rbdplugin-snapclass"))
+		})
+	})
+
+	Context("3. with config", func() {
+		config := &hc.KubevirtImageServiceConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: hc.KubevirtImageServiceConfigName},
+			Spec:       hc.KubevirtImageServiceConfigSpec{DefaultStorageClassName: "mystorageclass", DefaultSnapshotClassName: "mysnapshotclass"},
+		}
+		resp := handle(MutateClusterVirtualMachineImagePath, admissionv1beta1.Create, newTestEmptyCvmi(), nil, append(newTestStorageClasses(), config)...)
+
+		It("Should set the storage class and the snapshot class of the config", func() {
+			Expect(getTestPatch(resp, "/spec/pvc/storageClassName")).Should(Equal("mystorageclass"))
+			Expect(getTestPatch(resp, "/spec/snapshotClassName")).Should(Equal("mysnapshotclass"))
+		})
+	})
+
+	Context("4. with vmi of storage class and snapshot class", func() {
+		vmi := newTestVmi()
+		storageClassName := "mystorageclass"
+		vmi.Spec.PVC.StorageClassName = &storageClassName
+		resp := handle(MutateVirtualMachineImagePath, admissionv1beta1.Create, vmi, nil, newTestStorageClasses()...)
+
+		It("Should not change the storage class and the snapshot class", func() {
+			Expect(getTestPatch(resp, "/spec/pvc/storageClassName")).Should(BeNil())
+			Expect(getTestPatch(resp, "/spec/snapshotClassName")).Should(BeNil())
+		})
+	})
+
+	Context("5. with updated vmi", func() {
+		resp := handle(MutateVirtualMachineImagePath, admissionv1beta1.Update, newTestEmptyVmi(), newTestEmptyVmi(), newTestStorageClasses()...)
+
+		It("Should allow without patches", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+			Expect(resp.Patches).Should(BeEmpty())
+		})
+	})
+
+	Context("6. with updated vmi from the original manifest", func() {
+		oldVmi := newTestVmi()
+		storageClassName, volumeMode := "mystorageclass", corev1.PersistentVolumeBlock
+		oldVmi.Spec.PVC.StorageClassName = &storageClassName
+		oldVmi.Spec.PVC.VolumeMode = &volumeMode
+		resp := handle(MutateVirtualMachineImagePath, admissionv1beta1.Update, newTestEmptyVmi(), oldVmi, newTestStorageClasses()...)
+
+		It("Should set the classes, the access modes and the volume mode of the old vmi", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+			Expect(getTestPatch(resp, "/spec/pvc/storageClassName")).Should(Equal("mystorageclass"))
+			Expect(getTestPatch(resp, "/spec/snapshotClassName")).Should(Equal(oldVmi.Spec.SnapshotClassName))
+			Expect(getTestPatch(resp, "/spec/pvc/accessModes")).Should(Equal([]interface{}{string(oldVmi.Spec.PVC.AccessModes[0])}))
+			Expect(getTestPatch(resp, "/spec/pvc/volumeMode")).Should(Equal(string(corev1.PersistentVolumeBlock)))
+		})
+	})
+})
+
+// getTestPatch returns the value of the patch of the path, or nil if there is no patch of the path
+func getTestPatch(resp admission.Response, path string) interface{} {
+	for _, patch := range resp.Patches {
+		if patch.Path == path {
+			return patch.Value
+		}
+	}
+	return nil
+}
+
+// newTestEmptyVmi returns the vmi without the storage class, the snapshot class, the access modes and the volume mode
+func newTestEmptyVmi() *hc.VirtualMachineImage {
+	vmi := newTestVmi()
+	vmi.Spec.SnapshotClassName = ""
+	vmi.Spec.PVC.AccessModes = nil
+	return vmi
+}
+
+func newTestEmptyCvmi() *hc.ClusterVirtualMachineImage {
+	return &hc.ClusterVirtualMachineImage{
+		TypeMeta:   metav1.TypeMeta{APIVersion: hc.SchemeGroupVersion.String(), Kind: "ClusterVirtualMachineImage"},
+		ObjectMeta: metav1.ObjectMeta{Name: "mycvmi"},
+		Spec:       newTestEmptyVmi().Spec,
+	}
+}
+
+// newTestStorageClasses returns the default StorageClass and the VolumeSnapshotClasses of the other provisioner and its provisioner
+func newTestStorageClasses() []runtime.Object {
+	return []runtime.Object{
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "rook-ceph-block", Annotations: map[string]string{util.DefaultStorageClassAnnotation: "true"}},
+			Provisioner: testProvisioner,
+		},
+		&snapshotv1beta1.VolumeSnapshotClass{
+			ObjectMeta:     metav1.ObjectMeta{Name: "csi-hostpath-snapclass"},
+			Driver:         "hostpath.csi.k8s.io",
+			DeletionPolicy: snapshotv1beta1.VolumeSnapshotContentDelete,
+		},
+		&snapshotv1beta1.VolumeSnapshotClass{
+			ObjectMeta:     metav1.ObjectMeta{Name: "csi-rbdplugin-snapclass"},
+			Driver:         testProvisioner,
+			DeletionPolicy: snapshotv1beta1.VolumeSnapshotContentDelete,
+		},
+	}
+}
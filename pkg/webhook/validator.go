@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	img "kubevirt-image-service/pkg/controller/virtualmachineimage"
+	vmv "kubevirt-image-service/pkg/controller/virtualmachinevolume"
+	vmve "kubevirt-image-service/pkg/controller/virtualmachinevolumeexport"
+	"kubevirt-image-service/pkg/util"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validator denies the admission request of the object which validateCreate returns error for when it is created,
+// or validateUpdate returns error for when it is updated
+type validator struct {
+	decoder        *admission.Decoder
+	newObject      func() runtime.Object
+	validateCreate func(obj runtime.Object) error
+	validateUpdate func(oldObj, obj runtime.Object) error
+}
+
+// blank assignment to verify that validator implements admission.Handler
+var _ admission.Handler = &validator{}
+
+// newValidators returns the validators of all resources by the path of the webhook
+func newValidators(c client.Client, decoder *admission.Decoder) map[string]admission.Handler {
+	// The image is validated with the defaults of the config, as the controller does
+	validateImageSpec := func(spec *hc.VirtualMachineImageSpec) error {
+		config, err := util.GetConfig(c)
+		if err != nil {
+			return err
+		}
+		return img.ValidateVirtualMachineImageSpec(&hc.VirtualMachineImage{Spec: *spec}, config)
+	}
+	// The source of the image can be updated to import a new revision, so the updated spec is validated again
+	validateImageSpecUpdate := func(oldSpec, spec *hc.VirtualMachineImageSpec) error {
+		if err := img.ValidateVirtualMachineImageSpecUpdate(oldSpec, spec); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(oldSpec, spec) {
+			return nil
+		}
+		return validateImageSpec(spec)
+	}
+
+	return map[string]admission.Handler{
+		ValidateVirtualMachineImagePath: &validator{
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.VirtualMachineImage{} },
+			validateCreate: func(obj runtime.Object) error {
+				return validateImageSpec(&obj.(*hc.VirtualMachineImage).Spec)
+			},
+			validateUpdate: func(oldObj, obj runtime.Object) error {
+				return validateImageSpecUpdate(&oldObj.(*hc.VirtualMachineImage).Spec, &obj.(*hc.VirtualMachineImage).Spec)
+			},
+		},
+		ValidateClusterVirtualMachineImagePath: &validator{
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.ClusterVirtualMachineImage{} },
+			validateCreate: func(obj runtime.Object) error {
+				return validateImageSpec(&obj.(*hc.ClusterVirtualMachineImage).Spec)
+			},
+			validateUpdate: func(oldObj, obj runtime.Object) error {
+				return validateImageSpecUpdate(&oldObj.(*hc.ClusterVirtualMachineImage).Spec, &obj.(*hc.ClusterVirtualMachineImage).Spec)
+			},
+		},
+		ValidateVirtualMachineVolumePath: &validator{
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.VirtualMachineVolume{} },
+			validateCreate: func(obj runtime.Object) error {
+				return vmv.ValidateVolumeSpec(obj.(*hc.VirtualMachineVolume))
+			},
+			validateUpdate: func(oldObj, obj runtime.Object) error {
+				return vmv.ValidateVolumeSpecUpdate(&oldObj.(*hc.VirtualMachineVolume).Spec, &obj.(*hc.VirtualMachineVolume).Spec)
+			},
+		},
+		ValidateVirtualMachineVolumeExportPath: &validator{
+			decoder:   decoder,
+			newObject: func() runtime.Object { return &hc.VirtualMachineVolumeExport{} },
+			validateCreate: func(obj runtime.Object) error {
+				return vmve.ValidateVirtualMachineVolumeExportSpec(&obj.(*hc.VirtualMachineVolumeExport).Spec)
+			},
+			validateUpdate: func(oldObj, obj runtime.Object) error {
+				return vmve.ValidateVirtualMachineVolumeExportSpecUpdate(&oldObj.(*hc.VirtualMachineVolumeExport).Spec,
+					&obj.(*hc.VirtualMachineVolumeExport).Spec)
+			},
+		},
+	}
+}
+
+// Handle validates the object of the request. The object which already exists is validated only for the update,
+// so the object created before the webhook is installed can still be updated by the controller, e.g. to remove the finalizer
+func (v *validator) Handle(_ context.Context, req admission.Request) admission.Response {
+	obj := v.newObject()
+	if err := v.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		oldObj := v.newObject()
+		if err := v.decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.validateUpdate(oldObj, obj); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return admission.Allowed("")
+	}
+
+	if err := v.validateCreate(obj); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
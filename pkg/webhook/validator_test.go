@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	hc "kubevirt-image-service/pkg/apis/hypercloud/v1alpha1"
+	"kubevirt-image-service/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	testNs = "default"
+)
+
+// 번호		kind		operation		spec									result
+// 1		vmi			create			valid									allowed
+// 2		vmi			create			no snapshotClassName					denied
+// 3		vmi			create			no snapshotClassName, config default	allowed
+// 4		vmi			update			source									allowed
+// 5		vmi			update			pvc										denied
+// 6		vmi			update			finalizer of invalid vmi				allowed
+// 7		cvmi		create			invalid volumeMode						denied
+// 8		vmv			create			namespace of ClusterVirtualMachineImage	denied
+// 9		vmv			update			capacity								denied
+// 10		vmve		create			multiple destinations					denied
+// 11		vmve		update			finalizer								allowed
+var _ = Describe("Handle", func() {
+	Context("1. with valid vmi", func() {
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Create, newTestVmi(), nil)
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+	})
+
+	Context("2. with vmi without snapshotClassName", func() {
+		vmi := newTestVmi()
+		vmi.Spec.SnapshotClassName = ""
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Create, vmi, nil)
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("snapshotClassName is missing"))
+		})
+	})
+
+	Context("3. with vmi without snapshotClassName and config with default snapshot class", func() {
+		vmi := newTestVmi()
+		vmi.Spec.SnapshotClassName = ""
+		config := &hc.KubevirtImageServiceConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: hc.KubevirtImageServiceConfigName},
+			Spec:       hc.KubevirtImageServiceConfigSpec{DefaultSnapshotClassName: "mysnapshotclass"},
+		}
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Create, vmi, nil, config)
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+	})
+
+	Context("4. with vmi of updated source", func() {
+		vmi := newTestVmi()
+		vmi.Spec.Source.HTTP = "https://example.com/new.qcow2"
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Update, vmi, newTestVmi())
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+	})
+
+	Context("5. with vmi of updated pvc", func() {
+		vmi := newTestVmi()
+		vmi.Spec.PVC.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("5Gi")
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Update, vmi, newTestVmi())
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("pvc is immutable"))
+		})
+	})
+
+	Context("6. with invalid vmi of updated finalizer", func() {
+		oldVmi := newTestVmi()
+		oldVmi.Spec.SnapshotClassName = ""
+		vmi := oldVmi.DeepCopy()
+		vmi.Finalizers = []string{"hypercloud.tmaxanc.com/finalizer"}
+		resp := handle(ValidateVirtualMachineImagePath, admissionv1beta1.Update, vmi, oldVmi)
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+	})
+
+	Context("7. with cvmi of invalid volumeMode", func() {
+		volumeMode := corev1.PersistentVolumeMode("invalid")
+		cvmi := &hc.ClusterVirtualMachineImage{
+			TypeMeta:   metav1.TypeMeta{APIVersion: hc.SchemeGroupVersion.String(), Kind: "ClusterVirtualMachineImage"},
+			ObjectMeta: metav1.ObjectMeta{Name: "mycvmi"},
+			Spec:       newTestVmi().Spec,
+		}
+		cvmi.Spec.PVC.VolumeMode = &volumeMode
+		resp := handle(ValidateClusterVirtualMachineImagePath, admissionv1beta1.Create, cvmi, nil)
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("VolumeMode in pvc is invalid"))
+		})
+	})
+
+	Context("8. with vmv of ClusterVirtualMachineImage with namespace", func() {
+		volume := newTestVolume()
+		volume.Spec.VirtualMachineImage.Kind = hc.ClusterVirtualMachineImageKind
+		volume.Spec.VirtualMachineImage.Namespace = "othernamespace"
+		resp := handle(ValidateVirtualMachineVolumePath, admissionv1beta1.Create, volume, nil)
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("namespace can't be set"))
+		})
+	})
+
+	Context("9. with vmv of updated capacity", func() {
+		volume := newTestVolume()
+		volume.Spec.Capacity[corev1.ResourceStorage] = resource.MustParse("5Gi")
+		resp := handle(ValidateVirtualMachineVolumePath, admissionv1beta1.Update, volume, newTestVolume())
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("immutable"))
+		})
+	})
+
+	Context("10. with vmve of multiple destinations", func() {
+		export := newTestExport()
+		export.Spec.Destination.S3 = &hc.VirtualMachineVolumeExportDestinationS3{URL: "https://s3.example.com/bucket/disk.img"}
+		resp := handle(ValidateVirtualMachineVolumeExportPath, admissionv1beta1.Create, export, nil)
+
+		It("Should deny with the reason", func() {
+			Expect(resp.Allowed).Should(BeFalse())
+			Expect(string(resp.Result.Reason)).Should(ContainSubstring("multiple destination"))
+		})
+	})
+
+	Context("11. with vmve of updated finalizer", func() {
+		export := newTestExport()
+		export.Finalizers = []string{"hypercloud.tmaxanc.com/finalizer"}
+		resp := handle(ValidateVirtualMachineVolumeExportPath, admissionv1beta1.Update, export, newTestExport())
+
+		It("Should allow", func() {
+			Expect(resp.Allowed).Should(BeTrue())
+		})
+	})
+})
+
+// handle returns the response of the defaulter or the validator of the path for the request of obj, which is updated from oldObj if it is not nil
+func handle(path string, operation admissionv1beta1.Operation, obj, oldObj runtime.Object, objects ...runtime.Object) admission.Response {
+	client, scheme, err := util.CreateFakeClientAndScheme(objects...)
+	if err != nil {
+		panic(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		panic(err)
+	}
+	req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+		Operation: operation,
+		Object:    runtime.RawExtension{Raw: toJSON(obj)},
+	}}
+	if oldObj != nil {
+		req.OldObject = runtime.RawExtension{Raw: toJSON(oldObj)}
+	}
+	if defaulter, found := newDefaulters(client, decoder)[path]; found {
+		return defaulter.Handle(context.TODO(), req)
+	}
+	return newValidators(client, decoder)[path].Handle(context.TODO(), req)
+}
+
+func toJSON(obj runtime.Object) []byte {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func newTestVmi() *hc.VirtualMachineImage {
+	return &hc.VirtualMachineImage{
+		TypeMeta:   metav1.TypeMeta{APIVersion: hc.SchemeGroupVersion.String(), Kind: hc.VirtualMachineImageKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "myvmi", Namespace: testNs},
+		Spec: hc.VirtualMachineImageSpec{
+			Source:            hc.VirtualMachineImageSource{HTTP: "https://example.com/disk.qcow2"},
+			SnapshotClassName: "mysnapshotclass",
+			PVC: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("3Gi")},
+				},
+			},
+		},
+	}
+}
+
+func newTestVolume() *hc.VirtualMachineVolume {
+	return &hc.VirtualMachineVolume{
+		TypeMeta:   metav1.TypeMeta{APIVersion: hc.SchemeGroupVersion.String(), Kind: "VirtualMachineVolume"},
+		ObjectMeta: metav1.ObjectMeta{Name: "myvmv", Namespace: testNs},
+		Spec: hc.VirtualMachineVolumeSpec{
+			VirtualMachineImage: hc.VirtualMachineImageName{Name: "myvmi"},
+			Capacity:            corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("3Gi")},
+		},
+	}
+}
+
+func newTestExport() *hc.VirtualMachineVolumeExport {
+	return &hc.VirtualMachineVolumeExport{
+		TypeMeta:   metav1.TypeMeta{APIVersion: hc.SchemeGroupVersion.String(), Kind: "VirtualMachineVolumeExport"},
+		ObjectMeta: metav1.ObjectMeta{Name: "myvmve", Namespace: testNs},
+		Spec: hc.VirtualMachineVolumeExportSpec{
+			VirtualMachineVolume: hc.VirtualMachineVolumeSource{Name: "myvmv"},
+			Destination:          hc.VirtualMachineVolumeExportDestination{Local: &hc.VirtualMachineVolumeExportDestinationLocal{}},
+		},
+	}
+}
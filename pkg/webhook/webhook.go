@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Paths of the webhooks, which must match the MutatingWebhookConfiguration and the ValidatingWebhookConfiguration in deploy/webhook.yaml
+const (
+	MutateVirtualMachineImagePath          = "/mutate-virtualmachineimage"
+	MutateClusterVirtualMachineImagePath   = "/mutate-clustervirtualmachineimage"
+	ValidateVirtualMachineImagePath        = "/validate-virtualmachineimage"
+	ValidateClusterVirtualMachineImagePath = "/validate-clustervirtualmachineimage"
+	ValidateVirtualMachineVolumePath       = "/validate-virtualmachinevolume"
+	ValidateVirtualMachineVolumeExportPath = "/validate-virtualmachinevolumeexport"
+)
+
+// AddToManager registers the defaulting webhooks of the images and the validating webhooks of all resources to the webhook server of the Manager
+func AddToManager(mgr manager.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	server := mgr.GetWebhookServer()
+	for path, handler := range newDefaulters(mgr.GetClient(), decoder) {
+		server.Register(path, &webhook.Admission{Handler: handler})
+	}
+	for path, handler := range newValidators(mgr.GetClient(), decoder) {
+		server.Register(path, &webhook.Admission{Handler: handler})
+	}
+	return nil
+}